@@ -0,0 +1,560 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/d3z41k/image-grabber/pkg/grabber"
+	"github.com/dustin/go-humanize"
+)
+
+// stateFile is written into the output directory by crawl and download so
+// that an interrupted run can be picked up again with `grab resume <dir>`.
+const stateFile = ".grab-state.json"
+
+// state is what gets persisted to stateFile: the command that was run,
+// its arguments and the options it was run with.
+type state struct {
+	Command string          `json:"command"`
+	Args    []string        `json:"args"`
+	Options grabber.Options `json:"options"`
+}
+
+// listFlag is a comma separated flag value, e.g. -ext jpg,png
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listFlag) Set(v string) error {
+	*l = nil
+	for _, s := range strings.Split(v, ",") {
+		s = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(s)), ".")
+		if s != "" {
+			*l = append(*l, s)
+		}
+	}
+	return nil
+}
+
+// headerFlag is a repeatable header flag value, e.g.
+// -header 'Referer: https://example.com/' -header 'Cookie: a=b'
+type headerFlag map[string]string
+
+func (h *headerFlag) String() string {
+	var headers []string
+	for k, v := range *h {
+		headers = append(headers, k+": "+v)
+	}
+	return strings.Join(headers, ", ")
+}
+
+func (h *headerFlag) Set(s string) error {
+	k, v, ok := strings.Cut(s, ":")
+	k = strings.TrimSpace(k)
+	if !ok || k == "" {
+		return fmt.Errorf("bad header %q, want 'Key: Value'", s)
+	}
+	if *h == nil {
+		*h = make(headerFlag)
+	}
+	(*h)[k] = strings.TrimSpace(v)
+	return nil
+}
+
+// urlsFlag is a comma separated flag value kept as is, e.g.
+// -proxy http://a:3128,socks5://b:1080
+type urlsFlag []string
+
+func (l *urlsFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *urlsFlag) Set(v string) error {
+	*l = nil
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			*l = append(*l, s)
+		}
+	}
+	return nil
+}
+
+// ruleFlag is a repeatable flag value kept as is, e.g.
+// -full-size-rule 's/_small\././' -full-size-rule 's,/thumbs/,/full/,'
+type ruleFlag []string
+
+func (r *ruleFlag) String() string {
+	return strings.Join(*r, " ")
+}
+
+func (r *ruleFlag) Set(v string) error {
+	*r = append(*r, v)
+	return nil
+}
+
+// bytesFlag is a size flag value accepting units, e.g. -min-bytes 10KB
+type bytesFlag int64
+
+func (b *bytesFlag) String() string {
+	if *b == 0 {
+		return "0"
+	}
+	return humanize.Bytes(uint64(*b))
+}
+
+func (b *bytesFlag) Set(v string) error {
+	n, err := humanize.ParseBytes(v)
+	if err != nil {
+		return err
+	}
+	*b = bytesFlag(n)
+	return nil
+}
+
+// hostRateFlag is a repeatable rate flag value for a host, e.g.
+// -host-rate example.com=0.5 -host-rate cdn.example.com=20
+type hostRateFlag map[string]float64
+
+func (h *hostRateFlag) String() string {
+	var rates []string
+	for host, rate := range *h {
+		rates = append(rates, host+"="+strconv.FormatFloat(rate, 'g', -1, 64))
+	}
+	return strings.Join(rates, ",")
+}
+
+func (h *hostRateFlag) Set(s string) error {
+	host, v, ok := strings.Cut(s, "=")
+	host = strings.ToLower(strings.TrimSpace(host))
+	rate, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+	if !ok || host == "" || err != nil {
+		return fmt.Errorf("bad host rate %q, want host=rate", s)
+	}
+	if *h == nil {
+		*h = make(hostRateFlag)
+	}
+	(*h)[host] = rate
+	return nil
+}
+
+// ipVersionFlag is a boolean flag making the connections use a single IP
+// version, e.g. -4
+type ipVersionFlag struct {
+	version *int
+	v       int
+}
+
+func (f ipVersionFlag) IsBoolFlag() bool { return true }
+
+func (f ipVersionFlag) String() string {
+	return strconv.FormatBool(f.version != nil && *f.version == f.v)
+}
+
+func (f ipVersionFlag) Set(s string) error {
+	on, err := strconv.ParseBool(s)
+	if err != nil {
+		return err
+	}
+	if on {
+		*f.version = f.v
+	} else if *f.version == f.v {
+		*f.version = 0
+	}
+	return nil
+}
+
+// quiet and verbose are set by the -quiet and -verbose flags of every
+// subcommand, reportFile by -report.
+var (
+	quiet, verbose bool
+	reportFile     string
+)
+
+// logLevel is the level of the messages logged, warnings and errors unless
+// -quiet or -verbose say otherwise.
+func logLevel() slog.Level {
+	switch {
+	case quiet:
+		return slog.LevelError
+	case verbose:
+		return slog.LevelDebug
+	}
+	return slog.LevelWarn
+}
+
+// newFlagSet registers the flags common to all subcommands on top of opts.
+func newFlagSet(name, args string, opts *grabber.Options) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: grab %s [flags] %s\n\nflags:\n", name, args)
+		fs.PrintDefaults()
+	}
+
+	fs.BoolVar(&quiet, "quiet", quiet, "only print errors, no progress")
+	fs.BoolVar(&verbose, "verbose", verbose, "log every page and request")
+	fs.StringVar(&reportFile, "report", reportFile, "write a JSON summary of the run to this `file`")
+	fs.String("config", "", "YAML or TOML `file` with the urls and options of the run")
+	fs.StringVar(&opts.Dir, "o", opts.Dir, "output `directory`, .zip or .tar.gz archive, or s3://, gs://, azblob://, sftp://, webdav:// or webdavs:// url to upload to")
+	fs.IntVar(&opts.Concurrency, "c", opts.Concurrency, "number of concurrent downloads")
+	fs.IntVar(&opts.MaxConnsPerHost, "max-conns-per-host", opts.MaxConnsPerHost, "open at most this many connections to a host at once, shared by downloads and pages, 0 for no limit")
+	fs.IntVar(&opts.Limit, "limit", opts.Limit, "download at most this many images, 0 for all")
+	fs.Var((*headerFlag)(&opts.Headers), "header", "send this `'Key: Value'` header with every request, may be repeated")
+	fs.StringVar(&opts.UserAgent, "user-agent", opts.UserAgent, "user agent `string` for all requests")
+	fs.StringVar(&opts.Auth, "auth", opts.Auth, "`user:password` sent with HTTP Basic auth with every request, not kept for resume")
+	fs.StringVar(&opts.Bearer, "bearer", opts.Bearer, "`token` sent as a bearer token with every request, not kept for resume")
+	fs.Var((*urlsFlag)(&opts.Proxies), "proxy", "comma separated proxy `urls` (http, https or socks5) used round robin")
+	fs.StringVar(&opts.ProxyFile, "proxy-file", opts.ProxyFile, "`file` listing more proxies, one per line")
+	fs.IntVar(&opts.ProxyMaxFails, "proxy-max-fails", opts.ProxyMaxFails, "take a proxy out of the rotation after this many failed requests in a row, 0 never does")
+	fs.DurationVar(&opts.ProxyRecheck, "proxy-recheck", opts.ProxyRecheck, "try dead proxies again after this long, 0 never does")
+	fs.Var((*ruleFlag)(&opts.Resolve), "resolve", "connect to `host:ip` instead of looking host up, chrome included, may be repeated")
+	fs.Var(ipVersionFlag{&opts.IPVersion, 4}, "4", "only connect over IPv4")
+	fs.Var(ipVersionFlag{&opts.IPVersion, 6}, "6", "only connect over IPv6")
+	fs.BoolVar(&opts.TLS.Insecure, "insecure", opts.TLS.Insecure, "accept any TLS certificate, chrome included")
+	fs.StringVar(&opts.TLS.CAFile, "ca-file", opts.TLS.CAFile, "PEM `file` of CAs to trust on top of those of the system")
+	fs.StringVar(&opts.TLS.CertFile, "cert", opts.TLS.CertFile, "PEM client certificate `file` for the servers asking for one, with -key")
+	fs.StringVar(&opts.TLS.KeyFile, "key", opts.TLS.KeyFile, "PEM `file` of the key of the -cert client certificate")
+	fs.StringVar(&opts.CookiesFile, "cookies-file", opts.CookiesFile, "Netscape cookies.txt or JSON `file` with cookies to send")
+	fs.Var(&opts.CookiesFromBrowser, "cookies-from-browser", "send the cookies `browser` (chrome or firefox) has for the sites grabbed, to grab as logged in there")
+	fs.Var((*listFlag)(&opts.Extensions), "ext", "only download files with these comma separated `extensions`")
+	fs.Var((*listFlag)(&opts.Types), "types", "only grab these comma separated content `types`, as jpeg,png,webp, checked against the extension before and the content after downloading")
+	fs.StringVar(&opts.AcceptRegex, "accept-regex", opts.AcceptRegex, "only crawl and download the page, photo and image urls matching this `regexp`")
+	fs.StringVar(&opts.RejectRegex, "reject-regex", opts.RejectRegex, "don't crawl or download the page, photo and image urls matching this `regexp`")
+	fs.Var((*listFlag)(&opts.AllowedDomains), "allowed-domains", "comma separated `domains` crawling and downloading may touch, with their subdomains and the registered domain of the start page")
+	fs.Var((*ruleFlag)(&opts.Rewrite), "rewrite", "`s/pattern/replacement/` rule the urls of the images found are rewritten with before downloading, may be repeated")
+	fs.BoolVar(&opts.FullSize, "full-size", opts.FullSize, "try the original of images whose url looks like a resized copy, as photo_small.jpg or /thumbs/photo.jpg, before the image found")
+	fs.Var((*ruleFlag)(&opts.FullSizeRules), "full-size-rule", "`s/pattern/replacement/` rule turning an image url into the url of its original, tried before the image found, may be repeated")
+	fs.BoolVar(&opts.KeepErrorPages, "keep-error-pages", opts.KeepErrorPages, "keep the web pages served in place of files in the error-pages directory, -keep-error-pages=false to remove them")
+	fs.BoolVar(&opts.ImagesOnly, "images-only", opts.ImagesOnly, "discard downloads that turn out not to be images, or videos with -media video")
+	fs.Var(&opts.Playlists, "playlists", "what to do with HLS and DASH video playlists: `export` them to the JSON manifest or stitch the HLS segments into a file")
+	fs.Var((*listFlag)(&opts.Media), "media", "comma separated `kinds` of media to grab: image, video or both")
+	fs.StringVar(&opts.Layout, "layout", opts.Layout, "save the downloads into subdirectories named after these `parts` separated by /: host, page (the page an image was on) or date, e.g. host/page")
+	fs.BoolVar(&opts.Fsync, "fsync", opts.Fsync, "flush every download to the disk before naming it, and quarantine the partial downloads of a run that went down instead of resuming them")
+	fs.Var((*bytesFlag)(&opts.MinBytes), "min-bytes", "skip images smaller than this `size`, e.g. 10KB")
+	fs.Var((*bytesFlag)(&opts.MaxBytesPerFile), "max-bytes-per-file", "skip files bigger than this `size`, e.g. 50MB, stopping their download as soon as it is exceeded")
+	fs.IntVar(&opts.MinWidth, "min-width", opts.MinWidth, "skip images narrower than this many `pixels`")
+	fs.IntVar(&opts.MinHeight, "min-height", opts.MinHeight, "skip images lower than this many `pixels`")
+	fs.Var((*bytesFlag)(&opts.ProbeBytes), "probe-bytes", "with -min-width or -min-height, fetch only this `size` of every image first, e.g. 64KB, and the rest only if its dimensions pass")
+	fs.Var(&opts.Dedup, "dedup", "what to do with content that was saved before: off, skip or `link`")
+	fs.Var(&opts.NearDup, "near-dup", "what to do with images looking like one saved before: off, `flag` or skip")
+	fs.IntVar(&opts.NearDupDistance, "near-dup-distance", opts.NearDupDistance, "how many of the 64 perceptual hash `bits` may differ for images to count as the same")
+	fs.Var((*listFlag)(&opts.Convert), "convert", "convert images, as comma separated from:to `formats` like webp:jpeg,avif:jpeg (avif needs a build with -tags avif)")
+	fs.IntVar(&opts.ConvertQuality, "convert-quality", opts.ConvertQuality, "JPEG `quality` (1-100) of converted images")
+	fs.IntVar(&opts.Thumbnails, "thumbs", opts.Thumbnails, "write thumbnails at most this many `pixels` wide and high into thumbs/ in the output directory, 0 for none")
+	fs.BoolVar(&opts.ServerTimes, "server-times", opts.ServerTimes, "give the files the Last-Modified time of the server, -server-times=false keeps the time of the grab")
+	fs.BoolVar(&opts.Verify, "verify", opts.Verify, "check downloads against the Content-MD5 or ETag of the server and retry corrupt ones")
+	fs.StringVar(&opts.Manifest, "manifest", opts.Manifest, "`file` in the output directory recording what was downloaded, empty to disable")
+	fs.StringVar(&opts.JSONManifest, "json-manifest", opts.JSONManifest, "append a JSON line describing every grabbed image to this `file`")
+	fs.StringVar(&opts.Catalog, "catalog", opts.Catalog, "record every grabbed image in this SQLite `file`, searched with grab query")
+	fs.IntVar(&opts.MaxFiles, "max-files", opts.MaxFiles, "stop once the output directory has this many files, 0 for no limit")
+	fs.Var((*bytesFlag)(&opts.MaxTotalSize), "max-total-size", "stop once the output directory holds this `size`, e.g. 50G")
+	fs.BoolVar(&opts.DryRun, "dry-run", opts.DryRun, "only print what would be downloaded, with the sizes the servers report, and write nothing")
+	fs.BoolVar(&opts.Preflight, "preflight", opts.Preflight, "ask for all sizes first and stop if the downloads won't fit on the disk")
+	fs.Float64Var(&opts.Rate, "rate", opts.Rate, "maximum `requests` per second to each host, 0 for no limit")
+	fs.DurationVar(&opts.Delay, "delay", opts.Delay, "wait this long between requests to the same host")
+	fs.DurationVar(&opts.RandomDelay, "random-delay", opts.RandomDelay, "add a random delay of up to this `duration` between requests to the same host")
+	fs.Var((*hostRateFlag)(&opts.HostRates), "host-rate", "maximum requests per second to a host as `host=rate`, in place of -rate, may be repeated")
+	fs.Var((*ruleFlag)(&opts.Priority), "priority", "order the downloads by this `rule`: originals, newest (pages with the latest date in their url first) or url=REGEXP (matching images first), may be repeated, the first rule winning")
+	fs.IntVar(&opts.HostConcurrency, "host-concurrency", opts.HostConcurrency, "number of concurrent downloads from the same host, 0 for as many as -c")
+	fs.BoolVar(&opts.Robots, "robots", opts.Robots, "obey robots.txt, skipping the pages and images it disallows")
+	fs.IntVar(&opts.Retries, "retries", opts.Retries, "how many times to retry a transient failure")
+	fs.DurationVar(&opts.Backoff, "backoff", opts.Backoff, "delay before the first retry, doubled on every further retry")
+	fs.Float64Var(&opts.Jitter, "jitter", opts.Jitter, "randomize retry delays by this `fraction` (0-1)")
+	fs.DurationVar(&opts.Timeout, "timeout", opts.Timeout, "give up on a download attempt after this long, 0 for never")
+	fs.DurationVar(&opts.PageTimeout, "page-timeout", opts.PageTimeout, "give up on loading a page, in chrome or not, after this long, 0 for never")
+	fs.DurationVar(&opts.TotalTimeout, "total-timeout", opts.TotalTimeout, "stop the run after this long, saving what it got, 0 for never")
+	fs.StringVar(&opts.Exec, "exec", opts.Exec, "shell `command` to run for every file saved, {} being its path")
+	fs.IntVar(&opts.ExecJobs, "exec-jobs", opts.ExecJobs, "number of -exec commands running at the same time, 0 for the number of CPUs")
+	fs.StringVar(&opts.Webhook, "webhook", opts.Webhook, "`url` to post a JSON summary to when the run ends")
+	fs.Var(&opts.WebhookFormat, "webhook-format", "`format` of the webhook: generic, slack or discord, guessed from the url by default")
+	fs.Float64Var(&opts.WebhookFailureRate, "webhook-failure-rate", opts.WebhookFailureRate, "also post to the webhook once more than this `fraction` (0-1) of the urls failed, 0 for never")
+
+	return fs
+}
+
+// crawlFlags registers the flags that only matter when crawling.
+func crawlFlags(fs *flag.FlagSet, opts *grabber.Options) {
+	fs.IntVar(&opts.Depth, "depth", opts.Depth, "how many levels of same-host links to crawl below the start page")
+	fs.BoolVar(&opts.Pagination, "pagination", opts.Pagination, "follow rel=\"next\" and ?page=N links to the next pages of a gallery, -pagination=false to turn off")
+	fs.BoolVar(&opts.NormalizeURLs, "normalize-urls", opts.NormalizeURLs, "tell the links and images found apart by their canonical url, without fragment and tracking parameters, -normalize-urls=false to turn off")
+	fs.Var((*listFlag)(&opts.TrackingParams), "tracking-params", "comma separated query `parameters` dropped from the urls found, a trailing * matching any suffix")
+	fs.BoolVar(&opts.SpanHosts, "span-hosts", opts.SpanHosts, "also crawl the pages linked on other hosts, as far as -allowed-domains lets it")
+	fs.IntVar(&opts.MaxPages, "max-pages", opts.MaxPages, "crawl at most this many pages, 0 for all")
+	fs.BoolVar(&opts.Sitemap, "sitemap", opts.Sitemap, "crawl the pages listed in the sitemaps of the site, found in robots.txt or at /sitemap.xml")
+	fs.StringVar(&opts.LinkSelector, "link-selector", opts.LinkSelector, "CSS `selector` of the links to photo detail pages")
+	fs.StringVar(&opts.ImageSelector, "image-selector", opts.ImageSelector, "CSS `selector` of the elements whose srcset, src or href is an image")
+	fs.Var((*listFlag)(&opts.LazyAttributes), "lazy-attrs", "comma separated `attributes` holding the real url of lazy loaded images, looked at before srcset and src")
+	fs.BoolVar(&opts.MetaImages, "meta-images", opts.MetaImages, "also grab the og:image and twitter:image of every page")
+	fs.BoolVar(&opts.EmbeddedImages, "embedded-images", opts.EmbeddedImages, "also grab the images in the JSON-LD and other JSON data of every page")
+	fs.BoolVar(&opts.Scroll, "scroll", opts.Scroll, "render the pages in chrome and scroll down until no more links and images load")
+	fs.IntVar(&opts.MaxScrolls, "max-scrolls", opts.MaxScrolls, "stop scrolling a page after this many scrolls, 0 for no limit")
+	fs.IntVar(&opts.ScrollItems, "scroll-items", opts.ScrollItems, "stop scrolling a page once it has this many links and images, 0 for no limit")
+	fs.StringVar(&opts.LoadMoreSelector, "load-more", opts.LoadMoreSelector, "CSS `selector` of a \"load more\" button to click, in chrome, until no more links and images load")
+	fs.IntVar(&opts.MaxLoadMore, "max-load-more", opts.MaxLoadMore, "click the load more button at most this many times, 0 for no limit")
+	fs.BoolVar(&opts.StaticFirst, "static-first", opts.StaticFirst, "look for the images of photo detail pages in their HTML first and only open them in chrome if it has none")
+	fs.BoolVar(&opts.NetworkImages, "network-images", opts.NetworkImages, "also grab every image chrome loads while a photo detail page is open, like those set by scripts")
+	fs.IntVar(&opts.Tabs, "tabs", opts.Tabs, "open this many photo detail pages in chrome tabs at the same time")
+	fs.StringVar(&opts.ClickSelector, "click-selector", opts.ClickSelector, "CSS `selector` clicked on photo detail pages, empty to only open them")
+	fs.BoolVar(&opts.Screenshots, "screenshots", opts.Screenshots, "save a screenshot of photo detail pages that have no image to download")
+	fs.StringVar(&opts.ScreenshotSelector, "screenshot-selector", opts.ScreenshotSelector, "CSS `selector` of the elements to take a screenshot of, the biggest one is taken")
+	fs.StringVar(&opts.Frontier, "frontier", opts.Frontier, "SQLite `file` in the output directory keeping what a crawl has left to do, for grab resume to go on from, empty to disable")
+	fs.BoolVar(&opts.Diff, "diff", opts.Diff, "only grab the images the manifest doesn't have yet and list those of the site it has that are gone")
+	fs.StringVar(&opts.ExportLinks, "export-links", opts.ExportLinks, "write the discovered photo and image urls to this CSV `file` instead of downloading them")
+	fs.BoolVar(&opts.Referer, "referer", opts.Referer, "send the page an image was found on as its Referer, -referer=false to turn off")
+	fs.BoolVar(&opts.Browser.Headless, "headless", opts.Browser.Headless, "run chrome without a window, -headless=false to watch it")
+	fs.StringVar(&opts.Browser.ProxyServer, "proxy-server", opts.Browser.ProxyServer, "proxy `url` for chrome, instead of the first -proxy")
+	fs.StringVar(&opts.Browser.WindowSize, "window-size", opts.Browser.WindowSize, "chrome window `size` as WIDTHxHEIGHT")
+	fs.Var((*listFlag)(&opts.Browser.Block), "block", "comma separated `kinds` of requests chrome doesn't make, to render pages faster: font, stylesheet, media, analytics or ads")
+	fs.Var(&opts.Browser.Profile, "chrome-profile", "chrome flags for where it runs: default or `container` for Docker and CI (no sandbox, no /dev/shm, chrome looked up in $CHROME_PATH and the usual places)")
+	fs.StringVar(&opts.Browser.RemoteURL, "remote-debugging-url", opts.Browser.RemoteURL, "DevTools `url` of a running chrome to use instead of starting one, as ws://host:9222/devtools/browser/ID or http://host:9222")
+}
+
+func usage() {
+	fmt.Fprint(os.Stderr, `usage: grab <command> [flags] [arguments]
+
+commands:
+  crawl     crawl a gallery page and grab the photos it links to
+  download  download image urls given as arguments, with -input or on stdin
+  resume    resume an interrupted crawl or download in a directory
+  watch     crawl galleries again every -interval and grab what is new
+  mirror    make a directory match galleries: grab what is new or changed, -delete what is gone
+  schedule  run the jobs of a jobs file on their cron schedules
+  serve     take crawl and download jobs over an HTTP API and a web dashboard
+  query     search the catalog of grabbed images
+
+Run "grab <command> -h" for the flags of a command.
+
+exit status:
+  0  everything was grabbed
+  1  nothing could be grabbed or the run failed
+  2  bad usage
+  3  some urls failed, the rest was grabbed
+`)
+}
+
+func runCrawl(ctx context.Context, args []string) error {
+	opts := grabber.DefaultOptions()
+	urls, err := loadConfig(args, &opts)
+	if err != nil {
+		return err
+	}
+
+	fs := newFlagSet("crawl", "url...", &opts)
+	crawlFlags(fs, &opts)
+	fs.Parse(args)
+
+	if fs.NArg() > 0 {
+		urls = fs.Args()
+	}
+	if len(urls) == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+	if err := saveState(state{Command: "crawl", Args: urls, Options: opts}); err != nil {
+		return err
+	}
+
+	return crawl(ctx, urls, opts)
+}
+
+func runDownload(ctx context.Context, args []string) error {
+	opts := grabber.DefaultOptions()
+	urls, err := loadConfig(args, &opts)
+	if err != nil {
+		return err
+	}
+
+	fs := newFlagSet("download", "url...", &opts)
+	input := fs.String("input", "", "read the urls from this `file`, one per line, - for stdin")
+	fs.Parse(args)
+
+	if fs.NArg() > 0 {
+		urls = fs.Args()
+	}
+	// Piped in urls are read even without -input
+	if *input == "" && len(urls) == 0 && !isTerminal(os.Stdin) {
+		*input = "-"
+	}
+	if *input != "" {
+		list, err := readURLs(*input)
+		if err != nil {
+			return err
+		}
+		urls = append(urls, list...)
+	}
+	if len(urls) == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+	if err := saveState(state{Command: "download", Args: urls, Options: opts}); err != nil {
+		return err
+	}
+
+	return download(ctx, urls, opts)
+}
+
+// readURLs reads a newline separated url list from the file at path, or
+// from stdin if path is "-". Empty lines and lines starting with # are
+// skipped.
+func readURLs(path string) ([]string, error) {
+	f := os.Stdin
+	if path != "-" {
+		var err error
+		if f, err = os.Open(path); err != nil {
+			return nil, err
+		}
+		defer f.Close()
+	}
+
+	var urls []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			urls = append(urls, line)
+		}
+	}
+	return urls, scanner.Err()
+}
+
+// isTerminal reports whether f is a terminal rather than a pipe or file.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// loadConfig applies the file given with -config to opts before the other
+// flags are parsed, so that flags on the command line override the file. It
+// returns the urls listed in the file.
+func loadConfig(args []string, opts *grabber.Options) ([]string, error) {
+	var path string
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		if a == "--" {
+			break
+		}
+		switch {
+		case a == "-config" || a == "--config":
+			if i+1 < len(args) {
+				path = args[i+1]
+			}
+		case strings.HasPrefix(a, "-config="), strings.HasPrefix(a, "--config="):
+			path = a[strings.Index(a, "=")+1:]
+		}
+	}
+	if path == "" {
+		return nil, nil
+	}
+
+	cfg, err := grabber.LoadConfig(path, *opts)
+	if err != nil {
+		return nil, err
+	}
+	*opts = cfg.Options
+
+	return cfg.URLs, nil
+}
+
+// runResume re-runs the command recorded in the state file of a directory.
+// Flags given on the command line override the recorded options.
+func runResume(ctx context.Context, args []string) error {
+	opts := grabber.DefaultOptions()
+	fs := resumeFlags(&opts)
+	fs.Parse(args)
+
+	dir := "."
+	if fs.NArg() > 0 {
+		dir = fs.Arg(0)
+	}
+
+	st, err := loadState(dir)
+	if err != nil {
+		return err
+	}
+
+	// Parse again on top of the recorded options so only explicit flags win.
+	opts = st.Options
+	opts.Dir = dir
+	resumeFlags(&opts).Parse(args)
+	// A crawl goes on from its frontier rather than from the start page
+	opts.Resume = true
+
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+
+	fmt.Printf("Resuming %s of %s\n", st.Command, strings.Join(st.Args, " "))
+
+	switch st.Command {
+	case "crawl":
+		return crawl(ctx, st.Args, opts)
+	case "download":
+		return download(ctx, st.Args, opts)
+	}
+
+	return fmt.Errorf("unknown command %q in %s", st.Command, filepath.Join(dir, stateFile))
+}
+
+func resumeFlags(opts *grabber.Options) *flag.FlagSet {
+	fs := newFlagSet("resume", "[directory]", opts)
+	crawlFlags(fs, opts)
+	return fs
+}
+
+func saveState(st state) error {
+	// A dry run leaves nothing behind, not even a state to resume
+	if st.Options.DryRun {
+		return nil
+	}
+	// There is no resuming from object storage
+	if grabber.IsRemote(st.Options.Dir) {
+		return nil
+	}
+
+	if err := os.MkdirAll(st.Options.Dir, 0700); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filepath.Join(st.Options.Dir, stateFile), data, 0600)
+}
+
+func loadState(dir string) (state, error) {
+	var st state
+
+	data, err := os.ReadFile(filepath.Join(dir, stateFile))
+	if os.IsNotExist(err) {
+		return st, fmt.Errorf("nothing to resume in %s", dir)
+	}
+	if err != nil {
+		return st, err
+	}
+
+	if err := json.Unmarshal(data, &st); err != nil {
+		return st, fmt.Errorf("%s: %v", stateFile, err)
+	}
+	if len(st.Args) == 0 {
+		return st, fmt.Errorf("%s: no arguments recorded", stateFile)
+	}
+
+	return st, nil
+}
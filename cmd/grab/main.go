@@ -0,0 +1,263 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/d3z41k/image-grabber/pkg/grabber"
+	"github.com/dustin/go-humanize"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	// The first interrupt stops what runs, which still saves what it got,
+	// the second one doesn't wait for that
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	var err error
+	switch cmd, args := os.Args[1], os.Args[2:]; cmd {
+	case "crawl":
+		err = runCrawl(ctx, args)
+	case "download":
+		err = runDownload(ctx, args)
+	case "resume":
+		err = runResume(ctx, args)
+	case "watch":
+		err = runWatch(ctx, args)
+	case "mirror":
+		err = runMirror(ctx, args)
+	case "schedule":
+		err = runSchedule(ctx, args)
+	case "serve":
+		err = runServe(ctx, args)
+	case "query":
+		err = runQuery(args)
+	case "help", "-h", "-help", "--help":
+		usage()
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "grab: unknown command %q\n\n", cmd)
+		usage()
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "grab:", err)
+		os.Exit(exitCode(err))
+	}
+}
+
+// exitCode tells a run where only some urls failed apart from one where
+// nothing came through or that couldn't go on.
+func exitCode(err error) int {
+	var failedErr *grabber.FailedError
+	if errors.As(err, &failedErr) && failedErr.Partial() {
+		return 3
+	}
+	return 1
+}
+
+// newGrabber creates a grabber that reports to the terminal through r.
+func newGrabber(opts grabber.Options, r *renderer) (*grabber.Grabber, error) {
+	g, err := grabber.New(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	g.SetLogger(slog.New(slog.NewTextHandler(r, &slog.HandlerOptions{Level: logLevel()})))
+	g.OnQueue = r.Queue
+	g.OnProgress = r.Update
+	g.OnDownload = func(f *grabber.File) {
+		if f.SimilarTo != "" {
+			r.Printf("%s looks like %s\n", f.Path, f.SimilarTo)
+		}
+	}
+	g.OnPreflight = func(p grabber.Preflight) {
+		free := "unknown"
+		if p.Free >= 0 {
+			free = humanize.Bytes(uint64(p.Free))
+		}
+		r.Printf("Expecting %d files, %s", p.Files, humanize.Bytes(uint64(p.Bytes)))
+		if p.Unknown > 0 {
+			r.Printf(" plus %d of unknown size", p.Unknown)
+		}
+		r.Printf(", %s free\n", free)
+	}
+	g.OnError = func(f grabber.Failure) {
+		r.Fail(f.URL)
+	}
+
+	return g, nil
+}
+
+// crawl grabs the galleries at urls.
+func crawl(ctx context.Context, urls []string, opts grabber.Options) error {
+	return run(opts, func(g *grabber.Grabber) error {
+		return crawlAll(ctx, g, urls)
+	})
+}
+
+// crawlAll crawls every url with g, going on after those that fail until
+// ctx is done.
+func crawlAll(ctx context.Context, g *grabber.Grabber, urls []string) error {
+	var err error
+	for _, url := range urls {
+		if ctx.Err() != nil {
+			break
+		}
+		if crawlErr := g.Crawl(ctx, url); crawlErr != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", url, crawlErr)
+			err = crawlErr
+		}
+	}
+	return err
+}
+
+// download fetches every url into opts.Dir.
+func download(ctx context.Context, urls []string, opts grabber.Options) error {
+	return run(opts, func(g *grabber.Grabber) error {
+		return g.Download(ctx, urls)
+	})
+}
+
+// run sets up the grabber and progress display around fn.
+func run(opts grabber.Options, fn func(*grabber.Grabber) error) error {
+	r := newRenderer(quiet)
+	g, err := newGrabber(opts, r)
+	if err != nil {
+		return err
+	}
+
+	g.OnSkip = func(url, reason string) {
+		r.Skip(url)
+		if reason == grabber.RobotsReason {
+			r.Printf("Skipped %s (%s)\n", url, reason)
+		}
+	}
+
+	var mu sync.Mutex
+	var estimated, unknown int
+	var estimatedBytes int64
+	if opts.DryRun {
+		g.OnQueue = nil
+		g.OnEstimate = func(e *grabber.Estimate) {
+			size := "unknown size"
+			mu.Lock()
+			estimated++
+			if e.Size >= 0 {
+				size = humanize.Bytes(uint64(e.Size))
+				estimatedBytes += e.Size
+			} else {
+				unknown++
+			}
+			mu.Unlock()
+			r.Printf("%s -> %s (%s)\n", e.URL, e.Path, size)
+		}
+		fmt.Println("Dry run, nothing will be written")
+	} else if !quiet {
+		fmt.Println("Download Started")
+	}
+
+	err = fn(g)
+	if closeErr := g.Close(); err == nil {
+		err = closeErr
+	}
+	r.Close()
+	if opts.DryRun {
+		fmt.Printf("Would download %d files, %s", estimated, humanize.Bytes(uint64(estimatedBytes)))
+		if unknown > 0 {
+			fmt.Printf(" plus %d of unknown size", unknown)
+		}
+		fmt.Println()
+	}
+	report := g.Report()
+	if !opts.DryRun && !quiet {
+		printReport(report)
+	}
+	printFailures(g.Failures())
+	if reportFile != "" {
+		if err := report.WriteFile(reportFile); err != nil {
+			return fmt.Errorf("report: %v", err)
+		}
+	}
+	var quotaErr *grabber.QuotaError
+	if errors.As(err, &quotaErr) && !grabber.IsRemote(opts.Dir) {
+		fmt.Fprintf(os.Stderr, "Stopped as %s, \"grab resume %s\" with a higher limit or more room goes on from here\n", quotaErr.Reason, opts.Dir)
+	}
+	if errors.Is(err, grabber.ErrCanceled) && !grabber.IsRemote(opts.Dir) {
+		fmt.Fprintf(os.Stderr, "Interrupted, \"grab resume %s\" goes on from here\n", opts.Dir)
+	}
+	if err != nil {
+		return err
+	}
+
+	if !opts.DryRun && !quiet {
+		fmt.Println("Grabbing completed!")
+	}
+
+	return nil
+}
+
+// printReport sums up the run.
+func printReport(rep grabber.Report) {
+	fmt.Println()
+	if rep.Pages > 0 {
+		fmt.Printf("Pages crawled:  %d\n", rep.Pages)
+	}
+	fmt.Printf("Images found:   %d\n", rep.Found)
+	fmt.Printf("Downloaded:     %d (%s)\n", rep.Downloaded, humanize.Bytes(uint64(rep.Bytes)))
+	if len(rep.Skipped) > 0 {
+		var total int
+		var reasons []string
+		for reason, n := range rep.Skipped {
+			total += n
+			reasons = append(reasons, fmt.Sprintf("%d %s", n, reason))
+		}
+		sort.Strings(reasons)
+		fmt.Printf("Skipped:        %d (%s)\n", total, strings.Join(reasons, ", "))
+	}
+	fmt.Printf("Failed:         %d\n", len(rep.Failed))
+	if len(rep.Added) > 0 || len(rep.Removed) > 0 {
+		fmt.Printf("New:            %d\n", len(rep.Added))
+		fmt.Printf("Gone:           %d\n", len(rep.Removed))
+		for _, u := range rep.Removed {
+			fmt.Printf("  %s\n", u)
+		}
+	}
+	fmt.Printf("Elapsed:        %s, %s/s\n", rep.Elapsed.Round(time.Millisecond), humanize.Bytes(uint64(rep.Throughput())))
+}
+
+// printFailures lists what couldn't be grabbed.
+func printFailures(failures []grabber.Failure) {
+	if len(failures) == 0 {
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "\n%d failed:\n", len(failures))
+	for _, f := range failures {
+		kind := "gave up"
+		switch {
+		case f.Corrupt():
+			kind = "corrupt"
+		case f.Permanent():
+			kind = "permanent"
+		}
+		fmt.Fprintf(os.Stderr, "  %s\n    %v (%s after %d attempts)\n", f.URL, f.Err, kind, f.Attempts)
+	}
+}
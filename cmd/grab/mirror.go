@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+
+	"github.com/d3z41k/image-grabber/pkg/grabber"
+)
+
+// runMirror makes the output directory match the galleries: what is new
+// is grabbed, what changed is grabbed again and, with -delete, what is
+// gone from the site is deleted.
+func runMirror(ctx context.Context, args []string) error {
+	opts := grabber.DefaultOptions()
+	urls, err := loadConfig(args, &opts)
+	if err != nil {
+		return err
+	}
+
+	fs := newFlagSet("mirror", "url...", &opts)
+	crawlFlags(fs, &opts)
+	fs.BoolVar(&opts.MirrorDelete, "delete", opts.MirrorDelete, "delete the files of the images gone from the site, as far as the manifest knows the page they were on")
+	fs.Parse(args)
+	opts.Mirror = true
+
+	if fs.NArg() > 0 {
+		urls = fs.Args()
+	}
+	if len(urls) == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if opts.MirrorDelete && !opts.Referer {
+		return errors.New("-delete needs -referer to know which page an image was on")
+	}
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+	// Resuming goes on with the mirror, the options say it is one
+	if err := saveState(state{Command: "crawl", Args: urls, Options: opts}); err != nil {
+		return err
+	}
+
+	return crawl(ctx, urls, opts)
+}
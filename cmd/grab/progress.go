@@ -0,0 +1,272 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/d3z41k/image-grabber/pkg/grabber"
+	"github.com/dustin/go-humanize"
+)
+
+const (
+	// refreshRate is how often the progress lines are redrawn.
+	refreshRate = 100 * time.Millisecond
+	// maxBars is the number of per-file bars shown at the same time.
+	maxBars  = 10
+	barWidth = 30
+	nameLen  = 30
+)
+
+// renderer draws a bar for every running download plus an overall summary
+// line. Downloads report concurrently, so all the state sits behind a mutex
+// and the lines are redrawn at a fixed rate instead of on every write.
+// When stdout isn't a terminal only finished files are printed, when quiet
+// nothing is.
+type renderer struct {
+	mu    sync.Mutex
+	out   io.Writer
+	log   io.Writer
+	tty   bool
+	quiet bool
+
+	start     time.Time
+	queued    int
+	done      int
+	doneBytes uint64
+	pending   map[string]bool
+	active    map[string]grabber.Progress
+	order     []string
+	drawn     int
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+func newRenderer(quiet bool) *renderer {
+	r := &renderer{
+		out:     os.Stdout,
+		log:     os.Stderr,
+		quiet:   quiet,
+		start:   time.Now(),
+		pending: make(map[string]bool),
+		active:  make(map[string]grabber.Progress),
+		stop:    make(chan struct{}),
+	}
+	r.tty = !quiet && isTerminal(os.Stdout)
+
+	if r.tty {
+		r.wg.Add(1)
+		go r.loop()
+	}
+
+	return r
+}
+
+func (r *renderer) loop() {
+	defer r.wg.Done()
+
+	t := time.NewTicker(refreshRate)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-t.C:
+			r.mu.Lock()
+			r.draw()
+			r.mu.Unlock()
+		case <-r.stop:
+			return
+		}
+	}
+}
+
+// Close draws the final state and stops redrawing.
+func (r *renderer) Close() {
+	close(r.stop)
+	r.wg.Wait()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.tty {
+		r.draw()
+	}
+}
+
+// Queue counts a url waiting to be downloaded.
+func (r *renderer) Queue(url string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.queued++
+	r.pending[url] = true
+}
+
+// Update records the progress of a download.
+func (r *renderer) Update(p grabber.Progress) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.active[p.URL]; !ok {
+		r.order = append(r.order, p.URL)
+	}
+	r.active[p.URL] = p
+
+	if p.Done {
+		r.remove(p.URL)
+		delete(r.pending, p.URL)
+		r.done++
+		r.doneBytes += p.Written
+		if !r.tty && !r.quiet {
+			fmt.Fprintf(r.out, "Downloaded %s (%s) [%d/%d]\n", filepath.Base(p.File), humanize.Bytes(p.Written), r.done, r.queued)
+		}
+	}
+}
+
+// Fail drops the bar of a download that failed for good.
+func (r *renderer) Fail(url string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.remove(url)
+	delete(r.pending, url)
+}
+
+// Skip drops a queued download that a filter rejected, so it no longer
+// counts towards the total.
+func (r *renderer) Skip(url string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.remove(url)
+	if r.pending[url] {
+		delete(r.pending, url)
+		r.queued--
+	}
+}
+
+func (r *renderer) remove(url string) {
+	delete(r.active, url)
+	for i, u := range r.order {
+		if u == url {
+			r.order = append(r.order[:i], r.order[i+1:]...)
+			break
+		}
+	}
+}
+
+// Printf prints a message above the progress lines.
+func (r *renderer) Printf(format string, args ...interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.clear()
+	fmt.Fprintf(r.out, format, args...)
+	if r.tty {
+		r.draw()
+	}
+}
+
+// Write prints log lines to stderr above the progress lines.
+func (r *renderer) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.clear()
+	n, err := r.log.Write(p)
+	if r.tty {
+		r.draw()
+	}
+	return n, err
+}
+
+// clear moves the cursor back up over the lines drawn last time.
+func (r *renderer) clear() {
+	for ; r.drawn > 0; r.drawn-- {
+		fmt.Fprint(r.out, "\033[1A\033[2K")
+	}
+}
+
+func (r *renderer) draw() {
+	r.clear()
+	if r.queued == 0 && len(r.order) == 0 {
+		return
+	}
+
+	var lines []string
+	for i, u := range r.order {
+		if i == maxBars {
+			lines = append(lines, fmt.Sprintf("  ... and %d more", len(r.order)-maxBars))
+			break
+		}
+		lines = append(lines, bar(r.active[u]))
+	}
+	lines = append(lines, r.overall())
+
+	for _, l := range lines {
+		fmt.Fprintln(r.out, l)
+	}
+	r.drawn = len(lines)
+}
+
+// overall renders e.g. "12/340 images, 1.2 GB, ETA 4m0s".
+func (r *renderer) overall() string {
+	written := r.doneBytes
+	expected := r.doneBytes
+	unknown := len(r.pending)
+	for _, p := range r.active {
+		written += p.Written
+		if p.Total > 0 {
+			expected += p.Total
+			unknown--
+		}
+	}
+
+	if unknown < 0 {
+		unknown = 0
+	}
+
+	eta := "?"
+	elapsed := time.Since(r.start)
+	if r.done > 0 && written > 0 && elapsed > 0 {
+		// Files whose size isn't known yet are assumed to be average
+		expected += uint64(unknown) * (r.doneBytes / uint64(r.done))
+		rate := float64(written) / elapsed.Seconds()
+		if expected > written {
+			eta = time.Duration(float64(expected-written) / rate * float64(time.Second)).Round(time.Second).String()
+		} else {
+			eta = "0s"
+		}
+	}
+
+	return fmt.Sprintf("%d/%d images, %s, ETA %s", r.done, r.queued, humanize.Bytes(written), eta)
+}
+
+// bar renders a single download, e.g. "photo.jpg  [=====>    ]  45%  1.2 MB / 2.6 MB".
+func bar(p grabber.Progress) string {
+	name := filepath.Base(p.File)
+	if len(name) > nameLen {
+		name = name[:nameLen-3] + "..."
+	}
+
+	if p.Total == 0 {
+		return fmt.Sprintf("%-*s  [%s]  %s", nameLen, name, strings.Repeat("?", barWidth), humanize.Bytes(p.Written))
+	}
+
+	frac := float64(p.Written) / float64(p.Total)
+	if frac > 1 {
+		frac = 1
+	}
+	filled := int(frac * barWidth)
+	b := strings.Repeat("=", filled)
+	if filled < barWidth {
+		b += ">" + strings.Repeat(" ", barWidth-filled-1)
+	}
+
+	return fmt.Sprintf("%-*s  [%s] %3.0f%%  %s / %s", nameLen, name, b, frac*100, humanize.Bytes(p.Written), humanize.Bytes(p.Total))
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/d3z41k/image-grabber/pkg/grabber"
+	"github.com/dustin/go-humanize"
+)
+
+func queryUsage() {
+	fmt.Fprint(os.Stderr, `usage: grab query <command> [flags]
+
+commands:
+  search  list the images matching the flags, the last grabbed first
+  stats   count the images and bytes, by host
+  dups    list the urls that had the same content
+
+Run "grab query <command> -h" for the flags of a command.
+`)
+}
+
+// runQuery searches the catalog written by -catalog.
+func runQuery(args []string) error {
+	if len(args) == 0 {
+		queryUsage()
+		os.Exit(2)
+	}
+
+	cmd, args := args[0], args[1:]
+	fs := flag.NewFlagSet("query "+cmd, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: grab query %s [flags]\n\nflags:\n", cmd)
+		fs.PrintDefaults()
+	}
+	catalog := fs.String("catalog", "catalog.db", "the SQLite `file` written by -catalog")
+	asJSON := fs.Bool("json", false, "print JSON instead of a table")
+
+	var q grabber.CatalogQuery
+	var since, until string
+	switch cmd {
+	case "search":
+		fs.StringVar(&q.Text, "text", "", "only images whose url, page or path contain this `text`")
+		fs.StringVar(&q.Host, "host", "", "only images from this `host` or its subdomains")
+		fs.StringVar(&q.Camera, "camera", "", "only photos whose camera make or model contain this `text`")
+		fs.StringVar(&q.ContentType, "type", "", "only files whose content type starts with this, e.g. image/png")
+		fs.IntVar(&q.MinWidth, "min-width", 0, "only images at least this many `pixels` wide")
+		fs.IntVar(&q.MinHeight, "min-height", 0, "only images at least this many `pixels` high")
+		fs.StringVar(&since, "since", "", "only images grabbed since this `date`, as 2006-01-02 or RFC 3339")
+		fs.StringVar(&until, "until", "", "only images grabbed before this `date`")
+		fs.IntVar(&q.Limit, "limit", 0, "list at most this many images, 0 for all")
+	case "stats", "dups":
+	case "help", "-h", "-help", "--help":
+		queryUsage()
+		return nil
+	default:
+		fmt.Fprintf(os.Stderr, "grab: unknown query command %q\n\n", cmd)
+		queryUsage()
+		os.Exit(2)
+	}
+	fs.Parse(args)
+	// The text can be given as argument too
+	if q.Text == "" && fs.NArg() > 0 {
+		q.Text = strings.Join(fs.Args(), " ")
+	}
+
+	var err error
+	if q.Since, err = parseDate(since); err != nil {
+		return fmt.Errorf("-since: %v", err)
+	}
+	if q.Until, err = parseDate(until); err != nil {
+		return fmt.Errorf("-until: %v", err)
+	}
+
+	if _, err := os.Stat(*catalog); err != nil {
+		return fmt.Errorf("catalog: %v", err)
+	}
+	c, err := grabber.OpenCatalog(*catalog)
+	if err != nil {
+		return fmt.Errorf("catalog: %v", err)
+	}
+	defer c.Close()
+
+	switch cmd {
+	case "search":
+		entries, err := c.Search(q)
+		if err != nil {
+			return err
+		}
+		if *asJSON {
+			if entries == nil {
+				entries = []grabber.CatalogEntry{}
+			}
+			return printJSON(entries)
+		}
+		printEntries(entries)
+	case "stats":
+		stats, err := c.Stats()
+		if err != nil {
+			return err
+		}
+		if *asJSON {
+			return printJSON(stats)
+		}
+		fmt.Printf("Images:  %d (%s)\n", stats.Images, humanize.Bytes(uint64(stats.Bytes)))
+		fmt.Printf("Unique:  %d\n", stats.Unique)
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		for _, h := range stats.Hosts {
+			fmt.Fprintf(w, "  %s\t%d\n", h.Host, h.Images)
+		}
+		w.Flush()
+	case "dups":
+		groups, err := c.Duplicates()
+		if err != nil {
+			return err
+		}
+		if *asJSON {
+			if groups == nil {
+				groups = [][]grabber.CatalogEntry{}
+			}
+			return printJSON(groups)
+		}
+		for _, g := range groups {
+			fmt.Printf("%s (%s)\n", g[0].SHA256, humanize.Bytes(uint64(g[0].Size)))
+			for _, e := range g {
+				fmt.Printf("  %s\n", e.URL)
+			}
+		}
+	}
+
+	return nil
+}
+
+// parseDate parses a date or RFC 3339 time, "" is the zero time.
+func parseDate(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+// printEntries prints catalog entries as a table.
+func printEntries(entries []grabber.CatalogEntry) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "PATH\tSIZE\tPIXELS\tCAMERA\tTAKEN\tURL")
+	for _, e := range entries {
+		pixels := ""
+		if e.Width > 0 {
+			pixels = fmt.Sprintf("%dx%d", e.Width, e.Height)
+		}
+		taken := ""
+		if !e.Taken.IsZero() {
+			taken = e.Taken.Format("2006-01-02 15:04")
+		}
+		camera := strings.TrimSpace(e.Make + " " + e.Model)
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", e.Path, humanize.Bytes(uint64(e.Size)), pixels, camera, taken, e.URL)
+	}
+	w.Flush()
+}
+
+func printJSON(v interface{}) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
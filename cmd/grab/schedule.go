@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/d3z41k/image-grabber/pkg/grabber"
+	"github.com/dustin/go-humanize"
+)
+
+// runSchedule runs the jobs of a jobs file on their schedules until it is
+// interrupted, or prints how they fared with -status.
+func runSchedule(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("schedule", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: grab schedule [flags] jobs.yaml\n\nflags:\n")
+		fs.PrintDefaults()
+	}
+	statusFile := fs.String("status-file", "", "`file` the status of the jobs is written to, the jobs file with .status.json by default")
+	status := fs.Bool("status", false, "print the status of the jobs of a running or past schedule and exit")
+	fs.BoolVar(&quiet, "quiet", quiet, "only log errors")
+	fs.BoolVar(&verbose, "verbose", verbose, "log every page and request")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	path := fs.Arg(0)
+	if *statusFile == "" {
+		*statusFile = strings.TrimSuffix(path, filepath.Ext(path)) + ".status.json"
+	}
+
+	if *status {
+		list, err := grabber.ReadJobStatus(*statusFile)
+		if err != nil {
+			return err
+		}
+		printJobStatus(list)
+		return nil
+	}
+
+	jobs, err := grabber.LoadJobs(path, grabber.DefaultOptions())
+	if err != nil {
+		return err
+	}
+
+	// Starting and finishing jobs is what there is to see
+	level := slog.LevelInfo
+	if quiet || verbose {
+		level = logLevel()
+	}
+	s := &grabber.Scheduler{
+		Jobs:       jobs,
+		StatusFile: *statusFile,
+		Logger:     slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level})),
+	}
+	fmt.Printf("Scheduling %d jobs, status in %s\n", len(jobs), *statusFile)
+	return s.Run(ctx)
+}
+
+// printJobStatus prints the status of jobs as a table.
+func printJobStatus(list []grabber.JobStatus) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "JOB\tSCHEDULE\tSTATE\tRUNS\tLAST RUN\tDOWNLOADED\tFAILED\tNEXT")
+	for _, st := range list {
+		state := "idle"
+		switch {
+		case st.Running:
+			state = "running"
+		case st.LastError != "":
+			state = "failed"
+		}
+		last := "never"
+		if !st.LastStart.IsZero() {
+			last = st.LastStart.Format(time.DateTime)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%d (%s)\t%d\t%s\n", st.Name, st.Schedule, state, st.Runs, last,
+			st.Downloaded, humanize.Bytes(uint64(st.Bytes)), st.Failed, st.Next.Format(time.DateTime))
+	}
+	w.Flush()
+
+	for _, st := range list {
+		if st.LastError != "" {
+			fmt.Printf("%s: %s\n", st.Name, st.LastError)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+
+	"github.com/d3z41k/image-grabber/pkg/grabber"
+	"google.golang.org/grpc"
+)
+
+// runServe runs grab as a daemon taking jobs over a REST API and its
+// dashboard, the flags being the options the jobs start from.
+func runServe(ctx context.Context, args []string) error {
+	opts := grabber.DefaultOptions()
+	opts.Dir = "jobs"
+	if _, err := loadConfig(args, &opts); err != nil {
+		return err
+	}
+
+	fs := newFlagSet("serve", "", &opts)
+	crawlFlags(fs, &opts)
+	listen := fs.String("listen", "127.0.0.1:8080", "`address` to listen on")
+	token := fs.String("token", os.Getenv("GRAB_TOKEN"), "bearer `token` requests need, $GRAB_TOKEN by default")
+	grpcListen := fs.String("grpc-listen", "", "`address` to serve the gRPC API on, none by default")
+	running := fs.Int("max-jobs", 1, "number of jobs running at the same time")
+	fs.Parse(args)
+
+	if fs.NArg() > 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if grabber.IsRemote(opts.Dir) {
+		return errors.New("the output of serve must be a directory")
+	}
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(opts.Dir, 0700); err != nil {
+		return err
+	}
+
+	level := slog.LevelInfo
+	if quiet || verbose {
+		level = logLevel()
+	}
+	s := &grabber.Server{
+		Root:       opts.Dir,
+		Defaults:   opts,
+		MaxRunning: *running,
+		Token:      *token,
+		Logger:     slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level})),
+	}
+	srv := &http.Server{Addr: *listen, Handler: s}
+
+	var grpcSrv *grpc.Server
+	if *grpcListen != "" {
+		l, err := net.Listen("tcp", *grpcListen)
+		if err != nil {
+			return err
+		}
+		grpcSrv = s.GRPC()
+		go grpcSrv.Serve(l)
+		fmt.Printf("Serving the gRPC API on %s\n", *grpcListen)
+	}
+	go func() {
+		<-ctx.Done()
+		srv.Shutdown(context.Background())
+		if grpcSrv != nil {
+			grpcSrv.Stop()
+		}
+	}()
+
+	fmt.Printf("Serving jobs on http://%s, writing to %s\n", *listen, opts.Dir)
+	if *token == "" {
+		fmt.Println("There is no -token, anybody reaching the address can start jobs")
+	}
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	fmt.Println("Canceling the running jobs")
+	s.Close()
+	return nil
+}
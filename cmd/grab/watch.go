@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/d3z41k/image-grabber/pkg/grabber"
+)
+
+// runWatch crawls the galleries again every -interval, downloading only
+// the images the manifest doesn't have yet, until it is interrupted.
+func runWatch(ctx context.Context, args []string) error {
+	opts := grabber.DefaultOptions()
+	urls, err := loadConfig(args, &opts)
+	if err != nil {
+		return err
+	}
+
+	fs := newFlagSet("watch", "url...", &opts)
+	crawlFlags(fs, &opts)
+	interval := fs.Duration("interval", time.Hour, "time between the starts of two crawls")
+	fs.Parse(args)
+
+	if fs.NArg() > 0 {
+		urls = fs.Args()
+	}
+	if len(urls) == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if *interval <= 0 {
+		return errors.New("interval must be positive")
+	}
+	if opts.Manifest == "" {
+		return errors.New("watch needs a manifest to tell the new images apart")
+	}
+	if opts.DryRun {
+		return errors.New("watch can't be a dry run")
+	}
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+	// An interrupted round is resumed like a crawl
+	if err := saveState(state{Command: "crawl", Args: urls, Options: opts}); err != nil {
+		return err
+	}
+
+	for round := 1; ; round++ {
+		start := time.Now()
+		var rep grabber.Report
+		err := run(opts, func(g *grabber.Grabber) error {
+			defer func() { rep = g.Report() }()
+			return crawlAll(ctx, g, urls)
+		})
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		// A full disk or quota won't get better by waiting
+		var quotaErr *grabber.QuotaError
+		if errors.As(err, &quotaErr) {
+			return err
+		}
+
+		next := start.Add(*interval)
+		fmt.Printf("Round %d at %s: %d new images, %d failed", round, start.Format(time.DateTime), rep.Downloaded, len(rep.Failed))
+		if err != nil {
+			fmt.Printf(" (%v)", err)
+		}
+		fmt.Printf(", next at %s\n", next.Format(time.DateTime))
+
+		select {
+		case <-time.After(time.Until(next)):
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package grabber
+
+import (
+	"context"
+	"errors"
+
+	"github.com/chromedp/cdproto/fetch"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+)
+
+// The kinds of requests BrowserOptions.Block can keep chrome from making.
+const (
+	BlockFont       = "font"
+	BlockStylesheet = "stylesheet"
+	BlockMedia      = "media"
+	BlockAnalytics  = "analytics"
+	BlockAds        = "ads"
+)
+
+// blockTypes are the resource types of the kinds blocked by type.
+var blockTypes = map[string]network.ResourceType{
+	BlockFont:       network.ResourceTypeFont,
+	BlockStylesheet: network.ResourceTypeStylesheet,
+	BlockMedia:      network.ResourceTypeMedia,
+}
+
+// blockURLs are the url patterns of the kinds blocked by where they go,
+// the hosts of the common trackers and ad networks.
+var blockURLs = map[string][]string{
+	BlockAnalytics: {
+		"*google-analytics.com/*",
+		"*googletagmanager.com/*",
+		"*connect.facebook.net/*",
+		"*hotjar.com/*",
+		"*clarity.ms/*",
+		"*scorecardresearch.com/*",
+		"*quantserve.com/*",
+		"*mixpanel.com/*",
+		"*segment.com/*",
+		"*segment.io/*",
+		"*newrelic.com/*",
+		"*nr-data.net/*",
+	},
+	BlockAds: {
+		"*doubleclick.net/*",
+		"*googlesyndication.com/*",
+		"*googleadservices.com/*",
+		"*adservice.google.com/*",
+		"*amazon-adsystem.com/*",
+		"*adnxs.com/*",
+		"*criteo.com/*",
+		"*criteo.net/*",
+		"*taboola.com/*",
+		"*outbrain.com/*",
+		"*pubmatic.com/*",
+		"*rubiconproject.com/*",
+		"*exoclick.com/*",
+		"*juicyads.com/*",
+	},
+}
+
+// validateBlock checks that Block only names kinds of requests there are.
+func (o BrowserOptions) validateBlock() error {
+	for _, kind := range o.Block {
+		if _, ok := blockTypes[kind]; ok {
+			continue
+		}
+		if _, ok := blockURLs[kind]; ok {
+			continue
+		}
+		return errors.New("unknown kind of request to block " + kind + ", want font, stylesheet, media, analytics or ads")
+	}
+	return nil
+}
+
+// blockPatterns returns the fetch patterns pausing the requests of the
+// kinds in Block.
+func (o BrowserOptions) blockPatterns() []*fetch.RequestPattern {
+	var patterns []*fetch.RequestPattern
+	for _, kind := range o.Block {
+		if t, ok := blockTypes[kind]; ok {
+			patterns = append(patterns, &fetch.RequestPattern{
+				URLPattern:   "*",
+				ResourceType: t,
+				RequestStage: fetch.RequestStageRequest,
+			})
+		}
+		for _, u := range blockURLs[kind] {
+			patterns = append(patterns, &fetch.RequestPattern{
+				URLPattern:   u,
+				RequestStage: fetch.RequestStageRequest,
+			})
+		}
+	}
+	return patterns
+}
+
+// blockActions make the tab of ctx fail the requests of the kinds in
+// Block as if an ad blocker stopped them. Only those requests are paused
+// by the fetch domain, so every paused request is one to fail.
+func (o BrowserOptions) blockActions(ctx context.Context) []chromedp.Action {
+	patterns := o.blockPatterns()
+	if len(patterns) == 0 {
+		return nil
+	}
+
+	chromedp.ListenTarget(ctx, func(ev interface{}) {
+		e, ok := ev.(*fetch.EventRequestPaused)
+		if !ok {
+			return
+		}
+		// Listeners must not block, the reply goes out on its own
+		go chromedp.Run(ctx, fetch.FailRequest(e.RequestID, network.ErrorReasonBlockedByClient))
+	})
+	return []chromedp.Action{fetch.Enable().WithPatterns(patterns)}
+}
@@ -0,0 +1,209 @@
+package grabber
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sync"
+
+	"github.com/chromedp/cdproto/emulation"
+	"github.com/chromedp/cdproto/security"
+	"github.com/chromedp/chromedp"
+)
+
+// BrowserOptions configure the chrome instance used to render pages.
+type BrowserOptions struct {
+	// Headless runs chrome without a window. Turn it off to watch what
+	// the extraction does.
+	Headless bool `json:"headless" yaml:"headless" toml:"headless"`
+	// ProxyServer is passed to chrome as --proxy-server, e.g.
+	// http://proxy.example.com:3128 or socks5://localhost:1080.
+	ProxyServer string `json:"proxy_server,omitempty" yaml:"proxy_server" toml:"proxy_server"`
+	// WindowSize is the size of the browser window as WIDTHxHEIGHT.
+	WindowSize string `json:"window_size,omitempty" yaml:"window_size" toml:"window_size"`
+	// RemoteURL is the DevTools endpoint of a running chrome to use instead
+	// of starting one, as ws://host:9222/devtools/browser/ID or just
+	// http://host:9222, e.g. of a browserless container. Headless and
+	// ProxyServer are then up to that chrome, the window size and user
+	// agent are set for every tab.
+	RemoteURL string `json:"remote_url,omitempty" yaml:"remote_url" toml:"remote_url"`
+	// Profile adds the chrome flags for where chrome runs, ChromeContainer
+	// for Docker and CI. Empty is ChromeDefault.
+	Profile ChromeProfile `json:"profile,omitempty" yaml:"profile" toml:"profile"`
+	// Block keeps chrome from loading these kinds of requests, to render
+	// heavy pages faster: font, stylesheet, media, analytics or ads.
+	Block []string `json:"block,omitempty" yaml:"block" toml:"block"`
+}
+
+// validate checks the options for values that can't work.
+func (o BrowserOptions) validate() error {
+	if _, _, err := o.windowSize(); err != nil {
+		return err
+	}
+	if err := new(ChromeProfile).Set(string(o.Profile)); err != nil {
+		return err
+	}
+	if err := o.validateBlock(); err != nil {
+		return err
+	}
+	if o.RemoteURL != "" {
+		u, err := url.Parse(o.RemoteURL)
+		if err != nil || u.Host == "" || u.Scheme != "ws" && u.Scheme != "wss" && u.Scheme != "http" && u.Scheme != "https" {
+			return fmt.Errorf("remote debugging url %q is not a ws, wss, http or https url", o.RemoteURL)
+		}
+	}
+	return nil
+}
+
+// windowSize parses WindowSize, returning 0, 0 if it is unset.
+func (o BrowserOptions) windowSize() (int, int, error) {
+	if o.WindowSize == "" {
+		return 0, 0, nil
+	}
+
+	var w, h int
+	if _, err := fmt.Sscanf(o.WindowSize, "%dx%d", &w, &h); err != nil || w <= 0 || h <= 0 {
+		return 0, 0, fmt.Errorf("bad window size %q, want WIDTHxHEIGHT", o.WindowSize)
+	}
+	return w, h, nil
+}
+
+// allocatorOptions turns the options into chrome command line flags,
+// replacing the chrome user agent if userAgent is set.
+func (o BrowserOptions) allocatorOptions(userAgent string) []chromedp.ExecAllocatorOption {
+	opts := append([]chromedp.ExecAllocatorOption{}, chromedp.DefaultExecAllocatorOptions[:]...)
+	opts = append(opts, o.Profile.allocatorOptions()...)
+	if !o.Headless {
+		opts = append(opts, chromedp.Flag("headless", false))
+	}
+	if o.ProxyServer != "" {
+		opts = append(opts, chromedp.ProxyServer(o.ProxyServer))
+	}
+	if userAgent != "" {
+		opts = append(opts, chromedp.UserAgent(userAgent))
+	}
+	if w, h, err := o.windowSize(); err == nil && w > 0 {
+		opts = append(opts, chromedp.WindowSize(w, h))
+	}
+	return opts
+}
+
+// browser is a chrome instance shared by all the pages a Collector renders.
+// It is started on first use, every page gets its own tab in it.
+type browser struct {
+	opts      BrowserOptions
+	userAgent string
+	// insecure makes the tabs accept any certificate.
+	insecure bool
+	// resolverRules are the --host-resolver-rules of a chrome of our own.
+	resolverRules string
+
+	mu          sync.Mutex
+	ctx         context.Context
+	cancel      context.CancelFunc
+	cancelAlloc context.CancelFunc
+}
+
+// tab opens a new tab, starting the browser if it isn't running yet or
+// starting it again if it crashed. The tab is closed by the returned cancel
+// func or once ctx is done, while the browser lives on until Close.
+func (b *browser) tab(ctx context.Context) (context.Context, context.CancelFunc, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.ctx != nil && !b.alive() {
+		b.cancel()
+		b.cancelAlloc()
+		b.ctx, b.cancel, b.cancelAlloc = nil, nil, nil
+	}
+	if b.ctx == nil {
+		var allocCtx context.Context
+		var cancelAlloc context.CancelFunc
+		if b.opts.RemoteURL != "" {
+			allocCtx, cancelAlloc = chromedp.NewRemoteAllocator(context.Background(), b.opts.RemoteURL)
+		} else {
+			opts := b.opts.allocatorOptions(b.userAgent)
+			if b.resolverRules != "" {
+				opts = append(opts, chromedp.Flag("host-resolver-rules", b.resolverRules))
+			}
+			allocCtx, cancelAlloc = chromedp.NewExecAllocator(context.Background(), opts...)
+		}
+		ctx, cancel := chromedp.NewContext(allocCtx)
+		// Running no actions starts chrome with a blank first tab, which
+		// keeps the browser alive until Close.
+		if err := chromedp.Run(ctx); err != nil {
+			cancel()
+			cancelAlloc()
+			return nil, nil, err
+		}
+		b.ctx, b.cancel, b.cancelAlloc = ctx, cancel, cancelAlloc
+	}
+
+	tabCtx, cancel := chromedp.NewContext(b.ctx)
+	stop := context.AfterFunc(ctx, cancel)
+	actions := append(b.remoteActions(), b.opts.blockActions(tabCtx)...)
+	if b.insecure {
+		actions = append(actions, security.SetIgnoreCertificateErrors(true))
+	}
+	if len(actions) > 0 {
+		if err := chromedp.Run(tabCtx, actions...); err != nil {
+			stop()
+			cancel()
+			return nil, nil, err
+		}
+	}
+	return tabCtx, func() {
+		stop()
+		cancel()
+	}, nil
+}
+
+// remoteActions set up a tab of a remote chrome the way the flags of
+// allocatorOptions set up a chrome of our own.
+func (b *browser) remoteActions() []chromedp.Action {
+	if b.opts.RemoteURL == "" {
+		return nil
+	}
+	var actions []chromedp.Action
+	if b.userAgent != "" {
+		actions = append(actions, emulation.SetUserAgentOverride(b.userAgent))
+	}
+	if w, h, err := b.opts.windowSize(); err == nil && w > 0 {
+		actions = append(actions, emulation.SetDeviceMetricsOverride(int64(w), int64(h), 1, false))
+	}
+	return actions
+}
+
+// alive reports whether the browser is still connected, as it isn't once
+// chrome crashed or was killed.
+func (b *browser) alive() bool {
+	if b.ctx.Err() != nil {
+		return false
+	}
+	c := chromedp.FromContext(b.ctx)
+	if c == nil || c.Browser == nil {
+		return false
+	}
+	select {
+	case <-c.Browser.LostConnection:
+		return false
+	default:
+		return true
+	}
+}
+
+// Close shuts the browser down. It is started again by the next tab.
+func (b *browser) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.cancel != nil {
+		b.cancel()
+		b.cancelAlloc()
+		b.ctx, b.cancel, b.cancelAlloc = nil, nil, nil
+	}
+}
@@ -0,0 +1,331 @@
+package grabber
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha1"
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/pbkdf2"
+	_ "modernc.org/sqlite"
+)
+
+// CookieBrowser is a browser whose cookies a run starts out with.
+type CookieBrowser string
+
+const (
+	// CookiesChrome reads the cookies of the Default profile of Google
+	// Chrome, decrypting them with the key of the system keyring.
+	CookiesChrome CookieBrowser = "chrome"
+	// CookiesFirefox reads the cookies of the Firefox profile used last.
+	CookiesFirefox CookieBrowser = "firefox"
+)
+
+// Set implements flag.Value.
+func (b *CookieBrowser) Set(v string) error {
+	switch CookieBrowser(v) {
+	case "", CookiesChrome, CookiesFirefox:
+		*b = CookieBrowser(v)
+		return nil
+	}
+	return fmt.Errorf("unknown browser %q, want chrome or firefox", v)
+}
+
+func (b CookieBrowser) String() string {
+	return string(b)
+}
+
+// readBrowserCookies returns the cookies browser has for domain and its
+// subdomains. The cookie store is copied first, as the browser keeps it
+// locked while it runs.
+func readBrowserCookies(browser CookieBrowser, domain string) ([]hostCookie, error) {
+	var path string
+	var err error
+	switch browser {
+	case CookiesChrome:
+		path, err = chromeCookieStore()
+	case CookiesFirefox:
+		path, err = firefoxCookieStore()
+	default:
+		return nil, fmt.Errorf("unknown browser %q", browser)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	tmp, err := os.MkdirTemp("", "grab-cookies-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+	copied := filepath.Join(tmp, "cookies.sqlite")
+	// The write ahead log holds the cookies set since the last checkpoint
+	for _, suffix := range []string{"", "-wal"} {
+		if err := copyFile(path+suffix, copied+suffix); err != nil && (suffix == "" || !errors.Is(err, os.ErrNotExist)) {
+			return nil, err
+		}
+	}
+
+	db, err := sql.Open("sqlite", copied)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	if browser == CookiesFirefox {
+		return firefoxCookies(db, domain)
+	}
+	return chromeStoreCookies(db, domain)
+}
+
+// copyFile copies the file at src to dst.
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// chromeCookieStore returns the path of the cookie database of the Default
+// profile of Chrome, which moved into Network in Chrome 96.
+func chromeCookieStore() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	switch runtime.GOOS {
+	case "linux":
+		dir = filepath.Join(dir, "google-chrome", "Default")
+	case "darwin":
+		dir = filepath.Join(dir, "Google", "Chrome", "Default")
+	default:
+		return "", fmt.Errorf("can't read the chrome cookies on %s, export them to a cookies file instead", runtime.GOOS)
+	}
+	for _, path := range []string{filepath.Join(dir, "Network", "Cookies"), filepath.Join(dir, "Cookies")} {
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("no chrome cookies in %s", dir)
+}
+
+// firefoxCookieStore returns the path of the cookie database of the
+// Firefox profile whose cookies changed last.
+func firefoxCookieStore() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	switch runtime.GOOS {
+	case "darwin":
+		dir = filepath.Join(dir, "Firefox", "Profiles")
+	case "windows":
+		dir = filepath.Join(dir, "Mozilla", "Firefox", "Profiles")
+	default:
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(home, ".mozilla", "firefox")
+	}
+
+	paths, _ := filepath.Glob(filepath.Join(dir, "*", "cookies.sqlite"))
+	var last string
+	var lastMod time.Time
+	for _, path := range paths {
+		if fi, err := os.Stat(path); err == nil && fi.ModTime().After(lastMod) {
+			last, lastMod = path, fi.ModTime()
+		}
+	}
+	if last == "" {
+		return "", fmt.Errorf("no firefox profile with cookies in %s", dir)
+	}
+	return last, nil
+}
+
+// firefoxCookies reads the cookies of domain from the moz_cookies table.
+func firefoxCookies(db *sql.DB, domain string) ([]hostCookie, error) {
+	rows, err := db.Query(`SELECT host, name, value, path, expiry, isSecure, isHttpOnly FROM moz_cookies
+		WHERE host = ? OR host LIKE ?`, domain, "%."+domain)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var cookies []hostCookie
+	for rows.Next() {
+		var host string
+		var expiry int64
+		c := &http.Cookie{}
+		if err := rows.Scan(&host, &c.Name, &c.Value, &c.Path, &expiry, &c.Secure, &c.HttpOnly); err != nil {
+			return nil, err
+		}
+		// Newer versions of Firefox store milliseconds instead of seconds
+		if expiry > 1e11 {
+			c.Expires = time.UnixMilli(expiry)
+		} else if expiry > 0 {
+			c.Expires = time.Unix(expiry, 0)
+		}
+		c.Domain = host
+		cookies = append(cookies, newHostCookie(c, !strings.HasPrefix(host, ".")))
+	}
+	return cookies, rows.Err()
+}
+
+// chromeEpoch is the start of the Windows epoch chrome counts expiry
+// times from, in seconds before the Unix one.
+const chromeEpoch = 11644473600
+
+// chromeStoreCookies reads the cookies of domain from the cookies table of
+// a chrome cookie database, decrypting their values.
+func chromeStoreCookies(db *sql.DB, domain string) ([]hostCookie, error) {
+	// From version 24 the values start with the hash of their host
+	var version int
+	var v string
+	if err := db.QueryRow(`SELECT value FROM meta WHERE key = 'version'`).Scan(&v); err == nil {
+		version, _ = strconv.Atoi(v)
+	}
+
+	rows, err := db.Query(`SELECT host_key, name, value, encrypted_value, path, expires_utc, is_secure, is_httponly FROM cookies
+		WHERE host_key = ? OR host_key LIKE ?`, domain, "%."+domain)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys chromeKeys
+	var cookies []hostCookie
+	for rows.Next() {
+		var host string
+		var encrypted []byte
+		var expires int64
+		c := &http.Cookie{}
+		if err := rows.Scan(&host, &c.Name, &c.Value, &encrypted, &c.Path, &expires, &c.Secure, &c.HttpOnly); err != nil {
+			return nil, err
+		}
+		if c.Value == "" && len(encrypted) > 0 {
+			value, err := keys.decrypt(encrypted)
+			if err != nil {
+				return nil, fmt.Errorf("cookie %s of %s: %v", c.Name, host, err)
+			}
+			if sum := sha256.Sum256([]byte(host)); version >= 24 && bytes.HasPrefix(value, sum[:]) {
+				value = value[len(sum):]
+			}
+			c.Value = string(value)
+		}
+		if expires > 0 {
+			c.Expires = time.Unix(expires/1e6-chromeEpoch, 0)
+		}
+		c.Domain = host
+		cookies = append(cookies, newHostCookie(c, !strings.HasPrefix(host, ".")))
+	}
+	return cookies, rows.Err()
+}
+
+// chromeKeys derives the keys chrome encrypts the cookie values with, from
+// the password of its "Safe Storage" keyring entry, once needed.
+type chromeKeys struct {
+	v10, v11 []byte
+}
+
+// decrypt decrypts a cookie value encrypted by chrome on Linux or macOS:
+// AES-128-CBC behind a v10 or v11 prefix.
+func (k *chromeKeys) decrypt(encrypted []byte) ([]byte, error) {
+	var key []byte
+	switch {
+	case bytes.HasPrefix(encrypted, []byte("v10")):
+		if k.v10 == nil {
+			password := "peanuts"
+			iterations := 1
+			if runtime.GOOS == "darwin" {
+				out, err := exec.Command("security", "find-generic-password", "-w", "-s", "Chrome Safe Storage").Output()
+				if err != nil {
+					return nil, fmt.Errorf("reading the chrome key from the keychain: %v", err)
+				}
+				password, iterations = strings.TrimSpace(string(out)), 1003
+			}
+			k.v10 = pbkdf2.Key([]byte(password), []byte("saltysalt"), iterations, 16, sha1.New)
+		}
+		key = k.v10
+	case bytes.HasPrefix(encrypted, []byte("v11")):
+		if k.v11 == nil {
+			out, err := exec.Command("secret-tool", "lookup", "application", "chrome").Output()
+			if err != nil {
+				return nil, fmt.Errorf("reading the chrome key from the keyring: %v", err)
+			}
+			k.v11 = pbkdf2.Key(bytes.TrimSpace(out), []byte("saltysalt"), 1, 16, sha1.New)
+		}
+		key = k.v11
+	default:
+		return nil, errors.New("unknown encryption")
+	}
+
+	data := encrypted[3:]
+	if len(data) == 0 || len(data)%aes.BlockSize != 0 {
+		return nil, errors.New("bad encrypted value")
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	value := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, bytes.Repeat([]byte{' '}, aes.BlockSize)).CryptBlocks(value, data)
+	pad := int(value[len(value)-1])
+	if pad == 0 || pad > aes.BlockSize || pad > len(value) {
+		return nil, errors.New("wrong key")
+	}
+	return value[:len(value)-pad], nil
+}
+
+// browserCookies adds the cookies Options.CookiesFromBrowser has for the
+// registered domains of urls to the jar, once per domain.
+func (g *Grabber) browserCookies(urls ...string) error {
+	if g.Options.CookiesFromBrowser == "" || g.Collector.Jar == nil {
+		return nil
+	}
+	for _, s := range urls {
+		u, err := url.Parse(s)
+		if err != nil || u.Hostname() == "" {
+			continue
+		}
+		domain := registeredDomain(u.Hostname())
+		if g.cookieDomains[domain] {
+			continue
+		}
+		cookies, err := readBrowserCookies(g.Options.CookiesFromBrowser, domain)
+		if err != nil {
+			return fmt.Errorf("cookies from %s: %v", g.Options.CookiesFromBrowser, err)
+		}
+		if g.cookieDomains == nil {
+			g.cookieDomains = make(map[string]bool)
+		}
+		g.cookieDomains[domain] = true
+		setCookies(g.Collector.Jar, cookies)
+		g.log().Info("read browser cookies", "browser", g.Options.CookiesFromBrowser, "domain", domain, "cookies", len(cookies))
+	}
+	return nil
+}
@@ -0,0 +1,266 @@
+package grabber
+
+import (
+	"database/sql"
+	"net/url"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// catalogSchema creates the table of a catalog.
+const catalogSchema = `
+CREATE TABLE IF NOT EXISTS images (
+	url          TEXT PRIMARY KEY,
+	page         TEXT NOT NULL DEFAULT '',
+	host         TEXT NOT NULL DEFAULT '',
+	path         TEXT NOT NULL,
+	size         INTEGER NOT NULL,
+	sha256       TEXT NOT NULL DEFAULT '',
+	content_type TEXT NOT NULL DEFAULT '',
+	width        INTEGER NOT NULL DEFAULT 0,
+	height       INTEGER NOT NULL DEFAULT 0,
+	make         TEXT NOT NULL DEFAULT '',
+	model        TEXT NOT NULL DEFAULT '',
+	lens         TEXT NOT NULL DEFAULT '',
+	taken        TEXT NOT NULL DEFAULT '',
+	lat          REAL,
+	lon          REAL,
+	grabbed      TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS images_sha256 ON images (sha256);
+CREATE INDEX IF NOT EXISTS images_host ON images (host);
+`
+
+// catalogColumns are the columns a CatalogEntry is scanned from.
+const catalogColumns = `url, page, host, path, size, sha256, content_type, width, height,
+	make, model, lens, taken, lat, lon, grabbed`
+
+// CatalogEntry is an image recorded in a catalog.
+type CatalogEntry struct {
+	URL         string `json:"url"`
+	Page        string `json:"page,omitempty"`
+	Host        string `json:"host"`
+	Path        string `json:"path"`
+	Size        int64  `json:"size"`
+	SHA256      string `json:"sha256,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	Width       int    `json:"width,omitempty"`
+	Height      int    `json:"height,omitempty"`
+	Exif
+	// Grabbed is when the image was last downloaded.
+	Grabbed time.Time `json:"grabbed"`
+}
+
+// CatalogQuery says which entries Catalog.Search returns. Zero fields
+// match everything.
+type CatalogQuery struct {
+	// Text is looked for in the url, page and path.
+	Text string
+	Host string
+	// Camera is looked for in the camera make and model.
+	Camera string
+	// ContentType is a prefix of the content type, like image/ or
+	// image/png.
+	ContentType string
+	MinWidth    int
+	MinHeight   int
+	// Since and Until bound when the images were grabbed.
+	Since time.Time
+	Until time.Time
+	// Limit is the most entries returned, 0 for all.
+	Limit int
+}
+
+// CatalogStats sums up a catalog.
+type CatalogStats struct {
+	Images int   `json:"images"`
+	Bytes  int64 `json:"bytes"`
+	// Unique is the number of different contents.
+	Unique int `json:"unique"`
+	// Hosts are the number of images by host, most first.
+	Hosts []HostCount `json:"hosts"`
+}
+
+// HostCount is the number of images grabbed from a host.
+type HostCount struct {
+	Host   string `json:"host"`
+	Images int    `json:"images"`
+}
+
+// Catalog is a SQLite database of every image grabbed, with where it came
+// from, where it was saved and what the camera recorded about it. Unlike
+// the manifest it outlives the output directory and can be searched.
+type Catalog struct {
+	db *sql.DB
+}
+
+// OpenCatalog opens the catalog at path, creating it if it doesn't exist.
+func OpenCatalog(path string) (*Catalog, error) {
+	db, err := sql.Open("sqlite", path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		return nil, err
+	}
+	// SQLite takes one writer at a time anyway
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(catalogSchema); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &Catalog{db: db}, nil
+}
+
+// Close closes the database.
+func (c *Catalog) Close() error {
+	return c.db.Close()
+}
+
+// add records the grabbed file f, replacing what there was for its url.
+func (c *Catalog) add(f *File, x *Exif) error {
+	if x == nil {
+		x = &Exif{}
+	}
+	var host string
+	if u, err := url.Parse(f.URL); err == nil {
+		host = u.Hostname()
+	}
+	var taken string
+	if !x.Taken.IsZero() {
+		taken = x.Taken.Format(time.RFC3339)
+	}
+	var lat, lon sql.NullFloat64
+	if x.HasGPS {
+		lat = sql.NullFloat64{Float64: x.Lat, Valid: true}
+		lon = sql.NullFloat64{Float64: x.Lon, Valid: true}
+	}
+
+	_, err := c.db.Exec(`INSERT OR REPLACE INTO images (`+catalogColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		f.URL, f.Page, host, f.Path, f.Size, f.SHA256, f.ContentType, f.Width, f.Height,
+		x.Make, x.Model, x.Lens, taken, lat, lon, time.Now().UTC().Format(time.RFC3339))
+	return err
+}
+
+// Search returns the entries matching q, the last grabbed first.
+func (c *Catalog) Search(q CatalogQuery) ([]CatalogEntry, error) {
+	var where []string
+	var args []interface{}
+	if q.Text != "" {
+		where = append(where, "(url LIKE ? OR page LIKE ? OR path LIKE ?)")
+		like := "%" + q.Text + "%"
+		args = append(args, like, like, like)
+	}
+	if q.Host != "" {
+		where = append(where, "(host = ? OR host LIKE ?)")
+		args = append(args, q.Host, "%."+q.Host)
+	}
+	if q.Camera != "" {
+		where = append(where, "(make || ' ' || model) LIKE ?")
+		args = append(args, "%"+q.Camera+"%")
+	}
+	if q.ContentType != "" {
+		where = append(where, "content_type LIKE ?")
+		args = append(args, q.ContentType+"%")
+	}
+	if q.MinWidth > 0 {
+		where = append(where, "width >= ?")
+		args = append(args, q.MinWidth)
+	}
+	if q.MinHeight > 0 {
+		where = append(where, "height >= ?")
+		args = append(args, q.MinHeight)
+	}
+	if !q.Since.IsZero() {
+		where = append(where, "grabbed >= ?")
+		args = append(args, q.Since.UTC().Format(time.RFC3339))
+	}
+	if !q.Until.IsZero() {
+		where = append(where, "grabbed < ?")
+		args = append(args, q.Until.UTC().Format(time.RFC3339))
+	}
+
+	query := "SELECT " + catalogColumns + " FROM images"
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY grabbed DESC, url"
+	if q.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, q.Limit)
+	}
+
+	return c.entries(query, args...)
+}
+
+// Duplicates returns the groups of entries with the same content.
+func (c *Catalog) Duplicates() ([][]CatalogEntry, error) {
+	entries, err := c.entries(`SELECT ` + catalogColumns + ` FROM images
+		WHERE sha256 IN (SELECT sha256 FROM images WHERE sha256 != '' GROUP BY sha256 HAVING COUNT(*) > 1)
+		ORDER BY sha256, grabbed, url`)
+	if err != nil {
+		return nil, err
+	}
+
+	var groups [][]CatalogEntry
+	for i, e := range entries {
+		if i == 0 || e.SHA256 != entries[i-1].SHA256 {
+			groups = append(groups, nil)
+		}
+		groups[len(groups)-1] = append(groups[len(groups)-1], e)
+	}
+	return groups, nil
+}
+
+// Stats sums up the catalog.
+func (c *Catalog) Stats() (*CatalogStats, error) {
+	s := &CatalogStats{}
+	err := c.db.QueryRow(`SELECT COUNT(*), COALESCE(SUM(size), 0), COUNT(DISTINCT sha256) FROM images`).
+		Scan(&s.Images, &s.Bytes, &s.Unique)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := c.db.Query(`SELECT host, COUNT(*) FROM images GROUP BY host ORDER BY COUNT(*) DESC, host`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var h HostCount
+		if err := rows.Scan(&h.Host, &h.Images); err != nil {
+			return nil, err
+		}
+		s.Hosts = append(s.Hosts, h)
+	}
+	return s, rows.Err()
+}
+
+// entries runs a query selecting catalogColumns.
+func (c *Catalog) entries(query string, args ...interface{}) ([]CatalogEntry, error) {
+	rows, err := c.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []CatalogEntry
+	for rows.Next() {
+		var e CatalogEntry
+		var taken, grabbed string
+		var lat, lon sql.NullFloat64
+		err := rows.Scan(&e.URL, &e.Page, &e.Host, &e.Path, &e.Size, &e.SHA256, &e.ContentType,
+			&e.Width, &e.Height, &e.Make, &e.Model, &e.Lens, &taken, &lat, &lon, &grabbed)
+		if err != nil {
+			return nil, err
+		}
+		e.Taken, _ = time.Parse(time.RFC3339, taken)
+		e.Grabbed, _ = time.Parse(time.RFC3339, grabbed)
+		if lat.Valid && lon.Valid {
+			e.HasGPS, e.Lat, e.Lon = true, lat.Float64, lon.Float64
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
@@ -0,0 +1,93 @@
+package grabber
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/chromedp/chromedp"
+)
+
+// ChromeProfile is a set of chrome flags suited to where chrome runs.
+type ChromeProfile string
+
+const (
+	// ChromeDefault uses the chromedp defaults, which expect a desktop
+	// with chrome installed the usual way.
+	ChromeDefault ChromeProfile = "default"
+	// ChromeContainer runs chrome in Docker and CI: without the sandbox,
+	// which needs privileges containers don't have, without /dev/shm,
+	// which is 64MB there, and with chrome looked up in the places the
+	// common images install it to.
+	ChromeContainer ChromeProfile = "container"
+)
+
+// Set implements flag.Value.
+func (p *ChromeProfile) Set(v string) error {
+	switch ChromeProfile(v) {
+	case "", ChromeDefault, ChromeContainer:
+		*p = ChromeProfile(v)
+		return nil
+	}
+	return fmt.Errorf("unknown chrome profile %q, want default or container", v)
+}
+
+func (p ChromeProfile) String() string {
+	return string(p)
+}
+
+// chromeExecutables are the chrome binaries looked for in PATH by the
+// container profile, chromium first as that is what distro images ship.
+var chromeExecutables = []string{
+	"chromium",
+	"chromium-browser",
+	"google-chrome-stable",
+	"google-chrome",
+	"headless-shell",
+}
+
+// chromePaths are where the common container images put chrome outside
+// of PATH.
+var chromePaths = []string{
+	"/headless-shell/headless-shell",
+	"/usr/lib/chromium/chromium",
+	"/opt/google/chrome/chrome",
+}
+
+// findChrome returns the chrome to run in a container: $CHROME_PATH if
+// set, else the first of chromeExecutables in PATH or of chromePaths that
+// exists. It returns "" to leave the lookup to chromedp.
+func findChrome() string {
+	if p := os.Getenv("CHROME_PATH"); p != "" {
+		return p
+	}
+	for _, name := range chromeExecutables {
+		if p, err := exec.LookPath(name); err == nil {
+			return p
+		}
+	}
+	for _, p := range chromePaths {
+		if fi, err := os.Stat(p); err == nil && !fi.IsDir() {
+			return p
+		}
+	}
+	return ""
+}
+
+// allocatorOptions returns the chrome flags of the profile, added to the
+// chromedp defaults.
+func (p ChromeProfile) allocatorOptions() []chromedp.ExecAllocatorOption {
+	if p != ChromeContainer {
+		return nil
+	}
+	opts := []chromedp.ExecAllocatorOption{
+		chromedp.NoSandbox,
+		chromedp.DisableGPU,
+		chromedp.Flag("disable-dev-shm-usage", true),
+		chromedp.Flag("no-zygote", true),
+	}
+	if path := findChrome(); path != "" {
+		opts = append(opts, chromedp.ExecPath(path))
+	}
+	return opts
+}
@@ -0,0 +1,452 @@
+package grabber
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+	"github.com/gocolly/colly"
+)
+
+// metaImageSelector selects the Open Graph and Twitter Card images of a
+// page, whose url is in the content attribute.
+const metaImageSelector = `meta[property="og:image"], meta[property="og:image:url"], meta[property="og:image:secure_url"], ` +
+	`meta[name="twitter:image"], meta[name="twitter:image:src"], meta[property="twitter:image"]`
+
+// Collector finds the photo links and images of gallery pages.
+type Collector struct {
+	// LinkSelector selects the links to photo detail pages.
+	LinkSelector string
+	// ImageSelector selects the elements holding image urls, taken from
+	// their LazyAttributes, srcset, src or href attribute in that order.
+	ImageSelector string
+	// LazyAttributes are the attributes lazy loading keeps the real url in.
+	LazyAttributes []string
+	// MetaImages also takes the Open Graph and Twitter Card images.
+	MetaImages bool
+	// EmbeddedImages also takes the images in JSON-LD and other JSON
+	// embedded in the page.
+	EmbeddedImages bool
+	// Pagination finds the links to the next page of the gallery, marked
+	// rel="next" or numbered as in ?page=2 or /page/2.
+	Pagination bool
+	// SpanHosts also takes the links to pages on other hosts.
+	SpanHosts bool
+	// NormalizeURLs brings the urls found to their canonical form, without
+	// the TrackingParams.
+	NormalizeURLs  bool
+	TrackingParams []string
+	// Rewrite are the rules the urls of the images found are rewritten
+	// with, in turn, before being normalized.
+	Rewrite []*RewriteRule
+	// ClickSelector is the element clicked on a photo detail page to get
+	// the full size image. Empty only opens the page.
+	ClickSelector string
+	// StaticFirst looks for the images of photo detail pages in their HTML
+	// first, only opening them in chrome if it has none.
+	StaticFirst bool
+	// NetworkImages also takes the images chrome loads while a photo
+	// detail page is open.
+	NetworkImages bool
+	// Scroll renders the pages in chrome, scrolling down for as long as
+	// more links and images keep loading, but at most MaxScrolls times and
+	// only until there are ScrollItems of them, 0 being no limit.
+	Scroll      bool
+	MaxScrolls  int
+	ScrollItems int
+	// LoadMore is the "load more" button clicked on the pages, rendered in
+	// chrome, until it is gone or no more links and images load, but at
+	// most MaxLoadMore times, 0 being no limit.
+	LoadMore    string
+	MaxLoadMore int
+	// Screenshots takes a screenshot of the biggest element matching
+	// ScreenshotSelector of photo detail pages that have no image to
+	// download.
+	Screenshots        bool
+	ScreenshotSelector string
+	// PageTimeout is the longest fetching, rendering or resolving a page
+	// may take, 0 is no limit.
+	PageTimeout time.Duration
+	// Headers are sent with every page request.
+	Headers map[string]string
+	// Limiter spaces out the page requests, shared with the Downloader.
+	Limiter *RateLimiter
+	// Proxies are used for the page requests, shared with the Downloader.
+	Proxies *ProxyPool
+	// Logger gets the pages visited, nil logs nothing.
+	Logger *slog.Logger
+	// Jar holds the cookies of colly and chrome, shared with the
+	// Downloader. Nil keeps a separate jar per page.
+	Jar *cookiejar.Jar
+
+	browser browser
+}
+
+// NewCollector creates a Collector using the selectors of opts, with the
+// image selector extended to the videos if opts.Media asks for them.
+func NewCollector(opts Options) *Collector {
+	rewrite, _ := parseRewriteRules(opts.Rewrite)
+	// Chrome takes a single proxy for the whole browser
+	if opts.Browser.ProxyServer == "" && len(opts.Proxies) > 0 {
+		opts.Browser.ProxyServer = opts.Proxies[0]
+	}
+
+	return &Collector{
+		LinkSelector:       opts.LinkSelector,
+		ImageSelector:      opts.mediaSelector(),
+		LazyAttributes:     opts.LazyAttributes,
+		MetaImages:         opts.MetaImages && opts.wants(MediaImage),
+		EmbeddedImages:     opts.EmbeddedImages && opts.wants(MediaImage),
+		Pagination:         opts.Pagination,
+		SpanHosts:          opts.SpanHosts,
+		NormalizeURLs:      opts.NormalizeURLs,
+		TrackingParams:     opts.TrackingParams,
+		Rewrite:            rewrite,
+		Screenshots:        opts.Screenshots,
+		ScreenshotSelector: opts.ScreenshotSelector,
+		ClickSelector:      opts.ClickSelector,
+		StaticFirst:        opts.StaticFirst,
+		NetworkImages:      opts.NetworkImages && opts.wants(MediaImage),
+		Scroll:             opts.Scroll,
+		MaxScrolls:         opts.MaxScrolls,
+		ScrollItems:        opts.ScrollItems,
+		LoadMore:           opts.LoadMoreSelector,
+		MaxLoadMore:        opts.MaxLoadMore,
+		PageTimeout:        opts.PageTimeout,
+		Headers:            opts.headers(),
+		browser:            browser{opts: opts.Browser, userAgent: opts.UserAgent, insecure: opts.TLS.Insecure, resolverRules: opts.hostResolverRules()},
+	}
+}
+
+// Page is what was found on a visited page.
+type Page struct {
+	URL string
+	// Links are the absolute urls of the photo detail pages the page links to.
+	Links []string
+	// Pages are all the other same-host pages it links to, or all the
+	// other pages with SpanHosts.
+	Pages []string
+	// Next are the pages continuing the gallery, if Pagination is set.
+	Next []string
+	// Images are the absolute urls of the images embedded in the page.
+	Images []string
+}
+
+// Collect visits pageURL and returns the links matching c.LinkSelector, the
+// other same-host links and the images matching c.ImageSelector. With
+// c.Scroll or c.LoadMore these are taken from the page once chrome is done
+// scrolling it and clicking its load more button. Galleries a
+// GalleryExtractor takes aren't visited, they are their only photo link.
+// The requests end when ctx is done.
+func (c *Collector) Collect(ctx context.Context, pageURL string) (*Page, error) {
+	if err := checkURL(pageURL); err != nil {
+		return nil, err
+	}
+	if e, ok := FindExtractor(pageURL).(GalleryExtractor); ok && e.Gallery(pageURL) {
+		return &Page{URL: pageURL, Links: []string{pageURL}}, nil
+	}
+	ctx, cancel := withTimeout(ctx, c.PageTimeout)
+	defer cancel()
+
+	cc := c.colly(ctx)
+	if c.Scroll || c.LoadMore != "" {
+		html, err := c.render(ctx, pageURL)
+		if err != nil {
+			return nil, timeoutError(ctx, err, c.PageTimeout)
+		}
+		cc = colly.NewCollector()
+		cc.WithTransport(renderedPage{html: html})
+	}
+	page := &Page{URL: pageURL}
+
+	// Find all photo links. This runs over the whole page before the
+	// handler below, so it can tell photo links from other pages.
+	photos := make(map[string]bool)
+	if c.LinkSelector != "" {
+		cc.OnHTML(c.LinkSelector, func(e *colly.HTMLElement) {
+			link := c.normalize(e.Request.AbsoluteURL(e.Attr("href")))
+			if link != "" && isHTTP(link) && !photos[link] {
+				photos[link] = true
+				page.Links = append(page.Links, link)
+			}
+		})
+	}
+
+	// Find and visit all links
+	cc.OnHTML("a[href]", func(e *colly.HTMLElement) {
+		link := c.normalize(e.Request.AbsoluteURL(e.Attr("href")))
+		if link != "" && isHTTP(link) && !photos[link] && (c.SpanHosts || sameHost(link, pageURL)) {
+			page.Pages = append(page.Pages, link)
+		}
+	})
+
+	// Find the next pages of the gallery
+	if c.Pagination {
+		next := make(map[string]bool)
+		addNext := func(link string) {
+			link = c.normalize(link)
+			if link != "" && isHTTP(link) && link != pageURL && !next[link] {
+				next[link] = true
+				page.Next = append(page.Next, link)
+			}
+		}
+		cc.OnHTML(nextLinkSelector, func(e *colly.HTMLElement) {
+			addNext(e.Request.AbsoluteURL(e.Attr("href")))
+		})
+		cc.OnHTML("a[href]", func(e *colly.HTMLElement) {
+			link := e.Request.AbsoluteURL(e.Attr("href"))
+			if u, err := url.Parse(link); err == nil && !photos[c.normalize(link)] && isNextPage(e.Request.URL, u) {
+				addNext(link)
+			}
+		})
+	}
+
+	seen := make(map[string]bool)
+	addImage := func(e *colly.HTMLElement, src string) {
+		if !isDataURL(src) {
+			src = c.imageURL(e.Request.AbsoluteURL(src))
+		}
+		if src != "" && (isHTTP(src) || isDataURL(src)) && !seen[src] {
+			seen[src] = true
+			page.Images = append(page.Images, src)
+		}
+	}
+
+	// Find all images, preferring lazy loaded urls and the biggest srcset
+	// candidate over src
+	if c.ImageSelector != "" {
+		cc.OnHTML(c.ImageSelector, func(e *colly.HTMLElement) {
+			if src := imageSource(e.Attr, c.LazyAttributes); src != "" {
+				addImage(e, src)
+			}
+		})
+	}
+
+	// The hero image of articles and products is often only in the meta tags
+	if c.MetaImages {
+		cc.OnHTML(metaImageSelector, func(e *colly.HTMLElement) {
+			if src := strings.TrimSpace(e.Attr("content")); src != "" {
+				addImage(e, src)
+			}
+		})
+	}
+
+	// Single page apps often only have the full size urls in their data
+	if c.EmbeddedImages {
+		cc.OnHTML(embeddedJSONSelector, func(e *colly.HTMLElement) {
+			for _, src := range embeddedImages([]byte(e.Text)) {
+				addImage(e, src)
+			}
+		})
+	}
+
+	if err := cc.Visit(pageURL); err != nil {
+		return nil, timeoutError(ctx, err, c.PageTimeout)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, timeoutError(ctx, err, c.PageTimeout)
+	}
+
+	return page, nil
+}
+
+// colly creates a colly collector sending c.Headers through c.Proxies, with
+// the cookies of c.Jar and the requests spaced out by c.Limiter. Its
+// requests end when ctx is done, the caller has to check ctx after a Visit
+// as those aborted before they were sent don't fail.
+func (c *Collector) colly(ctx context.Context) *colly.Collector {
+	cc := colly.NewCollector()
+	if c.Jar != nil {
+		cc.SetCookieJar(c.Jar)
+	}
+	var transport http.RoundTripper = http.DefaultTransport
+	if c.Proxies != nil {
+		transport = c.Proxies.transport()
+	}
+	cc.WithTransport(contextTransport{ctx: ctx, next: transport})
+	cc.OnRequest(func(r *colly.Request) {
+		if err := c.Limiter.Wait(ctx, r.URL.Host); err != nil {
+			r.Abort()
+			return
+		}
+		orDiscard(c.Logger).Debug("requesting page", "url", r.URL.String())
+		for k, v := range c.Headers {
+			r.Headers.Set(k, v)
+		}
+	})
+	return cc
+}
+
+// contextTransport sends the requests of colly, which knows nothing of
+// contexts, with ctx.
+type contextTransport struct {
+	ctx  context.Context
+	next http.RoundTripper
+}
+
+func (t contextTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.next.RoundTrip(req.WithContext(t.ctx))
+}
+
+// Resolve opens a photo detail page in a new chrome tab and returns the
+// images the Extractor registered for the page finds, DefaultExtractor
+// clicking c.ClickSelector and taking the images matching c.ImageSelector.
+// If c.Screenshots is set and there are none, a screenshot of the page is
+// taken instead. The tab starts with the cookies c.Jar has for the page and
+// the cookies it ends up with are put back into c.Jar. The tab is closed
+// early when ctx is done. Errors wrap ErrExtraction, or ErrBadURL if
+// pageURL can't be opened.
+func (c *Collector) Resolve(ctx context.Context, pageURL string) ([]ImageRef, error) {
+	if err := checkURL(pageURL); err != nil {
+		return nil, err
+	}
+	ctx, cancel := withTimeout(ctx, c.PageTimeout)
+	defer cancel()
+
+	images, err := c.resolve(ctx, pageURL)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrExtraction, timeoutError(ctx, err, c.PageTimeout))
+	}
+	return c.cleanRefs(images), nil
+}
+
+// cleanRefs rewrites and normalizes the urls of the refs that are to be
+// downloaded, dropping those that turn out to be the same.
+func (c *Collector) cleanRefs(refs []ImageRef) []ImageRef {
+	if !c.NormalizeURLs && len(c.Rewrite) == 0 {
+		return refs
+	}
+	seen := make(map[string]bool)
+	kept := refs[:0]
+	for _, ref := range refs {
+		if ref.Data == nil {
+			ref.URL = c.imageURL(ref.URL)
+		}
+		if !seen[ref.URL] {
+			seen[ref.URL] = true
+			kept = append(kept, ref)
+		}
+	}
+	return kept
+}
+
+// resolve is Resolve without the timeout.
+func (c *Collector) resolve(ctx context.Context, pageURL string) ([]ImageRef, error) {
+	// Only the default extractor does what the static HTML may already show
+	if _, ok := FindExtractor(pageURL).(defaultExtractor); ok && c.StaticFirst {
+		images, err := c.resolveStatic(ctx, pageURL)
+		if err == nil && len(images) > 0 {
+			orDiscard(c.Logger).Debug("resolved photo page without chrome", "url", pageURL, "images", len(images))
+			return images, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		orDiscard(c.Logger).Debug("opening photo page in chrome", "url", pageURL, "static_err", err)
+	}
+
+	ctx, cancel, err := c.browser.tab(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer cancel()
+	var loaded *networkImages
+	if c.NetworkImages {
+		if loaded, err = watchImages(ctx); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.navigate(ctx, pageURL); err != nil {
+		return nil, err
+	}
+
+	tab := &Tab{URL: pageURL, ImageSelector: c.ImageSelector, ClickSelector: c.ClickSelector, LazyAttributes: c.LazyAttributes, ctx: ctx}
+	images, err := FindExtractor(pageURL).Extract(tab)
+	if err != nil {
+		return nil, err
+	}
+	if loaded != nil {
+		images = loaded.add(images, pageURL)
+	}
+	if len(images) == 0 && c.Screenshots {
+		if images, err = tab.Screenshot(c.ScreenshotSelector); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.saveCookies(ctx); err != nil {
+		return nil, err
+	}
+
+	orDiscard(c.Logger).Debug("resolved photo page", "url", pageURL, "images", len(images))
+
+	return images, nil
+}
+
+// openTab opens pageURL in a new chrome tab with c.Headers and the cookies
+// c.Jar has for it. The returned cancel func closes the tab, as ctx being
+// done does.
+func (c *Collector) openTab(ctx context.Context, pageURL string) (context.Context, context.CancelFunc, error) {
+	ctx, cancel, err := c.browser.tab(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := c.navigate(ctx, pageURL); err != nil {
+		cancel()
+		return nil, nil, err
+	}
+	return ctx, cancel, nil
+}
+
+// navigate opens pageURL in the tab of ctx with c.Headers and the cookies
+// c.Jar has for it.
+func (c *Collector) navigate(ctx context.Context, pageURL string) error {
+	if err := c.Limiter.WaitURL(ctx, pageURL); err != nil {
+		return err
+	}
+
+	var actions []chromedp.Action
+	if len(c.Headers) > 0 {
+		headers := make(network.Headers, len(c.Headers))
+		for k, v := range c.Headers {
+			headers[k] = v
+		}
+		actions = append(actions, network.Enable(), network.SetExtraHTTPHeaders(headers))
+	}
+	if c.Jar != nil {
+		if u, err := url.Parse(pageURL); err == nil {
+			for _, ck := range c.Jar.Cookies(u) {
+				actions = append(actions, network.SetCookie(ck.Name, ck.Value).WithURL(pageURL))
+			}
+		}
+	}
+	actions = append(actions, chromedp.Navigate(pageURL))
+	return chromedp.Run(ctx, actions...)
+}
+
+// saveCookies puts the cookies of the tab back into c.Jar.
+func (c *Collector) saveCookies(ctx context.Context) error {
+	if c.Jar == nil {
+		return nil
+	}
+
+	return chromedp.Run(ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+		cookies, err := network.GetCookies().Do(ctx)
+		if err != nil {
+			return err
+		}
+		setCookies(c.Jar, chromeCookies(cookies))
+		return nil
+	}))
+}
+
+// Close shuts down the browser used by Resolve.
+func (c *Collector) Close() {
+	c.browser.Close()
+}
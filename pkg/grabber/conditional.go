@@ -0,0 +1,73 @@
+package grabber
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// validators are what the server sent to tell the content of a url apart
+// when it was last downloaded, sent back to have it answer 304 Not
+// Modified instead of the whole file if it didn't change.
+type validators struct {
+	etag     string
+	modified time.Time
+}
+
+// validatorsOf returns the validators Validators has for url.
+func (d *Downloader) validatorsOf(url string) validators {
+	if d.Validators == nil {
+		return validators{}
+	}
+	etag, modified := d.Validators(url)
+	return validators{etag: etag, modified: modified}
+}
+
+// set makes req conditional on the content having changed.
+func (v validators) set(req *http.Request) {
+	if v.etag != "" {
+		req.Header.Set("If-None-Match", v.etag)
+	}
+	if !v.modified.IsZero() {
+		req.Header.Set("If-Modified-Since", v.modified.UTC().Format(http.TimeFormat))
+	}
+}
+
+// notModified is the error of a conditional download the server answered
+// with 304 Not Modified.
+func notModified() error {
+	return &SkipError{Reason: UnchangedReason}
+}
+
+// isNotModified reports whether err is that of a conditional download of
+// something that didn't change.
+func isNotModified(err error) bool {
+	var skipErr *SkipError
+	return errors.As(err, &skipErr) && skipErr.Reason == UnchangedReason
+}
+
+// do waits for the limiter and sends req, returning the response if its
+// status is a success.
+func (d *Downloader) do(ctx context.Context, req *http.Request) (*http.Response, error) {
+	if err := d.Limiter.Wait(ctx, req.URL.Host); err != nil {
+		return nil, err
+	}
+	orDiscard(d.Logger).Debug("requesting", "url", req.URL.String())
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	return checkStatus(resp)
+}
+
+// validators returns the ETag and Last-Modified time the manifest has for
+// url, for Options.Mirror to download it only if it changed. Urls whose
+// file is gone get none, their content is needed either way.
+func (g *Grabber) validators(url string) (string, time.Time) {
+	if !g.Options.Mirror || g.manifest == nil || !g.manifest.Has(url) {
+		return "", time.Time{}
+	}
+	e, _ := g.manifest.entry(url)
+	return e.ETag, e.Modified
+}
@@ -0,0 +1,58 @@
+package grabber
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is a grab run described in a YAML or TOML file:
+//
+//	urls:
+//	  - https://example.com/gallery
+//	output: photos
+//	concurrency: 8
+//	link_selector: a.thumb
+//	headers:
+//	  Referer: https://example.com/
+//	login:
+//	  url: https://example.com/login
+//	  username_selector: "#user"
+//	  password_selector: "#pass"
+//	  username: me
+//	  password_env: GALLERY_PASSWORD
+type Config struct {
+	// URLs are the pages to crawl, or the images to download.
+	URLs    []string `yaml:"urls" toml:"urls"`
+	Options `yaml:",inline"`
+}
+
+// LoadConfig reads the config file at path on top of opts. The format is
+// picked by extension: .toml is TOML, anything else YAML.
+func LoadConfig(path string, opts Options) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &Config{Options: opts}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".toml":
+		err = toml.Unmarshal(data, cfg)
+	default:
+		err = yaml.Unmarshal(data, cfg)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	for i, ext := range cfg.Extensions {
+		cfg.Extensions[i] = strings.TrimPrefix(strings.ToLower(ext), ".")
+	}
+
+	return cfg, nil
+}
@@ -0,0 +1,166 @@
+package grabber
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// ConvertOptions tune the encoding of converted images.
+type ConvertOptions struct {
+	// Quality is the JPEG quality, 1 to 100.
+	Quality int
+}
+
+// Converter re-encodes images of one format in another.
+type Converter interface {
+	// Convert reads the image in r and writes it to w with the content
+	// type to.
+	Convert(w io.Writer, r io.Reader, to string, opts ConvertOptions) error
+}
+
+var converters struct {
+	mu sync.RWMutex
+	m  map[string]Converter
+}
+
+// RegisterConverter makes c convert images of the content type from to the
+// content type to, replacing what did that before. The pure Go converter is
+// registered for everything the image package can decode and encode, cgo
+// codecs can take over formats or add new ones from a file of their own.
+func RegisterConverter(from, to string, c Converter) {
+	converters.mu.Lock()
+	defer converters.mu.Unlock()
+
+	if converters.m == nil {
+		converters.m = make(map[string]Converter)
+	}
+	converters.m[from+">"+to] = c
+}
+
+func converterFor(from, to string) Converter {
+	converters.mu.RLock()
+	defer converters.mu.RUnlock()
+
+	return converters.m[from+">"+to]
+}
+
+// imageConverter converts through the decoders registered with the image
+// package.
+type imageConverter struct{}
+
+func (imageConverter) Convert(w io.Writer, r io.Reader, to string, opts ConvertOptions) error {
+	img, _, err := image.Decode(r)
+	if err != nil {
+		return err
+	}
+
+	switch to {
+	case "image/jpeg":
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: opts.Quality})
+	case "image/png":
+		return png.Encode(w, img)
+	}
+	return fmt.Errorf("can't encode %s", to)
+}
+
+func init() {
+	for _, from := range []string{"image/jpeg", "image/png", "image/gif", "image/webp", "image/bmp"} {
+		for _, to := range []string{"image/jpeg", "image/png"} {
+			if from != to {
+				RegisterConverter(from, to, imageConverter{})
+			}
+		}
+	}
+}
+
+// formatTypes maps the format names of Options.Convert to content types.
+var formatTypes = map[string]string{
+	"jpeg": "image/jpeg",
+	"jpg":  "image/jpeg",
+	"png":  "image/png",
+	"gif":  "image/gif",
+	"webp": "image/webp",
+	"bmp":  "image/bmp",
+	"avif": "image/avif",
+}
+
+// conversions parses Options.Convert into a map from the content types
+// converted to the ones they are converted to.
+func (o Options) conversions() (map[string]string, error) {
+	if len(o.Convert) == 0 {
+		return nil, nil
+	}
+
+	m := make(map[string]string)
+	for _, c := range o.Convert {
+		from, to, ok := strings.Cut(strings.ToLower(c), ":")
+		if !ok {
+			return nil, fmt.Errorf("conversion %q is not from:to", c)
+		}
+		fromType, toType := formatTypes[from], formatTypes[to]
+		if fromType == "" || toType == "" {
+			return nil, fmt.Errorf("conversion %q: unknown format", c)
+		}
+		if converterFor(fromType, toType) == nil {
+			if from == "avif" {
+				return nil, fmt.Errorf("conversion %q: build with -tags avif for AVIF support", c)
+			}
+			return nil, fmt.Errorf("conversion %q is not supported", c)
+		}
+		m[fromType] = toType
+	}
+	return m, nil
+}
+
+// convert re-encodes data of contentType as d.Convert says. It returns data
+// as is if it isn't to be converted, or can't be: the original is better
+// than nothing.
+func (d *Downloader) convert(url string, data []byte, contentType string) ([]byte, string) {
+	to := d.Convert[contentType]
+	if to == "" {
+		return data, contentType
+	}
+
+	var buf bytes.Buffer
+	opts := ConvertOptions{Quality: d.ConvertQuality}
+	if err := converterFor(contentType, to).Convert(&buf, bytes.NewReader(data), to, opts); err != nil {
+		orDiscard(d.Logger).Warn("can't convert", "url", url, "from", contentType, "to", to, "err", err)
+		return data, contentType
+	}
+	return buf.Bytes(), to
+}
+
+// convertFile converts the downloaded file at tmpName in place as d.Convert
+// says, returning its new content type, checksum and size. Files that
+// aren't converted keep theirs.
+func (d *Downloader) convertFile(url, tmpName, contentType string, p *Progress, sum *string) (string, error) {
+	if d.Convert[contentType] == "" {
+		return contentType, nil
+	}
+
+	data, err := os.ReadFile(tmpName)
+	if err != nil {
+		return "", err
+	}
+	converted, to := d.convert(url, data, contentType)
+	if to == contentType {
+		return contentType, nil
+	}
+	if err := os.WriteFile(tmpName, converted, 0644); err != nil {
+		return "", err
+	}
+
+	h := sha256.Sum256(converted)
+	*sum = hex.EncodeToString(h[:])
+	p.Written = uint64(len(converted))
+	return to, nil
+}
@@ -0,0 +1,13 @@
+//go:build avif
+
+package grabber
+
+import (
+	// Registers the AVIF decoder with the image package
+	_ "github.com/gen2brain/avif"
+)
+
+func init() {
+	RegisterConverter("image/avif", "image/jpeg", imageConverter{})
+	RegisterConverter("image/avif", "image/png", imageConverter{})
+}
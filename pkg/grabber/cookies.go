@@ -0,0 +1,211 @@
+package grabber
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chromedp/cdproto/network"
+	"golang.org/x/net/publicsuffix"
+)
+
+// NewCookieJar creates the cookie jar shared by colly, chrome and the
+// downloader, so that a session started on one of them carries over to the
+// others. If path isn't empty the jar starts out with the cookies in that
+// file, either in the Netscape cookies.txt format or a JSON array as
+// exported by browser extensions.
+func NewCookieJar(path string) (*cookiejar.Jar, error) {
+	jar, err := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+	if err != nil {
+		return nil, err
+	}
+	if path == "" {
+		return jar, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cookies []hostCookie
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && (trimmed[0] == '[' || trimmed[0] == '{') {
+		cookies, err = parseJSONCookies(trimmed)
+	} else {
+		cookies, err = parseNetscapeCookies(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	setCookies(jar, cookies)
+	return jar, nil
+}
+
+// parseNetscapeCookies reads the tab separated cookies.txt format of curl
+// and wget: domain, include subdomains, path, secure, expiry, name, value.
+func parseNetscapeCookies(data []byte) ([]hostCookie, error) {
+	var cookies []hostCookie
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		httpOnly := false
+		if strings.HasPrefix(line, "#HttpOnly_") {
+			line, httpOnly = strings.TrimPrefix(line, "#HttpOnly_"), true
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		if len(fields) == 6 {
+			// Cookies without a value
+			fields = append(fields, "")
+		}
+		if len(fields) != 7 {
+			return nil, fmt.Errorf("line %d: want 7 tab separated fields, got %d", n, len(fields))
+		}
+		expires, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: bad expiry %q", n, fields[4])
+		}
+
+		c := &http.Cookie{
+			Domain:   fields[0],
+			Path:     fields[2],
+			Secure:   strings.EqualFold(fields[3], "TRUE"),
+			Name:     fields[5],
+			Value:    fields[6],
+			HttpOnly: httpOnly,
+		}
+		if expires > 0 {
+			c.Expires = time.Unix(expires, 0)
+		}
+		cookies = append(cookies, newHostCookie(c, !strings.EqualFold(fields[1], "TRUE")))
+	}
+	return cookies, scanner.Err()
+}
+
+// jsonCookie is a cookie as exported by browser extensions and puppeteer.
+type jsonCookie struct {
+	Name           string   `json:"name"`
+	Value          string   `json:"value"`
+	Domain         string   `json:"domain"`
+	Path           string   `json:"path"`
+	Secure         bool     `json:"secure"`
+	HTTPOnly       bool     `json:"httpOnly"`
+	HostOnly       *bool    `json:"hostOnly"`
+	Expires        *float64 `json:"expires"`
+	ExpirationDate *float64 `json:"expirationDate"`
+}
+
+// parseJSONCookies reads a JSON array of cookies, or an object holding it
+// under "cookies" like the playwright storage state.
+func parseJSONCookies(data []byte) ([]hostCookie, error) {
+	var list []jsonCookie
+	if data[0] == '{' {
+		var state struct {
+			Cookies []jsonCookie `json:"cookies"`
+		}
+		if err := json.Unmarshal(data, &state); err != nil {
+			return nil, err
+		}
+		list = state.Cookies
+	} else if err := json.Unmarshal(data, &list); err != nil {
+		return nil, err
+	}
+
+	cookies := make([]hostCookie, 0, len(list))
+	for _, jc := range list {
+		c := &http.Cookie{
+			Name:     jc.Name,
+			Value:    jc.Value,
+			Domain:   jc.Domain,
+			Path:     jc.Path,
+			Secure:   jc.Secure,
+			HttpOnly: jc.HTTPOnly,
+		}
+		expires := jc.ExpirationDate
+		if expires == nil {
+			expires = jc.Expires
+		}
+		if expires != nil && *expires > 0 {
+			c.Expires = unixTime(*expires)
+		}
+		// Without a hostOnly field a leading dot is what marks domain cookies
+		host := !strings.HasPrefix(jc.Domain, ".")
+		if jc.HostOnly != nil {
+			host = *jc.HostOnly
+		}
+		cookies = append(cookies, newHostCookie(c, host))
+	}
+	return cookies, nil
+}
+
+// hostCookie is a cookie along with the host it was set by.
+type hostCookie struct {
+	host   string
+	cookie *http.Cookie
+}
+
+// newHostCookie takes the host from the domain of c, clearing the domain if
+// the cookie is only sent to that exact host: that is how cookiejar tells
+// them from the cookies also sent to subdomains.
+func newHostCookie(c *http.Cookie, hostOnly bool) hostCookie {
+	host := strings.TrimPrefix(c.Domain, ".")
+	if hostOnly {
+		c.Domain = ""
+	}
+	return hostCookie{host: host, cookie: c}
+}
+
+// chromeCookies converts the cookies read from chrome.
+func chromeCookies(list []*network.Cookie) []hostCookie {
+	cookies := make([]hostCookie, 0, len(list))
+	for _, nc := range list {
+		c := &http.Cookie{
+			Name:     nc.Name,
+			Value:    nc.Value,
+			Domain:   nc.Domain,
+			Path:     nc.Path,
+			Secure:   nc.Secure,
+			HttpOnly: nc.HTTPOnly,
+		}
+		if !nc.Session && nc.Expires > 0 {
+			c.Expires = unixTime(nc.Expires)
+		}
+		cookies = append(cookies, newHostCookie(c, !strings.HasPrefix(nc.Domain, ".")))
+	}
+	return cookies
+}
+
+// unixTime converts fractional seconds since the epoch.
+func unixTime(sec float64) time.Time {
+	whole, frac := math.Modf(sec)
+	return time.Unix(int64(whole), int64(frac*1e9))
+}
+
+// setCookies puts cookies into jar, each under a url of its own host.
+func setCookies(jar http.CookieJar, cookies []hostCookie) {
+	for _, hc := range cookies {
+		if hc.host == "" || hc.cookie.Name == "" {
+			continue
+		}
+		u := &url.URL{Scheme: "http", Host: hc.host, Path: hc.cookie.Path}
+		if hc.cookie.Secure {
+			u.Scheme = "https"
+		}
+		if u.Path == "" {
+			u.Path = "/"
+		}
+		jar.SetCookies(u, []*http.Cookie{hc.cookie})
+	}
+}
@@ -0,0 +1,18 @@
+package grabber
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// dashboard is the page of the Server showing its jobs. It is a single
+// file polling the API, so there is nothing to build.
+//
+//go:embed dashboard.html
+var dashboard []byte
+
+func handleDashboard(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Security-Policy", "default-src 'self'; img-src 'self'; style-src 'unsafe-inline'; script-src 'unsafe-inline'")
+	w.Write(dashboard)
+}
@@ -0,0 +1,60 @@
+package grabber
+
+import (
+	"encoding/base64"
+	"errors"
+	"mime"
+	"net/url"
+	"strings"
+)
+
+// isDataURL reports whether u holds its content inline, as in
+// data:image/png;base64,iVBOR...
+func isDataURL(u string) bool {
+	return strings.HasPrefix(u, "data:")
+}
+
+// dataURLExtension returns the extension going with the media type a data:
+// url declares, e.g. .png for data:image/png;base64,..., or "" if it
+// declares none that is known.
+func dataURLExtension(u string) string {
+	header, _, _ := strings.Cut(strings.TrimPrefix(u, "data:"), ",")
+	mediaType, _, _ := strings.Cut(header, ";")
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	if ext, ok := imageExtensions[mediaType]; ok {
+		return ext
+	}
+	if exts, err := mime.ExtensionsByType(mediaType); err == nil && len(exts) > 0 {
+		return exts[0]
+	}
+	return ""
+}
+
+// decodeDataURL returns the content of a data: url, which is base64 if the
+// media type ends in ;base64 and percent encoded otherwise.
+func decodeDataURL(u string) ([]byte, error) {
+	header, data, ok := strings.Cut(strings.TrimPrefix(u, "data:"), ",")
+	if !isDataURL(u) || !ok {
+		return nil, errors.New("bad data url")
+	}
+
+	if !strings.HasSuffix(strings.ToLower(header), ";base64") {
+		s, err := url.PathUnescape(data)
+		return []byte(s), err
+	}
+
+	// Spaces and line breaks are common in data urls taken from HTML
+	data = strings.Map(func(r rune) rune {
+		if r == ' ' || r == '\n' || r == '\r' || r == '\t' {
+			return -1
+		}
+		return r
+	}, data)
+	if unescaped, err := url.PathUnescape(data); err == nil {
+		data = unescaped
+	}
+	if b, err := base64.StdEncoding.DecodeString(data); err == nil {
+		return b, nil
+	}
+	return base64.RawStdEncoding.DecodeString(strings.TrimRight(data, "="))
+}
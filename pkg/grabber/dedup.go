@@ -0,0 +1,96 @@
+package grabber
+
+import (
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"sync"
+)
+
+// Dedup says what happens to a download whose content was saved before,
+// typically the same image served under several urls.
+type Dedup string
+
+const (
+	// DedupOff saves every download.
+	DedupOff Dedup = "off"
+	// DedupSkip drops the download, its url is recorded as the old file.
+	DedupSkip Dedup = "skip"
+	// DedupLink saves the download as a hard link to the old file.
+	DedupLink Dedup = "link"
+)
+
+// Set implements flag.Value.
+func (d *Dedup) Set(v string) error {
+	switch Dedup(v) {
+	case DedupOff, DedupSkip, DedupLink:
+		*d = Dedup(v)
+		return nil
+	}
+	return fmt.Errorf("unknown dedup mode %q, want off, skip or link", v)
+}
+
+func (d Dedup) String() string {
+	return string(d)
+}
+
+// enabled reports whether duplicates are looked for at all. The zero value
+// is the same as DedupOff.
+func (d Dedup) enabled() bool {
+	return d != DedupOff && d != ""
+}
+
+// HashIndex maps SHA-256 checksums to the file saved with that content.
+type HashIndex struct {
+	mu    sync.Mutex
+	paths map[string]string
+	// exists tells whether a file is still there, os.Stat if nil.
+	exists func(path string) bool
+}
+
+// NewHashIndex creates an empty index.
+func NewHashIndex() *HashIndex {
+	return &HashIndex{paths: make(map[string]string)}
+}
+
+// lookup returns the file with the checksum sum, or "" if there is none
+// or it was deleted in the meantime.
+func (x *HashIndex) lookup(sum string) string {
+	x.mu.Lock()
+	path := x.paths[sum]
+	x.mu.Unlock()
+
+	if path == "" {
+		return ""
+	}
+	if x.exists != nil {
+		if !x.exists(path) {
+			return ""
+		}
+		return path
+	}
+	if _, err := os.Stat(path); err != nil {
+		return ""
+	}
+	return path
+}
+
+func (x *HashIndex) add(sum, path string) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	x.paths[sum] = path
+}
+
+// hashFile feeds the content of the file at path to h.
+func hashFile(h hash.Hash, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(h, f)
+	return err
+}
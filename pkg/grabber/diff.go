@@ -0,0 +1,61 @@
+package grabber
+
+import "sort"
+
+// NotNewReason is the reason images the manifest has from an earlier run
+// are skipped with Options.Diff.
+const NotNewReason = "not new"
+
+// knows reports whether url was downloaded by an earlier run, whether its
+// file is still there or not.
+func (m *Manifest) knows(url string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, ok := m.entries[url]
+	return ok
+}
+
+// gone returns the urls of the manifest found on one of pages that aren't
+// in found, sorted. Entries recorded without the page they were found on,
+// or found on pages the crawl didn't visit, are left out, as nothing
+// tells they are gone.
+func (m *Manifest) gone(pages, found map[string]bool) []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var urls []string
+	for _, e := range m.entries {
+		if e.Page != "" && pages[e.Page] && !found[e.URL] {
+			urls = append(urls, e.URL)
+		}
+	}
+	sort.Strings(urls)
+	return urls
+}
+
+// diffNew returns the images the manifest doesn't know of, skipping the
+// others, and reports them as added.
+func (g *Grabber) diffNew(images []string) []string {
+	var added []string
+	for _, u := range images {
+		if g.manifest.knows(u) {
+			g.skip(u, NotNewReason)
+			continue
+		}
+		added = append(added, u)
+	}
+	g.failures.diff(added, nil)
+	g.log().Info("compared with the manifest", "new", len(added), "not_new", len(images)-len(added))
+	return added
+}
+
+// diffGone reports the images of the pages the crawl visited the manifest
+// has that it didn't find again as removed.
+func (g *Grabber) diffGone(pages, found map[string]bool) {
+	removed := g.manifest.gone(pages, found)
+	g.failures.diff(nil, removed)
+	for _, u := range removed {
+		g.log().Info("gone from the site", "url", u)
+	}
+}
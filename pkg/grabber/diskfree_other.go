@@ -0,0 +1,10 @@
+//go:build !linux && !darwin && !windows
+
+package grabber
+
+import "errors"
+
+// freeSpace isn't known on this platform.
+func freeSpace(dir string) (uint64, error) {
+	return 0, errors.New("free space unknown")
+}
@@ -0,0 +1,774 @@
+package grabber
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Progress describes how far along a single download is.
+type Progress struct {
+	URL  string
+	File string
+	// Written is the number of bytes in the file so far, including the
+	// part kept from an earlier interrupted run.
+	Written uint64
+	// Total is the expected size of the file, 0 if the server didn't say.
+	Total uint64
+	Done  bool
+}
+
+// WriteCounter counts the number of bytes written to it. By implementing the Write method,
+// it is of the io.Writer interface and we can pass this into io.TeeReader()
+// Every write to this writer will report the progress of the file write.
+type WriteCounter struct {
+	Total    uint64
+	Progress func(total uint64)
+}
+
+func (wc *WriteCounter) Write(p []byte) (int, error) {
+	n := len(p)
+	wc.Total += uint64(n)
+	if wc.Progress != nil {
+		wc.Progress(wc.Total)
+	}
+	return n, nil
+}
+
+// File is a completed download.
+type File struct {
+	URL string
+	// Page is the page the url was found on, if known.
+	Page string
+	Path string
+	Size int64
+	// Status is the HTTP status of the download.
+	Status int
+	// SHA256 is the hex encoded checksum of the content.
+	SHA256 string
+	// ContentType is sniffed from the first bytes of the file.
+	ContentType string
+	// Width and Height are read from the image header, 0 if unknown.
+	Width  int
+	Height int
+	// DuplicateOf is the file that already had the same content, if any.
+	// With DedupSkip Path is that file, with DedupLink Path is a hard link
+	// to it.
+	DuplicateOf string
+	// PerceptualHash is the hex encoded dHash of the image, if computed.
+	PerceptualHash string
+	// SimilarTo is an earlier image this one looks like. With NearDupSkip
+	// Path is that image.
+	SimilarTo string
+	// Thumb is the thumbnail of the image, if one was made.
+	Thumb string
+	// Modified is the Last-Modified time the server sent, zero if none.
+	Modified time.Time
+	// ETag is the entity tag the server sent, if any.
+	ETag string
+	// Variant is the full size url downloaded in place of URL, if any.
+	Variant string
+}
+
+// Downloader saves urls into a directory.
+type Downloader struct {
+	Dir string
+	// Storage is where the files go if Dir is the url of a Storage rather
+	// than a local directory.
+	Storage Storage
+	Client  *http.Client
+	// Headers are sent with every request.
+	Headers map[string]string
+	// Limiter spaces out the requests, shared with the Collector.
+	Limiter *RateLimiter
+	// ImagesOnly rejects downloads that turn out not to be images, or
+	// videos if Videos is set.
+	ImagesOnly bool
+	Videos     bool
+	// Types are the only content types kept, as sniffed before any
+	// conversion. Nil keeps all.
+	Types map[string]bool
+	// FullSize tries the full size versions of the images first, guessed
+	// from the size markers in their urls, falling back to the url found
+	// if none of them downloads. FullSizeRules are tried before the
+	// guesses, and with FullSize off too.
+	FullSize      bool
+	FullSizeRules []*RewriteRule
+	// KeepErrorPages moves the web pages served in place of a file into
+	// ErrorPagesDir instead of removing them.
+	KeepErrorPages bool
+	// Stitch downloads the segments of HLS playlists into a single file.
+	Stitch bool
+	// Filter drops downloads that are too small.
+	Filter SizeFilter
+	// ProbeBytes, with a minimum width or height in Filter, first requests
+	// only this many bytes of an image to check the dimensions in its
+	// header, and the rest only if they pass, which saves fetching the
+	// whole of every thumbnail. Only downloads to Dir are probed.
+	ProbeBytes int64
+	// Dedup says what to do with content that was saved before.
+	Dedup Dedup
+	// Index knows the checksums of the files saved so far.
+	Index *HashIndex
+	// NearDup says what to do with images looking like one saved before,
+	// NearDupDistance is how many bits their dHashes may differ by and
+	// Similar knows the dHashes of the images saved so far.
+	NearDup         NearDup
+	NearDupDistance int
+	Similar         *PerceptualIndex
+	// Convert maps the content types of the images to re-encode to the
+	// ones they become, see RegisterConverter. ConvertQuality is passed
+	// to the converters.
+	Convert        map[string]string
+	ConvertQuality int
+	// Thumbs is the size in pixels of the thumbnails written into
+	// ThumbsDir, 0 writes none.
+	Thumbs int
+	// Fsync flushes every file to the disk before it gets its name, and
+	// its directory after.
+	Fsync bool
+	// TrackingParams are the query parameters left out of the file names,
+	// see Options.TrackingParams.
+	TrackingParams []string
+	// ServerTimes sets the modification time of saved files to their
+	// Last-Modified time.
+	ServerTimes bool
+	// Verify checks downloads against the Content-MD5 or ETag their
+	// server sent, see ChecksumError.
+	Verify bool
+	// Timeout is the longest DownloadFrom and Estimate may take, 0 is no
+	// limit.
+	Timeout time.Duration
+
+	// Logger gets the requests made, nil logs nothing.
+	Logger *slog.Logger
+
+	// Destination, if set, returns the name relative to Dir a url is saved
+	// as, "" for the name it would get.
+	Destination func(url string) string
+	// Subdir, if set, returns the directory relative to Dir a url is saved
+	// in under the name it gets, "" for Dir itself. Destination wins over
+	// it.
+	Subdir func(url string) string
+	// Validators, if set, returns the ETag and Last-Modified time a url
+	// was saved with before. They are sent with its download, which fails
+	// with a SkipError if the server says it didn't change.
+	Validators func(url string) (etag string, modified time.Time)
+	// OnProgress is called while a file is downloading and once more with
+	// Done set when it is complete.
+	OnProgress func(Progress)
+
+	// mu serializes picking final names so that concurrent downloads
+	// can't claim the same one.
+	mu sync.Mutex
+
+	// held is the content of the urls that can't be requested, see Hold.
+	heldMu sync.Mutex
+	held   map[string][]byte
+}
+
+// ErrDownload is wrapped by the errors of downloads that failed, as opposed
+// to being skipped.
+var ErrDownload = errors.New("download failed")
+
+// NewDownloader creates a Downloader saving into dir.
+func NewDownloader(dir string) *Downloader {
+	return &Downloader{Dir: dir, Client: http.DefaultClient, TrackingParams: defaultTrackingParams}
+}
+
+// DownloadFile will download a url and store it in the downloader directory.
+// It is DownloadFrom without a referring page.
+func (d *Downloader) DownloadFile(ctx context.Context, url string) (*File, error) {
+	return d.DownloadFrom(ctx, url, "")
+}
+
+// Hold keeps data as the content of url, which DownloadFrom and Estimate
+// then use instead of requesting url. It is for images that only exist in
+// the browser, like screenshots.
+func (d *Downloader) Hold(url string, data []byte) {
+	d.heldMu.Lock()
+	defer d.heldMu.Unlock()
+
+	if d.held == nil {
+		d.held = make(map[string][]byte)
+	}
+	d.held[url] = data
+}
+
+// heldData returns what Hold keeps for url, dropping it if release is set.
+func (d *Downloader) heldData(url string, release bool) ([]byte, bool) {
+	d.heldMu.Lock()
+	defer d.heldMu.Unlock()
+
+	data, ok := d.held[url]
+	if release {
+		delete(d.held, url)
+	}
+	return data, ok
+}
+
+// DownloadFrom will download a url and store it in the downloader directory.
+// It writes to the destination file as it downloads it, without
+// loading the entire file into memory.
+// We pass an io.TeeReader into Copy() to report progress on the download.
+// If a .tmp file is left over from an interrupted run, only the missing
+// bytes are requested with a Range header and appended to it.
+// The referer, if not empty, is the page the url was found on: it is sent
+// as the Referer header unless d.Headers already has one, which gets past
+// most hotlink protection. The download stops when ctx is done, keeping the
+// .tmp file to resume from. Errors other than a SkipError wrap ErrDownload,
+// and ErrBadURL too for urls that can't be downloaded.
+func (d *Downloader) DownloadFrom(ctx context.Context, url, referer string) (*File, error) {
+	ctx, cancel := withTimeout(ctx, d.Timeout)
+	defer cancel()
+
+	since := d.validatorsOf(url)
+	f, status, err := d.downloadFullSize(ctx, url, referer, since)
+	if f == nil && err == nil {
+		f, status, err = d.download(ctx, url, referer, since)
+	}
+	if f != nil {
+		f.Page, f.Status = referer, status
+	}
+	var skipErr *SkipError
+	if err != nil && !errors.As(err, &skipErr) {
+		return f, fmt.Errorf("%w: %w", ErrDownload, timeoutError(ctx, err, d.Timeout))
+	}
+	return f, err
+}
+
+// download does the work of DownloadFrom, also returning the status of the
+// response. The request is conditional on since, unless it resumes a
+// partial download.
+func (d *Downloader) download(ctx context.Context, url, referer string, since validators) (*File, int, error) {
+	if data, ok := d.heldData(url, false); ok {
+		f, err := d.save(url, data)
+		if err == nil {
+			d.heldData(url, true)
+		}
+		return f, 0, err
+	}
+	if d.Stitch && playlistKind(url) == playlistHLS {
+		f, err := d.stitch(ctx, url, referer)
+		return f, http.StatusOK, err
+	}
+	if isDataURL(url) {
+		data, err := decodeDataURL(url)
+		if err != nil {
+			return nil, 0, &SkipError{Reason: "bad data url"}
+		}
+		f, err := d.save(url, data)
+		return f, 0, err
+	}
+	if err := checkURL(url); err != nil {
+		return nil, 0, err
+	}
+	if d.Storage != nil {
+		return d.downloadStored(ctx, url, referer, since)
+	}
+
+	fileName := filepath.Join(d.Dir, d.fileName(url))
+	tmpName := fileName + "." + shortHash([]byte(url)) + ".tmp"
+
+	// Create the file with .tmp extension, so that we won't overwrite a
+	// file until it's downloaded fully. The hash of the url keeps different
+	// urls with the same file name apart while still finding the partial
+	// file of this url again after an interruption.
+	out, err := os.OpenFile(tmpName, os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer out.Close()
+
+	info, err := out.Stat()
+	if err != nil {
+		return nil, 0, err
+	}
+	offset := info.Size()
+	// resumed is set if the .tmp file was left by an earlier download
+	resumed := offset > 0
+
+	if offset == 0 && d.probes() {
+		n, status, err := d.probe(ctx, out, url, referer, since)
+		if err != nil {
+			out.Close()
+			removeTmp(tmpName)
+			return nil, status, err
+		}
+		offset = n
+	}
+
+	req, err := d.newRequest(ctx, http.MethodGet, url, referer)
+	if err != nil {
+		return nil, 0, err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		// A server whose content changed since sends all of it instead
+		if v := loadIfRange(tmpName); v != "" {
+			req.Header.Set("If-Range", v)
+		}
+	} else {
+		since.set(req)
+	}
+
+	// Get the data
+	if err := d.Limiter.Wait(ctx, req.URL.Host); err != nil {
+		return nil, 0, err
+	}
+	orDiscard(d.Logger).Debug("requesting", "url", url, "offset", offset)
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified && offset == 0:
+		out.Close()
+		removeTmp(tmpName)
+		return nil, resp.StatusCode, notModified()
+	case resp.StatusCode == http.StatusPartialContent && offset > 0 && rangeStart(resp) == offset:
+		// The server honoured the range, append the rest
+	case resumed && (resp.StatusCode == http.StatusPartialContent ||
+		resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && !rangeComplete(resp, offset)):
+		// The partial file doesn't fit the content anymore, as it changed
+		// or shrank since, start over
+		orDiscard(d.Logger).Debug("partial download is stale, starting over", "url", url, "offset", offset)
+		resp.Body.Close()
+		out.Close()
+		removeTmp(tmpName)
+		return d.download(ctx, url, referer, since)
+	case resp.StatusCode == http.StatusPartialContent && offset > 0:
+		out.Close()
+		removeTmp(tmpName)
+		return nil, resp.StatusCode, fmt.Errorf("server sent the content from byte %d instead of %d", rangeStart(resp), offset)
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && offset > 0 && rangeComplete(resp, offset):
+		// The partial file already holds everything
+		h := sha256.New()
+		if err := hashFile(h, tmpName); err != nil {
+			return nil, 0, err
+		}
+		p := Progress{URL: url, File: fileName, Written: uint64(offset), Total: uint64(offset)}
+		f, err := d.finish(out, tmpName, p, hex.EncodeToString(h.Sum(nil)))
+		os.Remove(rangeFile(tmpName))
+		d.setModified(f, resp)
+		return f, resp.StatusCode, err
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		// No range support (or nothing to resume), start from scratch
+		offset = 0
+	default:
+		return nil, resp.StatusCode, newStatusError(resp)
+	}
+
+	if err := out.Truncate(offset); err != nil {
+		return nil, 0, err
+	}
+	if _, err := out.Seek(offset, io.SeekStart); err != nil {
+		return nil, 0, err
+	}
+	if offset == 0 {
+		if err := saveIfRange(tmpName, resp); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	// Opaque download endpoints often only have the real name in the header
+	if name := dispositionFileName(resp); name != "" {
+		fileName = filepath.Join(d.Dir, name)
+	}
+
+	p := Progress{URL: url, File: fileName}
+	if resp.ContentLength > 0 {
+		p.Total = uint64(offset + resp.ContentLength)
+
+		// Don't bother downloading what the size filter would drop anyway
+		if err := d.Filter.checkBytes(int64(p.Total)); err != nil {
+			out.Close()
+			removeTmp(tmpName)
+			return nil, 0, err
+		}
+		if err := d.checkSpace(resp.ContentLength); err != nil {
+			out.Close()
+			if offset == 0 {
+				removeTmp(tmpName)
+			}
+			return nil, 0, err
+		}
+	}
+
+	// The checksum is computed while streaming, a resumed download first
+	// feeds it the part that is already on disk
+	h := sha256.New()
+	if offset > 0 {
+		if err := hashFile(h, tmpName); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	// Create our bytes counter and pass it to be used alongside our writer
+	counter := &WriteCounter{Total: uint64(offset), Progress: func(total uint64) {
+		p.Written = total
+		d.progress(p)
+	}}
+	var body io.Reader = resp.Body
+	if c := d.checksums(resp); c != nil {
+		if offset > 0 {
+			if err := hashFile(c.whole, tmpName); err != nil {
+				return nil, 0, err
+			}
+		}
+		body = c
+	}
+	_, err = io.Copy(io.MultiWriter(out, h), io.TeeReader(d.Filter.limit(body, offset), counter))
+	if err != nil {
+		// Resuming would keep the damaged part, or go on with a file
+		// that is too big
+		var ce *ChecksumError
+		var se *SkipError
+		if errors.As(err, &ce) || errors.As(err, &se) {
+			out.Close()
+			removeTmp(tmpName)
+		}
+		return nil, 0, err
+	}
+
+	f, err := d.finish(out, tmpName, p, hex.EncodeToString(h.Sum(nil)))
+	os.Remove(rangeFile(tmpName))
+	d.setModified(f, resp)
+	return f, resp.StatusCode, err
+}
+
+// setModified records the ETag and Last-Modified time of resp in f, and
+// with ServerTimes makes the latter the modification time of the saved
+// file. Files that only point to one saved before keep their times, and so
+// do the files of a Storage.
+func (d *Downloader) setModified(f *File, resp *http.Response) {
+	if f == nil {
+		return
+	}
+	f.ETag = resp.Header.Get("ETag")
+	t, err := http.ParseTime(resp.Header.Get("Last-Modified"))
+	if err != nil {
+		return
+	}
+	f.Modified = t
+
+	if !d.ServerTimes || d.Storage != nil || f.DuplicateOf != "" || f.SimilarTo != "" {
+		return
+	}
+	if err := os.Chtimes(f.Path, t, t); err != nil {
+		orDiscard(d.Logger).Warn("can't set the modification time", "path", f.Path, "err", err)
+	}
+}
+
+// save stores data as the content of url, going through the same checks
+// and naming as a download.
+func (d *Downloader) save(url string, data []byte) (*File, error) {
+	if d.Storage != nil {
+		return d.store(url, d.heldFileName(url), bytes.NewReader(data), uint64(len(data)))
+	}
+
+	fileName := filepath.Join(d.Dir, d.heldFileName(url))
+	tmpName := fileName + "." + shortHash([]byte(url)) + ".tmp"
+
+	out, err := os.Create(tmpName)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := out.Write(data); err != nil {
+		out.Close()
+		os.Remove(tmpName)
+		return nil, err
+	}
+
+	sum := sha256.Sum256(data)
+	p := Progress{URL: url, File: fileName, Written: uint64(len(data)), Total: uint64(len(data))}
+	return d.finish(out, tmpName, p, hex.EncodeToString(sum[:]))
+}
+
+// heldFileName is the name held or inline content of url is saved as,
+// before the extension is fixed: the file name of the url or, if it has none
+// as data: and blob: urls, a hash of it.
+func (d *Downloader) heldFileName(url string) string {
+	if isDataURL(url) {
+		return shortHash([]byte(url)) + dataURLExtension(url)
+	}
+	return d.fileName(url)
+}
+
+// fileName is the name url is downloaded as, before the extension
+// is fixed: its file name or, if it has none as https://host/photos/, a
+// hash of it, so it never ends up as Dir itself.
+func (d *Downloader) fileName(url string) string {
+	if name := getFileName(url, d.TrackingParams); name != "" {
+		return name
+	}
+	return shortHash([]byte(url))
+}
+
+// newRequest creates a request for url with d.Headers and the referer set,
+// ending with ctx.
+func (d *Downloader) newRequest(ctx context.Context, method, url, referer string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range d.Headers {
+		req.Header.Set(k, v)
+	}
+	if referer != "" && req.Header.Get("Referer") == "" {
+		req.Header.Set("Referer", referer)
+	}
+	return req, nil
+}
+
+func (d *Downloader) progress(p Progress) {
+	if d.OnProgress != nil {
+		d.OnProgress(p)
+	}
+}
+
+// finish closes the tmp file and renames it back to the original file, with
+// the extension corrected to match what the content really is and a hash
+// appended if a different file already has that name. Web pages served in
+// place of the file fail with an ErrorPageError, and if only images are
+// wanted anything else is removed instead.
+func (d *Downloader) finish(out *os.File, tmpName string, p Progress, sum string) (*File, error) {
+	if err := out.Close(); err != nil {
+		return nil, err
+	}
+
+	contentType, err := sniffType(tmpName)
+	if err != nil {
+		return nil, err
+	}
+	if isErrorPage(p.URL, contentType) {
+		return nil, d.errorPage(p.URL, tmpName, contentType)
+	}
+	if err := d.checkType(contentType); err != nil {
+		os.Remove(tmpName)
+		return nil, err
+	}
+	if contentType, err = d.convertFile(p.URL, tmpName, contentType, &p, &sum); err != nil {
+		os.Remove(tmpName)
+		return nil, err
+	}
+	if d.ImagesOnly && !strings.HasPrefix(contentType, "image/") &&
+		!(d.Videos && strings.HasPrefix(contentType, "video/")) {
+		os.Remove(tmpName)
+		return nil, &notImageError{ContentType: contentType}
+	}
+
+	if err := d.Filter.checkBytes(int64(p.Written)); err != nil {
+		os.Remove(tmpName)
+		return nil, err
+	}
+	width, height, err := d.Filter.checkDimensions(tmpName)
+	if err != nil {
+		os.Remove(tmpName)
+		return nil, err
+	}
+	t := d.makeThumb(func() (io.ReadCloser, error) { return os.Open(tmpName) }, contentType)
+
+	if name := d.destinationOf(p.URL); name != "" {
+		p.File = filepath.Join(d.Dir, name)
+	} else if dir := d.subdirOf(p.URL); dir != "" {
+		p.File = filepath.Join(d.Dir, dir, filepath.Base(p.File))
+	}
+	if filepath.Dir(p.File) != filepath.Clean(d.Dir) {
+		if err := os.MkdirAll(filepath.Dir(p.File), 0700); err != nil {
+			os.Remove(tmpName)
+			return nil, err
+		}
+	}
+	d.mu.Lock()
+	name, err := claimName(fixExtension(p.File, contentType), int64(p.Written), sum)
+	var original string
+	if err == nil {
+		original, err = d.dedupe(tmpName, name, sum)
+	}
+	d.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	f := &File{
+		URL:         p.URL,
+		Path:        name,
+		Size:        int64(p.Written),
+		SHA256:      sum,
+		ContentType: contentType,
+		Width:       width,
+		Height:      height,
+		DuplicateOf: original,
+	}
+	if d.Dedup == DedupSkip && original != "" {
+		f.Path = original
+		return f, d.saveThumb(f, t)
+	}
+	if original == "" && d.NearDup.enabled() && d.Similar != nil {
+		if err := d.compareSimilar(f); err != nil {
+			return nil, err
+		}
+		if d.NearDup == NearDupSkip && f.SimilarTo != "" {
+			return f, nil
+		}
+	}
+	if err := d.saveThumb(f, t); err != nil {
+		return nil, err
+	}
+
+	p.File = name
+	p.Done = true
+	d.progress(p)
+
+	return f, nil
+}
+
+// destinationOf returns the name Destination picks for url.
+func (d *Downloader) destinationOf(url string) string {
+	if d.Destination == nil {
+		return ""
+	}
+	return d.Destination(url)
+}
+
+// subdirOf returns the directory Subdir picks for url.
+func (d *Downloader) subdirOf(url string) string {
+	if d.Subdir == nil {
+		return ""
+	}
+	return d.Subdir(url)
+}
+
+// compareSimilar looks for a saved image that looks like f. With NearDupSkip
+// the new file is removed and f points to the old one instead. Files that
+// can't be decoded as images are left alone.
+func (d *Downloader) compareSimilar(f *File) error {
+	hash, err := fileDHash(f.Path)
+	if err != nil {
+		return nil
+	}
+	f.PerceptualHash = hash
+
+	similar, err := d.Similar.match(hash, f.Path, d.NearDupDistance)
+	if err != nil || similar == "" {
+		return err
+	}
+	f.SimilarTo = similar
+	if d.NearDup != NearDupSkip {
+		return nil
+	}
+
+	info, err := os.Stat(similar)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(f.Path); err != nil {
+		return err
+	}
+	f.Path, f.Size, f.SHA256, f.PerceptualHash = similar, info.Size(), "", ""
+	return nil
+}
+
+// dedupe moves the tmp file to name, unless its content is already saved
+// elsewhere: then depending on d.Dedup it is dropped or hard linked to the
+// existing file, whose name is returned.
+func (d *Downloader) dedupe(tmpName, name, sum string) (string, error) {
+	original := ""
+	if d.Dedup.enabled() && d.Index != nil {
+		original = d.Index.lookup(sum)
+	}
+	if original == "" || original == name {
+		if d.Index != nil {
+			d.Index.add(sum, name)
+		}
+		return "", d.rename(tmpName, name)
+	}
+
+	if d.Dedup == DedupLink {
+		os.Remove(name)
+		if err := os.Link(original, name); err != nil {
+			// No hard links on this file system, keep the copy
+			return "", d.rename(tmpName, name)
+		}
+	}
+
+	return original, os.Remove(tmpName)
+}
+
+// rangeComplete reports whether a 416 response says the resource is exactly
+// size bytes long, i.e. "Content-Range: bytes */size".
+func rangeComplete(resp *http.Response, size int64) bool {
+	total := strings.TrimPrefix(resp.Header.Get("Content-Range"), "bytes */")
+	return total == strconv.FormatInt(size, 10)
+}
+
+// rangeStart returns where the content of a 206 response starts, from its
+// "Content-Range: bytes start-end/total", -1 if it doesn't say.
+func rangeStart(resp *http.Response) int64 {
+	cr := strings.TrimPrefix(resp.Header.Get("Content-Range"), "bytes ")
+	start, _, ok := strings.Cut(cr, "-")
+	if !ok {
+		return -1
+	}
+	n, err := strconv.ParseInt(start, 10, 64)
+	if err != nil {
+		return -1
+	}
+	return n
+}
+
+// rangeFile is the file kept next to the .tmp file tmpName with the
+// validator its content came with, so resuming it only appends to the same
+// content. It ends in .tmp too, to be swept along with it.
+func rangeFile(tmpName string) string {
+	return strings.TrimSuffix(tmpName, ".tmp") + ".range.tmp"
+}
+
+// saveIfRange keeps the validator of resp for resuming the .tmp file
+// tmpName its content goes to: its ETag if it is a strong one, as If-Range
+// takes no other, or else its Last-Modified time.
+func saveIfRange(tmpName string, resp *http.Response) error {
+	v := resp.Header.Get("ETag")
+	if v == "" || strings.HasPrefix(v, "W/") {
+		v = resp.Header.Get("Last-Modified")
+	}
+	if v == "" {
+		if err := os.Remove(rangeFile(tmpName)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	return os.WriteFile(rangeFile(tmpName), []byte(v+"\n"), 0644)
+}
+
+// loadIfRange returns the validator saveIfRange kept for tmpName, "" if
+// there is none.
+func loadIfRange(tmpName string) string {
+	b, err := os.ReadFile(rangeFile(tmpName))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
+
+// removeTmp removes the .tmp file tmpName along with its rangeFile.
+func removeTmp(tmpName string) {
+	os.Remove(tmpName)
+	os.Remove(rangeFile(tmpName))
+}
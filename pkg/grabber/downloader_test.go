@@ -0,0 +1,68 @@
+package grabber
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// serveContent serves content with Range and If-Range support, tagged etag.
+func serveContent(content, etag string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Content-Type", "application/octet-stream")
+		http.ServeContent(w, r, "", time.Time{}, strings.NewReader(content))
+	}))
+}
+
+func TestDownloadStalePartial(t *testing.T) {
+	content := strings.Repeat("new content ", 10)
+	for name, tmp := range map[string]struct{ data, ifRange string }{
+		// 416 without being complete
+		"longer": {data: content + "and more than there is now"},
+		// If-Range fails, the server sends it all
+		"changed": {data: "old content", ifRange: `"v1"`},
+		// Without a validator the partial file is only kept if it fits
+		"resumed": {data: content[:20]},
+	} {
+		t.Run(name, func(t *testing.T) {
+			srv := serveContent(content, `"v2"`)
+			defer srv.Close()
+			d := NewDownloader(t.TempDir())
+			url := srv.URL + "/data.bin"
+
+			tmpName := filepath.Join(d.Dir, d.fileName(url)) + "." + shortHash([]byte(url)) + ".tmp"
+			if err := os.WriteFile(tmpName, []byte(tmp.data), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if tmp.ifRange != "" {
+				if err := os.WriteFile(rangeFile(tmpName), []byte(tmp.ifRange+"\n"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			f, err := d.DownloadFile(context.Background(), url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := os.ReadFile(f.Path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, []byte(content)) {
+				t.Errorf("downloaded %q, want %q", got, content)
+			}
+			for _, p := range []string{tmpName, rangeFile(tmpName)} {
+				if _, err := os.Stat(p); err == nil {
+					t.Errorf("%s left behind", filepath.Base(p))
+				}
+			}
+		})
+	}
+}
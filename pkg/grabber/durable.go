@@ -0,0 +1,186 @@
+package grabber
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// runningFile starts the name of the mark a run with Options.Fsync writes
+// into the output directory, holding its pid and processInstance, and
+// Close removes. A mark left over by a process that isn't running anymore,
+// as after a reboot even if another process got its pid, tells the next
+// run that the machine went down during that one.
+const runningFile = ".grab-running"
+
+// runs numbers the marks of the runs of this process, as several may write
+// to the same directory.
+var runs atomic.Int64
+
+// quarantineDir is where the .tmp files of a run that went down are moved
+// to, in the output directory.
+const quarantineDir = ".grab-quarantine"
+
+// rename moves the downloaded tmp file to name. With Fsync the content is
+// flushed to the disk first and the directory of name after, so a power
+// loss leaves either the whole file under its name or none.
+func (d *Downloader) rename(tmpName, name string) error {
+	if !d.Fsync {
+		return os.Rename(tmpName, name)
+	}
+	if err := syncFile(tmpName); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpName, name); err != nil {
+		return err
+	}
+	return syncDir(filepath.Dir(name))
+}
+
+// syncFile flushes the content of the file at path to the disk.
+func syncFile(path string) error {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// syncDir flushes the entries of dir to the disk. Windows can't sync a
+// directory, and doesn't need to.
+func syncDir(dir string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	f, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return f.Sync()
+}
+
+// sweepTmp quarantines the .tmp files of the output directory if a run
+// with Options.Fsync went down there without finishing, as they may hold
+// blocks never written, which resuming would keep, then marks the
+// directory as written to by this run. While another run is still going
+// on there its .tmp files are in use, and are left for a later run to
+// quarantine. The .tmp files of runs that were stopped are left to resume
+// from.
+func (g *Grabber) sweepTmp() error {
+	dir := g.Options.Dir
+	marks, err := filepath.Glob(filepath.Join(dir, runningFile+"*"))
+	if err != nil {
+		return err
+	}
+	var dead []string
+	live := false
+	for _, m := range marks {
+		b, err := os.ReadFile(m)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return err
+		}
+		if markAlive(string(b)) {
+			live = true
+		} else {
+			dead = append(dead, m)
+		}
+	}
+	if len(dead) > 0 && live {
+		g.log().Warn("a run went down, leaving its partial downloads while another run is going on", "dir", dir)
+	} else if len(dead) > 0 {
+		n, err := quarantineTmp(dir)
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			g.log().Warn("a run went down, quarantined its partial downloads", "files", n, "dir", filepath.Join(dir, quarantineDir))
+		}
+		for _, m := range dead {
+			if err := os.Remove(m); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return err
+			}
+		}
+	}
+
+	pid := os.Getpid()
+	mark := filepath.Join(dir, fmt.Sprintf("%s.%d-%d", runningFile, pid, runs.Add(1)))
+	if err := os.WriteFile(mark, []byte(fmt.Sprintf("%d %s\n", pid, processInstance(pid))), 0600); err != nil {
+		return err
+	}
+	g.running = mark
+	return syncDir(dir)
+}
+
+// markAlive reports whether the run that wrote the running mark content
+// is still going on: its process is running and is the same one, not
+// another that got its pid since. Marks without the processInstance are
+// only told by the pid.
+func markAlive(content string) bool {
+	fields := strings.Fields(content)
+	if len(fields) == 0 {
+		return false
+	}
+	pid, err := strconv.Atoi(fields[0])
+	if err != nil || !processAlive(pid) {
+		return false
+	}
+	return len(fields) < 2 || fields[1] == processInstance(pid)
+}
+
+// quarantineTmp moves the .tmp files of dir to its quarantineDir, under
+// the same path they had in dir, and returns how many it moved.
+func quarantineTmp(dir string) (int, error) {
+	quarantine := filepath.Join(dir, quarantineDir)
+	n := 0
+	err := filepath.WalkDir(dir, func(path string, e fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if e.IsDir() {
+			if path == quarantine {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(e.Name(), ".tmp") {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		to := filepath.Join(quarantine, rel)
+		if err := os.MkdirAll(filepath.Dir(to), 0700); err != nil {
+			return err
+		}
+		n++
+		return os.Rename(path, to)
+	})
+	return n, err
+}
+
+// endRun removes the mark sweepTmp put on the output directory, as the
+// run finished.
+func (g *Grabber) endRun() {
+	if g.running == "" {
+		return
+	}
+	if err := os.Remove(g.running); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		g.log().Warn("can't remove the running mark", "err", err)
+	}
+	g.running = ""
+}
@@ -0,0 +1,53 @@
+package grabber
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSweepTmp(t *testing.T) {
+	pid := os.Getpid()
+	if processInstance(pid) == "" {
+		t.Skip("processes can't be told apart on this platform")
+	}
+	for name, test := range map[string]struct {
+		mark        string
+		quarantined bool
+	}{
+		// The pid went to this process after a reboot
+		"rebooted": {mark: fmt.Sprintf("%d another-boot\n", pid), quarantined: true},
+		"running":  {mark: fmt.Sprintf("%d %s\n", pid, processInstance(pid))},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			opts := DefaultOptions()
+			opts.Dir = dir
+			g, err := New(opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer g.Close()
+
+			tmp := filepath.Join("host", "photo.jpg.abc123.tmp")
+			if err := os.MkdirAll(filepath.Join(dir, "host"), 0700); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(dir, tmp), []byte("part"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(dir, runningFile+".1-1"), []byte(test.mark), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := g.sweepTmp(); err != nil {
+				t.Fatal(err)
+			}
+			_, err = os.Stat(filepath.Join(dir, quarantineDir, tmp))
+			if quarantined := err == nil; quarantined != test.quarantined {
+				t.Errorf("quarantined = %v, want %v", quarantined, test.quarantined)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package grabber
+
+import (
+	"encoding/json"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+)
+
+// embeddedJSONSelector selects the scripts holding JSON data: JSON-LD and
+// the state single page apps ship with, like __NEXT_DATA__ of Next.js.
+const embeddedJSONSelector = `script[type="application/ld+json"], script[type="application/json"], script#__NEXT_DATA__`
+
+// imageKeys are the JSON keys whose string values are image urls, lower
+// case. "url" only counts inside a schema.org ImageObject.
+var imageKeys = map[string]bool{
+	"image":        true,
+	"images":       true,
+	"thumbnail":    true,
+	"thumbnailurl": true,
+	"contenturl":   true,
+	"src":          true,
+	"photo":        true,
+	"photos":       true,
+}
+
+// embeddedImages returns the image urls in a JSON document: the strings
+// under one of imageKeys, and any other string that is a url ending in an
+// image extension. Documents that don't parse have none.
+func embeddedImages(data []byte) []string {
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil
+	}
+
+	var images []string
+	var walk func(v interface{}, imageKey bool)
+	walk = func(v interface{}, imageKey bool) {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			// Sorted so the images come out in the same order every time
+			keys := make([]string, 0, len(v))
+			for k := range v {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+
+			isImageObject := v["@type"] == "ImageObject"
+			for _, k := range keys {
+				lower := strings.ToLower(k)
+				walk(v[k], imageKeys[lower] || (isImageObject && lower == "url"))
+			}
+		case []interface{}:
+			for _, child := range v {
+				walk(child, imageKey)
+			}
+		case string:
+			if looksLikeURL(v) && (imageKey || hasImageExtension(v)) {
+				images = append(images, v)
+			}
+		}
+	}
+	walk(doc, false)
+
+	return images
+}
+
+// looksLikeURL reports whether s is an absolute, protocol relative or root
+// relative url.
+func looksLikeURL(s string) bool {
+	return strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://") ||
+		(strings.HasPrefix(s, "/") && !strings.ContainsAny(s, " \n"))
+}
+
+// hasImageExtension reports whether the path of the url s ends in the
+// extension of an image type.
+func hasImageExtension(s string) bool {
+	u, err := url.Parse(s)
+	if err != nil {
+		return false
+	}
+	ext := strings.ToLower(path.Ext(u.Path))
+	if alias, ok := extensionAliases[ext]; ok {
+		ext = alias
+	}
+	return isImageExtension(ext)
+}
@@ -0,0 +1,101 @@
+package grabber
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ErrorPagesDir is the directory, next to the downloaded files, that the
+// error pages served in place of files are kept in to look into.
+const ErrorPagesDir = "error-pages"
+
+// ErrorPageError is returned for a download that turned out to be a web
+// page, like the "please log in" or "not found" pages some hosts answer
+// image urls with even with a 200. Downloading it again won't change that.
+type ErrorPageError struct {
+	ContentType string
+	// Saved is where the page was kept, empty if it wasn't.
+	Saved string
+}
+
+func (e *ErrorPageError) Error() string {
+	msg := "got a " + e.ContentType + " page instead of the file"
+	if e.Saved != "" {
+		msg += ", kept as " + e.Saved
+	}
+	return msg
+}
+
+// isErrorPage reports whether content of contentType downloaded from url is
+// a page rather than the file: HTML or XML always, other text when the
+// extension of url says it is an image or a video.
+func isErrorPage(url, contentType string) bool {
+	switch contentType {
+	case "text/html", "text/xml":
+		return true
+	}
+	return strings.HasPrefix(contentType, "text/") && mediaKind(url) != ""
+}
+
+// errorPageName returns the name in ErrorPagesDir the error page of url is
+// kept as.
+func (d *Downloader) errorPageName(url, contentType string) string {
+	name := getFileName(url, d.TrackingParams)
+	stem := strings.TrimSuffix(name, path.Ext(name))
+	if stem == "" {
+		stem = "page"
+	}
+	ext := ".txt"
+	switch contentType {
+	case "text/html":
+		ext = ".html"
+	case "text/xml":
+		ext = ".xml"
+	}
+	return path.Join(ErrorPagesDir, stem+"-"+shortHash([]byte(url))+ext)
+}
+
+// errorPage returns the ErrorPageError of the error page of url in the tmp
+// file tmpName, moving the file into ErrorPagesDir if d.KeepErrorPages is
+// set and removing it if not.
+func (d *Downloader) errorPage(url, tmpName, contentType string) error {
+	e := &ErrorPageError{ContentType: contentType}
+	if !d.KeepErrorPages {
+		os.Remove(tmpName)
+		return e
+	}
+
+	name := d.errorPageName(url, contentType)
+	p := filepath.Join(d.Dir, filepath.FromSlash(name))
+	err := os.MkdirAll(filepath.Dir(p), 0755)
+	if err == nil {
+		err = os.Rename(tmpName, p)
+	}
+	if err != nil {
+		orDiscard(d.Logger).Warn("error page not kept", "url", url, "err", err)
+		os.Remove(tmpName)
+		return e
+	}
+	e.Saved = p
+	return e
+}
+
+// storedErrorPage is errorPage for d.Storage, where only its start, head,
+// is kept.
+func (d *Downloader) storedErrorPage(url, tmpName, contentType string, head []byte) error {
+	d.Storage.Remove(tmpName)
+	e := &ErrorPageError{ContentType: contentType}
+	if !d.KeepErrorPages {
+		return e
+	}
+
+	name := d.errorPageName(url, contentType)
+	if err := d.writeStored(name, head); err != nil {
+		orDiscard(d.Logger).Warn("error page not kept", "url", url, "err", err)
+		return e
+	}
+	e.Saved = d.location(name)
+	return e
+}
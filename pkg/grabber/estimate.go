@@ -0,0 +1,132 @@
+package grabber
+
+import (
+	"context"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Estimate is what a download would be, as told by the server without
+// fetching the content.
+type Estimate struct {
+	URL  string
+	Page string
+	// Path is the file the download would most likely be saved as. Name
+	// clashes are only settled by the real download.
+	Path string
+	// Size is the expected size in bytes, -1 if the server didn't say.
+	Size        int64
+	ContentType string
+}
+
+// Estimate asks the server about url with a HEAD request, falling back to
+// fetching its first byte if HEAD isn't allowed, and works out where it
+// would be saved. Nothing is written to disk.
+func (d *Downloader) Estimate(ctx context.Context, url, referer string) (*Estimate, error) {
+	data, ok := d.heldData(url, false)
+	if isDataURL(url) {
+		var err error
+		if data, err = decodeDataURL(url); err != nil {
+			return nil, err
+		}
+		ok = true
+	}
+	if ok {
+		ct := sniffBytes(data)
+		path := fixExtension(d.location(d.heldFileName(url)), ct)
+		return &Estimate{URL: url, Page: referer, Path: path, Size: int64(len(data)), ContentType: ct}, nil
+	}
+
+	if err := checkURL(url); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := withTimeout(ctx, d.Timeout)
+	defer cancel()
+	resp, err := d.head(ctx, url, referer)
+	if err != nil {
+		return nil, timeoutError(ctx, err, d.Timeout)
+	}
+	resp.Body.Close()
+
+	e := &Estimate{URL: url, Page: referer, Size: resp.ContentLength}
+	if resp.StatusCode == http.StatusPartialContent {
+		e.Size = contentRangeTotal(resp)
+	}
+	if ct, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type")); err == nil {
+		e.ContentType = ct
+	}
+	// The size is what is downloaded, converting changes only the type
+	if to := d.Convert[e.ContentType]; to != "" {
+		e.ContentType = to
+	}
+
+	name := d.fileName(url)
+	if n := dispositionFileName(resp); n != "" {
+		name = n
+	}
+	e.Path = fixExtension(d.location(name), e.ContentType)
+
+	return e, nil
+}
+
+// head sends a HEAD request for url, or a GET of its first byte if the
+// server doesn't support HEAD.
+func (d *Downloader) head(ctx context.Context, url, referer string) (*http.Response, error) {
+	req, err := d.newRequest(ctx, http.MethodHead, url, referer)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.Limiter.Wait(ctx, req.URL.Host); err != nil {
+		return nil, err
+	}
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusMethodNotAllowed && resp.StatusCode != http.StatusNotImplemented {
+		return checkStatus(resp)
+	}
+	resp.Body.Close()
+
+	req, err = d.newRequest(ctx, http.MethodGet, url, referer)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Range", "bytes=0-0")
+	if err := d.Limiter.Wait(ctx, req.URL.Host); err != nil {
+		return nil, err
+	}
+	resp, err = d.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	return checkStatus(resp)
+}
+
+// checkStatus returns resp if it is a success, or closes it and returns
+// the status error.
+func checkStatus(resp *http.Response) (*http.Response, error) {
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		resp.Body.Close()
+		return nil, newStatusError(resp)
+	}
+	return resp, nil
+}
+
+// contentRangeTotal returns the complete length from a Content-Range like
+// "bytes 0-0/1234", -1 if it is unknown.
+func contentRangeTotal(resp *http.Response) int64 {
+	cr := resp.Header.Get("Content-Range")
+	i := strings.LastIndex(cr, "/")
+	if i < 0 {
+		return -1
+	}
+	n, err := strconv.ParseInt(cr[i+1:], 10, 64)
+	if err != nil {
+		return -1
+	}
+	return n
+}
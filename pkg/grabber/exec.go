@@ -0,0 +1,92 @@
+package grabber
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// execPool runs Options.Exec for the saved files, at most Options.ExecJobs
+// at the same time, while the downloads go on.
+type execPool struct {
+	command string
+	slots   chan struct{}
+	wg      sync.WaitGroup
+}
+
+func newExecPool(opts Options) *execPool {
+	if opts.Exec == "" || opts.DryRun {
+		return nil
+	}
+	n := opts.ExecJobs
+	if n < 1 {
+		n = runtime.NumCPU()
+	}
+	return &execPool{command: opts.Exec, slots: make(chan struct{}, n)}
+}
+
+// runExec runs the command for f once a slot is free. A command that fails
+// fails the url of f, though the file stays downloaded. The command is
+// killed when ctx is done.
+func (g *Grabber) runExec(ctx context.Context, f *File) {
+	p := g.exec
+	p.slots <- struct{}{}
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		defer func() { <-p.slots }()
+
+		line := execLine(p.command, f.Path)
+		cmd := shellCommand(ctx, line)
+		cmd.Env = append(os.Environ(), "GRAB_URL="+f.URL, "GRAB_PAGE="+f.Page, "GRAB_FILE="+f.Path)
+		var out bytes.Buffer
+		cmd.Stdout, cmd.Stderr = &out, &out
+		if err := cmd.Run(); err != nil {
+			msg := strings.TrimSpace(out.String())
+			if msg != "" {
+				err = fmt.Errorf("%v: %s", err, msg)
+			}
+			g.fail(Failure{URL: f.URL, Err: fmt.Errorf("exec: %v", err), Attempts: 1})
+			return
+		}
+		g.log().Info("ran", "command", line, "output", strings.TrimSpace(out.String()))
+	}()
+}
+
+// wait waits for the commands running, if there is a pool.
+func (p *execPool) wait() {
+	if p != nil {
+		p.wg.Wait()
+	}
+}
+
+// execLine puts path into command in place of every {}, or at the end if
+// there are none, quoted for the shell.
+func execLine(command, path string) string {
+	quoted := shellQuote(path)
+	if strings.Contains(command, "{}") {
+		return strings.ReplaceAll(command, "{}", quoted)
+	}
+	return command + " " + quoted
+}
+
+// shellCommand runs line with the shell of the system.
+func shellCommand(ctx context.Context, line string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd", "/C", line)
+	}
+	return exec.CommandContext(ctx, "/bin/sh", "-c", line)
+}
+
+// shellQuote quotes s as a single argument for the shell of shellCommand.
+func shellQuote(s string) string {
+	if runtime.GOOS == "windows" {
+		return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
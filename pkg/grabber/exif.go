@@ -0,0 +1,79 @@
+package grabber
+
+import (
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/rwcarlsen/goexif/exif"
+)
+
+// Exif sums up the EXIF data of a photo.
+type Exif struct {
+	Make  string `json:"make,omitempty"`
+	Model string `json:"model,omitempty"`
+	Lens  string `json:"lens,omitempty"`
+	// Taken is when the photo was taken, zero if unknown.
+	Taken time.Time `json:"taken,omitzero"`
+	// Lat and Lon are where it was taken if HasGPS is set.
+	HasGPS bool    `json:"has_gps,omitempty"`
+	Lat    float64 `json:"lat,omitempty"`
+	Lon    float64 `json:"lon,omitempty"`
+}
+
+// readExif reads the EXIF data of the JPEG or TIFF image in r, nil if it
+// has none.
+func readExif(r io.Reader) *Exif {
+	x, err := exif.Decode(r)
+	if err != nil {
+		return nil
+	}
+
+	e := &Exif{
+		Make:  exifString(x, exif.Make),
+		Model: exifString(x, exif.Model),
+		Lens:  exifString(x, exif.LensModel),
+	}
+	if t, err := x.DateTime(); err == nil {
+		e.Taken = t
+	}
+	if lat, lon, err := x.LatLong(); err == nil {
+		e.HasGPS, e.Lat, e.Lon = true, lat, lon
+	}
+	return e
+}
+
+func exifString(x *exif.Exif, name exif.FieldName) string {
+	tag, err := x.Get(name)
+	if err != nil {
+		return ""
+	}
+	s, err := tag.StringVal()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(strings.TrimRight(s, "\x00"))
+}
+
+// fileExif reads the EXIF data of the saved file f, nil if it has none or
+// can't be read back.
+func (d *Downloader) fileExif(f *File) *Exif {
+	if !strings.HasPrefix(f.ContentType, "image/") {
+		return nil
+	}
+
+	var r io.ReadCloser
+	var err error
+	if d.Storage != nil {
+		r, err = d.Storage.Open(d.relative(f.Path))
+	} else {
+		r, err = os.Open(f.Path)
+	}
+	if err != nil {
+		return nil
+	}
+	defer r.Close()
+
+	return readExif(r)
+}
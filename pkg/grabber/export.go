@@ -0,0 +1,55 @@
+package grabber
+
+import (
+	"encoding/csv"
+	"os"
+	"sync"
+)
+
+// linkWriter writes the discovered urls to a CSV file with a type, url and
+// page column, the type being "photo" for photo detail pages and "image"
+// for images.
+type linkWriter struct {
+	mu sync.Mutex
+	f  *os.File
+	w  *csv.Writer
+}
+
+// createLinks creates the CSV file at path and writes its header.
+func createLinks(path string) (*linkWriter, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &linkWriter{f: f, w: csv.NewWriter(f)}
+	if err := w.w.Write([]string{"type", "url", "page"}); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+// write adds a row of kind for every url, with the page it was found on
+// from sources.
+func (w *linkWriter) write(kind string, urls []string, sources map[string]string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, u := range urls {
+		if err := w.w.Write([]string{kind, u, sources[u]}); err != nil {
+			return err
+		}
+	}
+	w.w.Flush()
+	return w.w.Error()
+}
+
+func (w *linkWriter) Close() error {
+	w.w.Flush()
+	if err := w.w.Error(); err != nil {
+		w.f.Close()
+		return err
+	}
+	return w.f.Close()
+}
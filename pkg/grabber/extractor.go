@@ -0,0 +1,297 @@
+package grabber
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	cdpbrowser "github.com/chromedp/cdproto/browser"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/cdproto/runtime"
+	"github.com/chromedp/chromedp"
+)
+
+// downloadWait is how long ClickDownload waits for the click to start a
+// download.
+const downloadWait = 10 * time.Second
+
+// ErrExtraction is wrapped by the errors of photo detail pages whose images
+// couldn't be found, as Collector.Resolve returns them.
+var ErrExtraction = errors.New("extraction failed")
+
+// ImageRef is an image found by an Extractor.
+type ImageRef struct {
+	URL string
+	// Page is the page it was found on.
+	Page string
+	// Data is the content of images that can't be downloaded, like
+	// screenshots, URL then only names them.
+	Data []byte
+}
+
+// Tab is a photo detail page opened in chrome, handed to an Extractor.
+type Tab struct {
+	// URL is the page that was opened.
+	URL string
+	// ImageSelector and ClickSelector are the selectors the grabber was
+	// configured with, for extractors that don't know better.
+	ImageSelector string
+	ClickSelector string
+	// LazyAttributes are looked at before srcset, src and href by Images.
+	LazyAttributes []string
+
+	ctx context.Context
+}
+
+// Run runs chromedp actions in the tab.
+func (t *Tab) Run(actions ...chromedp.Action) error {
+	return chromedp.Run(t.ctx, actions...)
+}
+
+// Images returns the images matching selector, taking their LazyAttributes,
+// srcset, src or href in that order, resolved against the current location
+// of the tab. The content of blob: urls is fetched by the page, as nothing
+// else can.
+func (t *Tab) Images(selector string) ([]ImageRef, error) {
+	var elements []map[string]string
+	// A JSON string is a valid JavaScript string literal
+	quoted, _ := json.Marshal(selector)
+	var location string
+	js := `Array.from(document.querySelectorAll(` + string(quoted) + `)).map(e =>
+		Object.fromEntries(Array.from(e.attributes).map(a => [a.name, a.value])))`
+	if err := t.Run(chromedp.Location(&location), chromedp.Evaluate(js, &elements)); err != nil {
+		return nil, err
+	}
+
+	base, err := url.Parse(location)
+	if err != nil {
+		return nil, err
+	}
+	var refs []ImageRef
+	seen := make(map[string]bool)
+	for _, attrs := range elements {
+		src := imageSource(func(name string) string { return attrs[name] }, t.LazyAttributes)
+		if src == "" || seen[src] {
+			continue
+		}
+		ref := ImageRef{URL: src, Page: t.URL}
+		if !isDataURL(src) {
+			u, err := base.Parse(src)
+			if err != nil {
+				continue
+			}
+			ref.URL = u.String()
+			switch {
+			case u.Scheme == "blob":
+				if ref.Data, err = t.fetchBlob(ref.URL); err != nil {
+					continue
+				}
+			case !isHTTP(ref.URL):
+				continue
+			}
+		}
+		if seen[ref.URL] {
+			continue
+		}
+		seen[src], seen[ref.URL] = true, true
+		refs = append(refs, ref)
+	}
+	return refs, nil
+}
+
+// fetchBlob returns the content of a blob: url of the page.
+func (t *Tab) fetchBlob(blobURL string) ([]byte, error) {
+	quoted, _ := json.Marshal(blobURL)
+	js := `fetch(` + string(quoted) + `).then(r => r.blob()).then(b => new Promise((resolve, reject) => {
+		const reader = new FileReader();
+		reader.onload = () => resolve(reader.result);
+		reader.onerror = () => reject(reader.error);
+		reader.readAsDataURL(b);
+	}))`
+
+	var dataURL string
+	err := t.Run(chromedp.Evaluate(js, &dataURL, func(p *runtime.EvaluateParams) *runtime.EvaluateParams {
+		return p.WithAwaitPromise(true)
+	}))
+	if err != nil {
+		return nil, err
+	}
+	return decodeDataURL(dataURL)
+}
+
+// Screenshot takes a PNG screenshot of the biggest element matching
+// selector, for images that can't be downloaded, like the ones drawn on a
+// canvas. It returns nothing if no element matches.
+func (t *Tab) Screenshot(selector string) ([]ImageRef, error) {
+	quoted, _ := json.Marshal(selector)
+	js := `(() => {
+		let best = null, area = 0;
+		for (const e of document.querySelectorAll(` + string(quoted) + `)) {
+			const r = e.getBoundingClientRect();
+			if (r.width * r.height > area) {
+				best = e;
+				area = r.width * r.height;
+			}
+		}
+		if (best) best.setAttribute("data-grab-screenshot", "");
+		return best !== null;
+	})()`
+
+	var found bool
+	if err := t.Run(chromedp.Evaluate(js, &found)); err != nil || !found {
+		return nil, err
+	}
+	var png []byte
+	if err := t.Run(chromedp.Screenshot("[data-grab-screenshot]", &png, chromedp.ByQuery)); err != nil {
+		return nil, err
+	}
+	return []ImageRef{{URL: t.URL + "#screenshot", Page: t.URL, Data: png}}, nil
+}
+
+// ClickDownload clicks selector and returns the file the click makes chrome
+// download, or the image or attachment it navigates to, as told by the CDP
+// events rather than the page. The download itself is cancelled, the
+// Downloader fetches the file. It returns nothing if the click doesn't
+// lead to a file within downloadWait.
+func (t *Tab) ClickDownload(selector string) ([]ImageRef, error) {
+	dir, err := os.MkdirTemp("", "grab-download")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	type download struct{ url, guid string }
+	found := make(chan download, 1)
+	send := func(d download) {
+		select {
+		case found <- d:
+		default:
+		}
+	}
+
+	ctx, cancel := context.WithCancel(t.ctx)
+	defer cancel()
+	chromedp.ListenTarget(ctx, func(ev interface{}) {
+		switch ev := ev.(type) {
+		case *cdpbrowser.EventDownloadWillBegin:
+			send(download{url: ev.URL, guid: ev.GUID})
+		case *network.EventResponseReceived:
+			if ev.Type != network.ResourceTypeImage && isFileResponse(ev.Response) {
+				send(download{url: ev.Response.URL})
+			}
+		}
+	})
+
+	err = t.Run(
+		network.Enable(),
+		cdpbrowser.SetDownloadBehavior(cdpbrowser.SetDownloadBehaviorBehaviorAllowAndName).WithDownloadPath(dir).WithEventsEnabled(true),
+		chromedp.Click(selector, chromedp.NodeVisible),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case d := <-found:
+		if d.guid != "" {
+			t.Run(cdpbrowser.CancelDownload(d.guid))
+		}
+		if !isHTTP(d.url) {
+			return nil, nil
+		}
+		return []ImageRef{{URL: d.url, Page: t.URL}}, nil
+	case <-time.After(downloadWait):
+		return nil, nil
+	case <-t.ctx.Done():
+		return nil, t.ctx.Err()
+	}
+}
+
+// isFileResponse reports whether r is an image or an attachment rather than
+// a page or a script.
+func isFileResponse(r *network.Response) bool {
+	if strings.HasPrefix(strings.ToLower(r.MimeType), "image/") {
+		return true
+	}
+	for k, v := range r.Headers {
+		if s, ok := v.(string); ok && strings.EqualFold(k, "Content-Disposition") &&
+			strings.HasPrefix(strings.ToLower(strings.TrimSpace(s)), "attachment") {
+			return true
+		}
+	}
+	return false
+}
+
+// Extractor holds the site specific logic of getting the full size images
+// out of photo detail pages. Extractors for new sites register themselves
+// with RegisterExtractor from a file of their own.
+type Extractor interface {
+	// Match reports whether the extractor handles the page at url.
+	Match(url string) bool
+	// Extract returns the images of the page opened in tab.
+	Extract(tab *Tab) ([]ImageRef, error)
+}
+
+// GalleryExtractor is an Extractor whose pages may be whole galleries, as
+// imgur albums, rather than the photo pages of one. Crawling such a page
+// hands it to Extract instead of scraping it, as the Extractor knows
+// better where its images are.
+type GalleryExtractor interface {
+	Extractor
+	// Gallery reports whether the page at url is a gallery the extractor
+	// takes all the images of.
+	Gallery(url string) bool
+}
+
+var extractors struct {
+	mu   sync.RWMutex
+	list []Extractor
+}
+
+// RegisterExtractor adds e to the extractors tried on photo detail pages.
+// Extractors are tried in the order they were registered, pages none of
+// them match are handled by DefaultExtractor.
+func RegisterExtractor(e Extractor) {
+	extractors.mu.Lock()
+	defer extractors.mu.Unlock()
+
+	extractors.list = append(extractors.list, e)
+}
+
+// FindExtractor returns the registered extractor matching url, or
+// DefaultExtractor if there is none.
+func FindExtractor(url string) Extractor {
+	extractors.mu.RLock()
+	defer extractors.mu.RUnlock()
+
+	for _, e := range extractors.list {
+		if e.Match(url) {
+			return e
+		}
+	}
+	return DefaultExtractor
+}
+
+// DefaultExtractor clicks the configured click selector, if any, and takes
+// the file the click downloads, or else the images matching the configured
+// image selector.
+var DefaultExtractor Extractor = defaultExtractor{}
+
+type defaultExtractor struct{}
+
+func (defaultExtractor) Match(string) bool { return true }
+
+func (defaultExtractor) Extract(tab *Tab) ([]ImageRef, error) {
+	if tab.ClickSelector != "" {
+		refs, err := tab.ClickDownload(tab.ClickSelector)
+		if err != nil || len(refs) > 0 {
+			return refs, err
+		}
+	}
+	return tab.Images(tab.ImageSelector)
+}
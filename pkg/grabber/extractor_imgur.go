@@ -0,0 +1,79 @@
+package grabber
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+
+	"github.com/chromedp/chromedp"
+)
+
+func init() {
+	RegisterExtractor(imgur{})
+}
+
+// imgur shows the images of albums and galleries resized and loads them as
+// the page scrolls, but the page carries the whole post as JSON in
+// window.postDataJSON, with the id and extension of every original. Albums
+// are galleries of their own, crawling one takes its images from there.
+type imgur struct{}
+
+// imgurPost is the part of window.postDataJSON telling the images of a post.
+type imgurPost struct {
+	Media []struct {
+		ID  string `json:"id"`
+		Ext string `json:"ext"`
+		URL string `json:"url"`
+	} `json:"media"`
+}
+
+func (imgur) Match(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	if host != "imgur.com" && host != "www.imgur.com" && host != "m.imgur.com" {
+		return false
+	}
+	return strings.HasPrefix(u.Path, "/a/") || strings.HasPrefix(u.Path, "/gallery/")
+}
+
+func (i imgur) Gallery(rawURL string) bool {
+	return i.Match(rawURL)
+}
+
+func (imgur) Extract(tab *Tab) ([]ImageRef, error) {
+	var data string
+	js := `typeof window.postDataJSON === "string" ? window.postDataJSON : ""`
+	if err := tab.Run(chromedp.Evaluate(js, &data)); err != nil {
+		return nil, err
+	}
+
+	if refs := imgurImages(data, tab.URL); len(refs) > 0 {
+		return refs, nil
+	}
+	// Pages from before the JSON show the images in the page
+	return tab.Images(tab.ImageSelector)
+}
+
+// imgurImages returns the originals of the post in data, the content of
+// window.postDataJSON of page, nothing if it isn't one.
+func imgurImages(data, page string) []ImageRef {
+	var post imgurPost
+	if data == "" || json.Unmarshal([]byte(data), &post) != nil {
+		return nil
+	}
+	var refs []ImageRef
+	for _, m := range post.Media {
+		// The url of the media is the original too, but may be missing
+		u := m.URL
+		if m.ID != "" && m.Ext != "" {
+			u = "https://i.imgur.com/" + m.ID + "." + strings.TrimPrefix(m.Ext, ".")
+		}
+		if isHTTP(u) {
+			refs = append(refs, ImageRef{URL: u, Page: page})
+		}
+	}
+	return refs
+}
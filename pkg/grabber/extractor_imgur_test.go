@@ -0,0 +1,80 @@
+package grabber
+
+import (
+	"context"
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCrawlImgurAlbum(t *testing.T) {
+	dir := t.TempDir()
+	opts := DefaultOptions()
+	opts.Dir = dir
+	opts.ExportLinks = filepath.Join(dir, "links.csv")
+	g, err := New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The album is handed to the imgur extractor instead of being scraped
+	album := "https://imgur.com/a/abc123"
+	if err := g.Crawl(context.Background(), album); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(opts.ExportLinks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{{"type", "url", "page"}, {"photo", album, album}}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("exported %q, want %q", rows, want)
+	}
+}
+
+func TestImgurImages(t *testing.T) {
+	page := "https://imgur.com/gallery/cats-xyz789"
+	data := `{"id":"xyz789","media":[
+		{"id":"AbCdEfG","ext":"jpeg","url":"https://i.imgur.com/AbCdEfG.jpeg"},
+		{"id":"HiJkLmN","ext":"mp4"},
+		{"url":"https://i.imgur.com/OpQrStU.png"},
+		{"id":"noext"}
+	]}`
+	want := []ImageRef{
+		{URL: "https://i.imgur.com/AbCdEfG.jpeg", Page: page},
+		{URL: "https://i.imgur.com/HiJkLmN.mp4", Page: page},
+		{URL: "https://i.imgur.com/OpQrStU.png", Page: page},
+	}
+	if got := imgurImages(data, page); !reflect.DeepEqual(got, want) {
+		t.Errorf("imgurImages = %v, want %v", got, want)
+	}
+	if got := imgurImages("", page); got != nil {
+		t.Errorf("imgurImages of no JSON = %v, want nothing", got)
+	}
+}
+
+func TestImgurMatch(t *testing.T) {
+	for url, want := range map[string]bool{
+		"https://imgur.com/a/abc123":              true,
+		"https://www.imgur.com/gallery/cats-x789": true,
+		"https://m.imgur.com/a/abc123":            true,
+		"https://imgur.com/abc123":                false,
+		"https://i.imgur.com/abc123.jpg":          false,
+		"https://notimgur.com/a/abc123":           false,
+	} {
+		if got := (imgur{}).Match(url); got != want {
+			t.Errorf("Match(%q) = %v, want %v", url, got, want)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package grabber
+
+import (
+	"net/url"
+	"strings"
+)
+
+func init() {
+	RegisterExtractor(sfwalbum{})
+}
+
+// sfwalbum only hands out the full size photo as the download started by the
+// download button of the photo page.
+type sfwalbum struct{}
+
+func (sfwalbum) Match(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	return host == "sfwalbum.com" || strings.HasSuffix(host, ".sfwalbum.com")
+}
+
+func (sfwalbum) Extract(tab *Tab) ([]ImageRef, error) {
+	refs, err := tab.ClickDownload("#downloadPhoto")
+	if err != nil || len(refs) > 0 {
+		return refs, err
+	}
+	// Older pages show the photo in the page instead
+	return tab.Images("img")
+}
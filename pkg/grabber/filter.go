@@ -0,0 +1,149 @@
+package grabber
+
+import (
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"regexp"
+
+	// Register the decoders used to read image dimensions
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+
+	_ "golang.org/x/image/bmp"
+	_ "golang.org/x/image/webp"
+)
+
+// SkipError is returned for a download that was dropped on purpose, e.g.
+// by a filter. It is reported as skipped rather than failed.
+type SkipError struct {
+	Reason string
+}
+
+func (e *SkipError) Error() string {
+	return "skipped: " + e.Reason
+}
+
+// urlFilter keeps the urls matching accept, if set, and not matching
+// reject, if set.
+type urlFilter struct {
+	accept, reject *regexp.Regexp
+}
+
+// urlFilter compiles Options.AcceptRegex and Options.RejectRegex, nil if
+// neither is set.
+func (o Options) urlFilter() (*urlFilter, error) {
+	if o.AcceptRegex == "" && o.RejectRegex == "" {
+		return nil, nil
+	}
+	f := &urlFilter{}
+	var err error
+	if o.AcceptRegex != "" {
+		if f.accept, err = regexp.Compile(o.AcceptRegex); err != nil {
+			return nil, fmt.Errorf("accept regex: %v", err)
+		}
+	}
+	if o.RejectRegex != "" {
+		if f.reject, err = regexp.Compile(o.RejectRegex); err != nil {
+			return nil, fmt.Errorf("reject regex: %v", err)
+		}
+	}
+	return f, nil
+}
+
+// matches reports whether u passes the filter. A nil filter lets everything
+// through.
+func (f *urlFilter) matches(u string) bool {
+	if f == nil {
+		return true
+	}
+	if f.accept != nil && !f.accept.MatchString(u) {
+		return false
+	}
+	return f.reject == nil || !f.reject.MatchString(u)
+}
+
+// SizeFilter drops downloads too small to be real photos, like thumbnails,
+// spacers and tracking pixels, and with MaxBytes the ones too big to be,
+// like videos linked from a gallery. Zero values don't filter.
+type SizeFilter struct {
+	MinBytes  int64
+	MaxBytes  int64
+	MinWidth  int
+	MinHeight int
+}
+
+// maxSizeReason is the reason downloads bigger than MaxBytes are skipped.
+const maxSizeReason = "above the maximum size"
+
+// checkBytes rejects files smaller than MinBytes or bigger than MaxBytes.
+func (f SizeFilter) checkBytes(size int64) error {
+	if f.MinBytes > 0 && size < f.MinBytes {
+		return &SkipError{Reason: "below the minimum size"}
+	}
+	if f.MaxBytes > 0 && size > f.MaxBytes {
+		return &SkipError{Reason: maxSizeReason}
+	}
+	return nil
+}
+
+// limit returns r failing with a SkipError as soon as it reads more than
+// MaxBytes, counting the offset bytes of a resumed download, for servers
+// that don't tell the size up front or lie about it.
+func (f SizeFilter) limit(r io.Reader, offset int64) io.Reader {
+	if f.MaxBytes <= 0 {
+		return r
+	}
+	return &maxBytesReader{r: r, left: f.MaxBytes - offset}
+}
+
+// maxBytesReader is the reader of SizeFilter.limit.
+type maxBytesReader struct {
+	r    io.Reader
+	left int64
+}
+
+func (m *maxBytesReader) Read(p []byte) (int, error) {
+	if m.left < 0 {
+		return 0, &SkipError{Reason: maxSizeReason}
+	}
+	// Read one byte past the limit to tell a file of exactly MaxBytes
+	// from a bigger one
+	if int64(len(p)) > m.left+1 {
+		p = p[:m.left+1]
+	}
+	n, err := m.r.Read(p)
+	m.left -= int64(n)
+	if m.left < 0 {
+		return 0, &SkipError{Reason: maxSizeReason}
+	}
+	return n, err
+}
+
+// checkDimensions reads the width and height from the header of the image
+// at path, without decoding the whole image, and rejects it if either is
+// below the minimum. Formats that can't be read are let through with 0, 0.
+func (f SizeFilter) checkDimensions(path string) (int, int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer file.Close()
+
+	return f.checkHeader(file)
+}
+
+// checkHeader is checkDimensions for an image read from r.
+func (f SizeFilter) checkHeader(r io.Reader) (int, int, error) {
+	cfg, _, err := image.DecodeConfig(r)
+	if err != nil {
+		return 0, 0, nil
+	}
+
+	if cfg.Width < f.MinWidth || cfg.Height < f.MinHeight {
+		return cfg.Width, cfg.Height, &SkipError{Reason: "below the minimum dimensions"}
+	}
+	return cfg.Width, cfg.Height, nil
+}
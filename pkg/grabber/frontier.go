@@ -0,0 +1,317 @@
+package grabber
+
+import (
+	"database/sql"
+	"path/filepath"
+	"sync"
+
+	_ "modernc.org/sqlite"
+)
+
+// frontierSchema creates the tables of a frontier. Rows are read back in
+// the order they were written, by rowid.
+const frontierSchema = `
+CREATE TABLE IF NOT EXISTS crawls (
+	start TEXT PRIMARY KEY,
+	done  INTEGER NOT NULL DEFAULT 0
+);
+CREATE TABLE IF NOT EXISTS pages (
+	start   TEXT NOT NULL,
+	url     TEXT NOT NULL,
+	depth   INTEGER NOT NULL,
+	visited INTEGER NOT NULL DEFAULT 0,
+	PRIMARY KEY (start, url)
+);
+CREATE TABLE IF NOT EXISTS found (
+	start TEXT NOT NULL,
+	url   TEXT NOT NULL,
+	kind  TEXT NOT NULL,
+	page  TEXT NOT NULL DEFAULT '',
+	done  INTEGER NOT NULL DEFAULT 0,
+	PRIMARY KEY (start, url)
+);
+`
+
+// The kinds of urls a frontier records as found.
+const (
+	foundPhoto = "photo"
+	foundImage = "image"
+)
+
+// frontier is a SQLite database of where a crawl is: the pages queued and
+// visited at every depth, the photo links found and whether they were
+// resolved, and the images found and whether they were downloaded. A
+// crawl that was killed picks up from it where it stopped, without
+// visiting a page or resolving a link twice. Crawls of different start
+// urls are kept apart.
+type frontier struct {
+	db *sql.DB
+	// start is the crawl under way.
+	start string
+
+	mu sync.Mutex
+	// downloaded are the images of the crawl under way that were
+	// downloaded by an earlier run.
+	downloaded map[string]bool
+}
+
+// frontierPage is a page queued by a crawl.
+type frontierPage struct {
+	url     string
+	depth   int
+	visited bool
+}
+
+// frontierFound is a photo link or image found by a crawl.
+type frontierFound struct {
+	url, kind, page string
+	done            bool
+}
+
+// crawlFrontier is what a frontier has of a crawl.
+type crawlFrontier struct {
+	// done is set once the crawl ran to its end.
+	done  bool
+	pages []frontierPage
+	found []frontierFound
+}
+
+// openFrontier opens the frontier at path, creating it if it doesn't exist.
+func openFrontier(path string) (*frontier, error) {
+	db, err := sql.Open("sqlite", path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=synchronous(NORMAL)")
+	if err != nil {
+		return nil, err
+	}
+	// SQLite takes one writer at a time anyway
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(frontierSchema); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &frontier{db: db}, nil
+}
+
+// Close closes the database.
+func (f *frontier) Close() error {
+	return f.db.Close()
+}
+
+// active reports whether there is a crawl under way to record. The
+// methods recording it do nothing without one, or on a nil frontier.
+func (f *frontier) active() bool {
+	return f != nil && f.start != ""
+}
+
+// begin makes start the crawl under way. With resume it returns what
+// there is of it from an earlier run, else it forgets it to start over.
+func (f *frontier) begin(start string, resume bool) (*crawlFrontier, error) {
+	f.start = start
+	f.mu.Lock()
+	f.downloaded = make(map[string]bool)
+	f.mu.Unlock()
+
+	c := &crawlFrontier{}
+	if resume {
+		err := f.db.QueryRow(`SELECT done FROM crawls WHERE start = ?`, start).Scan(&c.done)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, err
+		}
+		if err == nil {
+			if err := f.load(c); err != nil {
+				return nil, err
+			}
+			return c, nil
+		}
+	}
+
+	tx, err := f.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	for _, q := range []string{
+		`DELETE FROM pages WHERE start = ?`,
+		`DELETE FROM found WHERE start = ?`,
+		`INSERT OR REPLACE INTO crawls (start, done) VALUES (?, 0)`,
+	} {
+		if _, err := tx.Exec(q, start); err != nil {
+			return nil, err
+		}
+	}
+	return c, tx.Commit()
+}
+
+// load reads the pages and urls found of the crawl under way into c.
+func (f *frontier) load(c *crawlFrontier) error {
+	rows, err := f.db.Query(`SELECT url, depth, visited FROM pages WHERE start = ? ORDER BY rowid`, f.start)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var p frontierPage
+		if err := rows.Scan(&p.url, &p.depth, &p.visited); err != nil {
+			return err
+		}
+		c.pages = append(c.pages, p)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	rows, err = f.db.Query(`SELECT url, kind, page, done FROM found WHERE start = ? ORDER BY rowid`, f.start)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for rows.Next() {
+		var u frontierFound
+		if err := rows.Scan(&u.url, &u.kind, &u.page, &u.done); err != nil {
+			return err
+		}
+		if u.kind == foundImage && u.done {
+			f.downloaded[u.url] = true
+		}
+		c.found = append(c.found, u)
+	}
+	return rows.Err()
+}
+
+// queue records pages to be visited at depth.
+func (f *frontier) queue(depth int, urls ...string) error {
+	if !f.active() || len(urls) == 0 {
+		return nil
+	}
+	tx, err := f.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, u := range urls {
+		if _, err := tx.Exec(`INSERT OR IGNORE INTO pages (start, url, depth) VALUES (?, ?, ?)`, f.start, u, depth); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// visit records that the page at url was visited, whether it could be
+// crawled or not.
+func (f *frontier) visit(url string) error {
+	if !f.active() {
+		return nil
+	}
+	_, err := f.db.Exec(`UPDATE pages SET visited = 1 WHERE start = ? AND url = ?`, f.start, url)
+	return err
+}
+
+// find records the urls of kind found on page.
+func (f *frontier) find(kind, page string, urls ...string) error {
+	if !f.active() || len(urls) == 0 {
+		return nil
+	}
+	tx, err := f.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, u := range urls {
+		if _, err := tx.Exec(`INSERT OR IGNORE INTO found (start, url, kind, page) VALUES (?, ?, ?, ?)`, f.start, u, kind, page); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// done records that the photo link at url was resolved or the image at
+// url was downloaded.
+func (f *frontier) done(url string) error {
+	if !f.active() {
+		return nil
+	}
+	_, err := f.db.Exec(`UPDATE found SET done = 1 WHERE start = ? AND url = ?`, f.start, url)
+	return err
+}
+
+// has reports whether the image at url was downloaded by an earlier run
+// of the crawl under way.
+func (f *frontier) has(url string) bool {
+	if !f.active() {
+		return false
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.downloaded[url]
+}
+
+// finish records that the crawl under way ran to its end, so resuming it
+// has nothing left to do.
+func (f *frontier) finish() error {
+	if !f.active() {
+		return nil
+	}
+	_, err := f.db.Exec(`UPDATE crawls SET done = 1 WHERE start = ?`, f.start)
+	f.start = ""
+	return err
+}
+
+// restorePages returns the pages c has left to visit: queue those of the
+// first level with any, below those of the level under it. There are no
+// others, as a level is only crawled once the one above is done. The
+// pages queued are added to visited, crawled is how many were visited.
+func (c *crawlFrontier) restorePages(visited map[string]bool) (queue, below []string, first, crawled int) {
+	first = -1
+	for _, p := range c.pages {
+		visited[p.url] = true
+		if p.visited {
+			crawled++
+		} else if first < 0 || p.depth < first {
+			first = p.depth
+		}
+	}
+	for _, p := range c.pages {
+		switch {
+		case p.visited:
+		case p.depth == first:
+			queue = append(queue, p.url)
+		case p.depth == first+1:
+			below = append(below, p.url)
+		}
+	}
+	return queue, below, max(first, 0), crawled
+}
+
+// beginFrontier opens the Options.Frontier of the output directory and
+// starts recording the crawl of start in it, returning what an earlier
+// run left of it if Options.Resume is set. It returns nil for dry runs,
+// remote output directories and without a frontier.
+func (g *Grabber) beginFrontier(start string) (*crawlFrontier, error) {
+	if g.Options.Frontier == "" || g.Options.DryRun || g.Downloader.Storage != nil {
+		return nil, nil
+	}
+	if g.frontier == nil {
+		f, err := openFrontier(filepath.Join(g.Options.Dir, g.Options.Frontier))
+		if err != nil {
+			return nil, err
+		}
+		g.frontier = f
+	}
+	return g.frontier.begin(start, g.Options.Resume)
+}
+
+// finishFrontier records that the crawl under way ran to its end.
+func (g *Grabber) finishFrontier() {
+	g.remember(g.frontier.finish())
+}
+
+// remember logs the error of recording the crawl in the frontier, which
+// only costs a resumed run some work, rather than failing the crawl.
+func (g *Grabber) remember(err error) {
+	if err != nil {
+		g.log().Warn("could not record the crawl in the frontier", "err", err)
+	}
+}
@@ -0,0 +1,121 @@
+package grabber
+
+import (
+	"context"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// maxVariants caps how many full size variants of a url are tried before
+// the url itself.
+const maxVariants = 3
+
+var (
+	// dimensionSuffix is the size WordPress and many CMSs append to their
+	// resized images, as in photo-300x200.jpg.
+	dimensionSuffix = regexp.MustCompile(`-\d+x\d+(\.\w+)$`)
+	// sizeSuffix is a size token at the end of the name, as in
+	// photo_small.jpg, photo-1024.jpg or photo_t.jpg.
+	sizeSuffix = regexp.MustCompile(`(?i)[_-](?:small|medium|large|thumb|thumbnail|preview|sm|md|lg|[stmqnz]|\d{2,4}(?:w|px)?)(\.\w+)$`)
+	// sizeDirectory is a path segment holding the resized copies, as in
+	// /thumbs/photo.jpg.
+	sizeDirectory = regexp.MustCompile(`(?i)/(?:thumbs|thumb|thumbnails|small|medium|preview|resized)/`)
+)
+
+// sizeParams are the query parameters image CDNs resize with.
+var sizeParams = []string{"w", "h", "width", "height", "size", "resize", "fit", "crop"}
+
+// fullSizeVariants returns the urls the full size version of the image at
+// u may be found at, most likely first: those of rules, then, if
+// heuristics is set, the ones guessed from the size markers resized copies
+// commonly carry in their name, directory or query.
+func fullSizeVariants(u string, rules []*RewriteRule, heuristics bool) []string {
+	if !isHTTP(u) {
+		return nil
+	}
+	var variants []string
+	add := func(v string) {
+		if v == u || len(variants) == maxVariants {
+			return
+		}
+		for _, seen := range variants {
+			if seen == v {
+				return
+			}
+		}
+		variants = append(variants, v)
+	}
+
+	for _, r := range rules {
+		add(r.Rewrite(u))
+	}
+	if !heuristics {
+		return variants
+	}
+
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return variants
+	}
+	guess := *parsed
+	guess.Path = dimensionSuffix.ReplaceAllString(guess.Path, "$1")
+	guess.Path = sizeSuffix.ReplaceAllString(guess.Path, "$1")
+	guess.Path = sizeDirectory.ReplaceAllString(guess.Path, "/")
+	guess.RawPath = ""
+	if guess.RawQuery != "" {
+		q := guess.Query()
+		for _, p := range sizeParams {
+			q.Del(p)
+		}
+		guess.RawQuery = q.Encode()
+	}
+	add(guess.String())
+
+	// Sites with a thumbs directory often keep the originals next to it
+	if sizeDirectory.MatchString(parsed.Path) {
+		full := *parsed
+		full.Path = sizeDirectory.ReplaceAllString(full.Path, "/full/")
+		full.RawPath = ""
+		add(full.String())
+	}
+	return variants
+}
+
+// downloadFullSize tries the full size variants of url in turn, returning
+// the first one that downloads as the file of url. It returns no file and
+// no error if none does, leaving url itself to be downloaded, and the
+// error of a variant that didn't change since it was saved as url, see
+// validators.
+func (d *Downloader) downloadFullSize(ctx context.Context, url, referer string, since validators) (*File, int, error) {
+	for _, v := range fullSizeVariants(url, d.FullSizeRules, d.FullSize) {
+		f, status, err := d.download(ctx, v, referer, since)
+		if err == nil {
+			orDiscard(d.Logger).Debug("downloaded full size", "url", url, "variant", v)
+			f.URL, f.Variant = url, v
+			return f, status, nil
+		}
+		if isNotModified(err) {
+			return nil, status, err
+		}
+		orDiscard(d.Logger).Debug("no full size", "url", url, "variant", v, "err", err)
+		d.removeEmptyTmp(v)
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, 0, nil
+}
+
+// removeEmptyTmp removes the tmp file of url if nothing was written to it,
+// as for a variant that doesn't exist.
+func (d *Downloader) removeEmptyTmp(url string) {
+	if d.Storage != nil {
+		return
+	}
+	tmpName := filepath.Join(d.Dir, d.fileName(url)) + "." + shortHash([]byte(url)) + ".tmp"
+	if info, err := os.Stat(tmpName); err == nil && info.Size() == 0 {
+		os.Remove(tmpName)
+	}
+}
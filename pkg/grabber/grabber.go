@@ -0,0 +1,947 @@
+// Package grabber crawls gallery pages and downloads the images they link
+// to. It is the library behind the grab command and can be embedded in other
+// programs, which get notified of progress and errors through callbacks.
+package grabber
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// Grabber ties a Collector and a Downloader together into the crawl and
+// download pipeline.
+type Grabber struct {
+	Options    Options
+	Collector  *Collector
+	Downloader *Downloader
+	// Logger gets what the grabber does, set it with SetLogger.
+	Logger *slog.Logger
+
+	// OnQueue is called when a url is queued for download.
+	OnQueue func(url string)
+	// OnProgress is called while a file is downloading.
+	OnProgress func(Progress)
+	// OnRetry is called before a failed attempt is retried after wait.
+	OnRetry func(url string, attempt int, wait time.Duration, err error)
+	// OnDownload is called for every file saved.
+	OnDownload func(*File)
+	// OnEstimate is called instead of downloading every url in a dry run.
+	OnEstimate func(*Estimate)
+	// OnPreflight is called with the expected size of the downloads
+	// before they start, if Options.Preflight is set.
+	OnPreflight func(Preflight)
+	// OnSkip is called for every url that isn't downloaded, with the reason.
+	OnSkip func(url, reason string)
+	// OnError is called for every url that failed for good.
+	OnError func(Failure)
+
+	// OnLinkFound is called for every photo link and image found on page
+	// while crawling. Returning false drops it.
+	OnLinkFound func(url, page string) bool
+	// BeforeDownload is called before every download. Returning false
+	// skips the url, setting the Name of req saves it under that name. It
+	// is called from the download workers at the same time.
+	BeforeDownload func(req *DownloadRequest) bool
+	// AfterDownload is called for every file saved, also from the download
+	// workers, before the manifest records it. Returning an error fails the
+	// url, so a later run tries it again.
+	AfterDownload func(*File) error
+	// OnProxyDead is called when a proxy is taken out of the rotation
+	// after failing Options.ProxyMaxFails times in a row.
+	OnProxyDead func(proxy string, err error)
+
+	failures *summary
+	quota    *quota
+	exec     *execPool
+	canceled atomic.Bool
+	// deadline is when Options.TotalTimeout is up, counted from the first
+	// Crawl or Download.
+	deadline time.Time
+	// ran is set by the first Crawl or Download, runErr is the first error
+	// they returned.
+	ran      bool
+	runErr   error
+	alerted  atomic.Bool
+	manifest *Manifest
+	records  *recordWriter
+	catalog  *Catalog
+	frontier *frontier
+	links    *linkWriter
+	robots   *robots
+	// scope is the hosts the run under way may touch, nil for any.
+	scope    *domainScope
+	filter   *urlFilter
+	loggedIn bool
+	// running is the mark sweepTmp put on the output directory, "" until
+	// it did.
+	running string
+	// cookieDomains are the domains Options.CookiesFromBrowser was read
+	// for.
+	cookieDomains map[string]bool
+	// names are the names BeforeDownload picked, by url.
+	names sync.Map
+	// dirs are the directories Options.Layout puts the urls in.
+	dirs sync.Map
+}
+
+// New creates a Grabber from opts.
+func New(opts Options) (*Grabber, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	jar, err := NewCookieJar(opts.CookiesFile)
+	if err != nil {
+		return nil, fmt.Errorf("cookies: %v", err)
+	}
+	proxyURLs := opts.Proxies
+	if opts.ProxyFile != "" {
+		urls, err := readProxyFile(opts.ProxyFile)
+		if err != nil {
+			return nil, fmt.Errorf("proxies: %v", err)
+		}
+		proxyURLs = append(append([]string(nil), proxyURLs...), urls...)
+	}
+	proxies, err := NewProxyPool(proxyURLs)
+	if err != nil {
+		return nil, err
+	}
+	proxies.MaxFails = opts.ProxyMaxFails
+	proxies.Recheck = opts.ProxyRecheck
+	transport, err := newTransport(opts)
+	if err != nil {
+		return nil, fmt.Errorf("tls: %v", err)
+	}
+	proxies.setTransport(transport)
+	collectorOpts := opts
+	collectorOpts.Proxies = proxyURLs
+
+	g := &Grabber{
+		Options:    opts,
+		Collector:  NewCollector(collectorOpts),
+		Downloader: NewDownloader(opts.Dir),
+		failures:   newSummary(),
+		quota:      newQuota(opts),
+	}
+	limiter := NewRateLimiter(opts.Rate, opts.Delay, opts.RandomDelay)
+	for host, rate := range opts.HostRates {
+		if limiter.Rates == nil {
+			limiter.Rates = make(map[string]float64)
+		}
+		limiter.Rates[strings.ToLower(host)] = rate
+	}
+	g.Collector.Limiter = limiter
+	g.Downloader.Limiter = limiter
+	g.Collector.Jar = jar
+	g.Collector.Proxies = proxies
+	g.Downloader.Client = &http.Client{Jar: jar, Transport: proxies.transport()}
+	g.Downloader.Headers = opts.headers()
+	g.Downloader.ImagesOnly = opts.ImagesOnly
+	g.Downloader.Types, _ = opts.contentTypes()
+	g.Downloader.KeepErrorPages = opts.KeepErrorPages
+	g.Downloader.FullSize = opts.FullSize
+	g.Downloader.FullSizeRules, _ = parseRewriteRules(opts.FullSizeRules)
+	g.Downloader.Videos = opts.wants(MediaVideo)
+	g.Downloader.Stitch = opts.Playlists == PlaylistsStitch
+	g.Downloader.Dedup = opts.Dedup
+	g.Downloader.Index = NewHashIndex()
+	if IsRemote(opts.Dir) {
+		s, err := OpenStorage(opts.Dir)
+		if err != nil {
+			return nil, fmt.Errorf("storage: %v", err)
+		}
+		g.Downloader.Storage = s
+		g.Downloader.Index.exists = func(name string) bool {
+			_, err := s.Stat(name)
+			return err == nil
+		}
+	}
+	g.Downloader.NearDup = opts.NearDup
+	g.Downloader.NearDupDistance = opts.NearDupDistance
+	g.Downloader.Similar = NewPerceptualIndex()
+	g.filter, _ = opts.urlFilter()
+	g.Downloader.Convert, _ = opts.conversions()
+	g.Downloader.ConvertQuality = opts.ConvertQuality
+	g.Downloader.Thumbs = opts.Thumbnails
+	g.Downloader.ServerTimes = opts.ServerTimes
+	g.Downloader.Verify = opts.Verify
+	g.Downloader.Timeout = opts.Timeout
+	g.Downloader.Filter = SizeFilter{MinBytes: opts.MinBytes, MaxBytes: opts.MaxBytesPerFile, MinWidth: opts.MinWidth, MinHeight: opts.MinHeight}
+	g.Downloader.ProbeBytes = opts.ProbeBytes
+	g.Downloader.Fsync = opts.Fsync
+	g.Downloader.TrackingParams = opts.TrackingParams
+	g.Downloader.Destination = g.destination
+	g.Downloader.Subdir = g.subdir
+	g.Downloader.Validators = g.validators
+	g.exec = newExecPool(opts)
+	g.Downloader.OnProgress = func(p Progress) {
+		if g.OnProgress != nil {
+			g.OnProgress(p)
+		}
+	}
+	proxies.OnDead = func(proxy string, err error) {
+		g.log().Warn("proxy is dead", "proxy", proxy, "err", err)
+		if g.OnProxyDead != nil {
+			g.OnProxyDead(proxy, err)
+		}
+	}
+	if opts.Robots {
+		g.robots = newRobots(g.Downloader.Client, opts.headers(), limiter, opts.UserAgent)
+	}
+
+	return g, nil
+}
+
+// runContext returns ctx ending once Options.TotalTimeout is up.
+func (g *Grabber) runContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if g.Options.TotalTimeout <= 0 {
+		return ctx, func() {}
+	}
+	if g.deadline.IsZero() {
+		g.deadline = time.Now().Add(g.Options.TotalTimeout)
+	}
+	return context.WithDeadlineCause(ctx, g.deadline, fmt.Errorf("total timeout of %v is up", g.Options.TotalTimeout))
+}
+
+// ended remembers how a Crawl or Download went for the webhook.
+func (g *Grabber) ended(err error) error {
+	if g.runErr == nil {
+		g.runErr = err
+	}
+	g.ran = true
+	return err
+}
+
+// Close releases the browser started for rendering pages, closes the JSON
+// manifest and finishes the archive the files went into, if any, then tells
+// Options.Webhook how the run went. With Options.Fsync it marks the output
+// directory as no longer written to. The error is that of finishing the
+// archive.
+func (g *Grabber) Close() error {
+	g.Collector.Close()
+	g.endRun()
+	if g.records != nil {
+		g.records.Close()
+		g.records = nil
+	}
+	if g.links != nil {
+		g.links.Close()
+		g.links = nil
+	}
+	if g.catalog != nil {
+		g.catalog.Close()
+		g.catalog = nil
+	}
+	if g.frontier != nil {
+		g.frontier.Close()
+		g.frontier = nil
+	}
+	var err error
+	if c, ok := g.Downloader.Storage.(io.Closer); ok {
+		err = c.Close()
+	}
+	if g.ran {
+		g.ran = false
+		runErr := g.runErr
+		if runErr == nil {
+			runErr = err
+		}
+		g.notifyFinished(runErr)
+	}
+	return err
+}
+
+// ErrCanceled is returned by Crawl and Download after Cancel or once their
+// context is done, wrapping the cause of the latter unless it was plainly
+// canceled.
+var ErrCanceled = errors.New("canceled")
+
+// CanceledReason is the reason urls are skipped after Cancel.
+const CanceledReason = "canceled"
+
+// Cancel stops the crawl or download under way. No new pages are visited
+// and no new downloads started, those under way are finished. It can be
+// called from any goroutine. Canceling the context of Crawl or Download
+// stops them the same way but also interrupts the requests under way.
+func (g *Grabber) Cancel() {
+	g.canceled.Store(true)
+}
+
+// canceledErr returns the ErrCanceled the run stops with after Cancel or
+// once ctx is done, nil while it goes on.
+func (g *Grabber) canceledErr(ctx context.Context) error {
+	if ctx.Err() != nil {
+		cause := context.Cause(ctx)
+		if cause == context.Canceled || errors.Is(cause, ErrCanceled) {
+			return ErrCanceled
+		}
+		return fmt.Errorf("%w: %w", ErrCanceled, cause)
+	}
+	if g.canceled.Load() {
+		return ErrCanceled
+	}
+	return nil
+}
+
+// Failures returns the urls that failed so far.
+func (g *Grabber) Failures() []Failure {
+	g.failures.mu.Lock()
+	defer g.failures.mu.Unlock()
+
+	return append([]Failure(nil), g.failures.failures...)
+}
+
+// prepare creates the output directory, loads the manifest and logs in if
+// Options.Login is set. A dry run only loads the manifest.
+func (g *Grabber) prepare(ctx context.Context) error {
+	// Create folder if it not exist
+	if !g.Options.DryRun && g.Downloader.Storage == nil {
+		if err := os.MkdirAll(g.Options.Dir, 0700); err != nil {
+			return err
+		}
+	}
+
+	if g.Options.Fsync && g.running == "" && !g.Options.DryRun && g.Downloader.Storage == nil {
+		if err := g.sweepTmp(); err != nil {
+			return fmt.Errorf("sweeping partial downloads: %v", err)
+		}
+	}
+
+	if g.Options.Manifest != "" && g.manifest == nil {
+		var m *Manifest
+		var err error
+		if s := g.Downloader.Storage; s != nil {
+			m, err = loadStoredManifest(s, g.Options.Dir, g.Options.Manifest)
+		} else {
+			m, err = LoadManifest(filepath.Join(g.Options.Dir, g.Options.Manifest))
+		}
+		if err != nil {
+			return fmt.Errorf("manifest: %v", err)
+		}
+		g.manifest = m
+		m.index(g.Downloader.Index, g.Downloader.Similar)
+		g.quota.seed(m)
+	}
+
+	if g.Options.JSONManifest != "" && g.records == nil && !g.Options.DryRun {
+		w, err := openRecords(g.Options.JSONManifest)
+		if err != nil {
+			return fmt.Errorf("json manifest: %v", err)
+		}
+		g.records = w
+	}
+
+	if g.Options.Catalog != "" && g.catalog == nil && !g.Options.DryRun {
+		c, err := OpenCatalog(g.Options.Catalog)
+		if err != nil {
+			return fmt.Errorf("catalog: %v", err)
+		}
+		g.catalog = c
+	}
+
+	if g.Options.ExportLinks != "" && g.links == nil {
+		w, err := createLinks(g.Options.ExportLinks)
+		if err != nil {
+			return fmt.Errorf("export links: %v", err)
+		}
+		g.links = w
+	}
+
+	if g.Options.Login.URL != "" && !g.loggedIn {
+		if err := g.Collector.Login(ctx, g.Options.Login); err != nil {
+			return fmt.Errorf("login: %v", err)
+		}
+		g.loggedIn = true
+	}
+
+	return nil
+}
+
+// finish saves the manifest and returns the error summing up the run, the
+// QuotaError if it was stopped.
+func (g *Grabber) finish(ctx context.Context) error {
+	g.exec.wait()
+	if g.manifest != nil && !g.Options.DryRun {
+		if err := g.manifest.Save(); err != nil {
+			return fmt.Errorf("manifest: %v", err)
+		}
+	}
+	if err := g.quota.err(); err != nil && !g.Options.DryRun {
+		return err
+	}
+	if err := g.canceledErr(ctx); err != nil {
+		return err
+	}
+
+	return g.failures.err()
+}
+
+// Crawl visits the start page and, up to Options.Depth levels below it, the
+// same-host pages it links to. If url is a sitemap, or Options.Sitemap is
+// set, the pages and images the sitemaps list are the start instead. With
+// Options.Pagination the next pages of every visited page are visited at
+// the same level, and no more than Options.MaxPages pages are visited.
+// The photo links matching Options.LinkSelector are opened with chromedp
+// for the Extractor of the site to find their full size images, which are
+// downloaded into Options.Dir along with the images matching
+// Options.ImageSelector on every visited page. Galleries a
+// GalleryExtractor takes, as imgur albums, are opened like photo links.
+// With Options.ExportLinks the links and images are written to that file
+// instead. Once ctx is done the requests under way are interrupted and the
+// crawl stops with what it has.
+func (g *Grabber) Crawl(ctx context.Context, url string) error {
+	ctx, cancel := g.runContext(ctx)
+	defer cancel()
+	return g.ended(g.crawl(ctx, url))
+}
+
+func (g *Grabber) crawl(ctx context.Context, url string) error {
+	if err := g.prepare(ctx); err != nil {
+		if cerr := g.canceledErr(ctx); cerr != nil {
+			return cerr
+		}
+		return err
+	}
+	if err := g.quota.err(); err != nil && !g.Options.DryRun {
+		return err
+	}
+	if err := g.canceledErr(ctx); err != nil {
+		return err
+	}
+	if err := g.browserCookies(url); err != nil {
+		return err
+	}
+	g.scope = newDomainScope(g.Options.AllowedDomains, url)
+
+	var links, images []string
+	seen := make(map[string]bool)
+	// sources has the page every link and image was first found on
+	sources := make(map[string]string)
+	visited := map[string]bool{url: true, g.Collector.normalize(url): true}
+	queue := []string{url}
+	// below are the pages of the level under the first one crawled, and
+	// resolved the photo links already resolved, when resuming
+	var below []string
+	resolved := make(map[string]bool)
+	// collected are the pages this crawl got the images of, the only ones
+	// images can be told gone from
+	collected := make(map[string]bool)
+	first, crawled := 0, 0
+	// capped is set once the crawl left pages or photos out on purpose
+	capped := false
+	failed := len(g.Failures())
+
+	if !g.allowed(ctx, url) {
+		if err := g.canceledErr(ctx); err != nil {
+			return err
+		}
+		return errors.New(RobotsReason)
+	}
+
+	fr, err := g.beginFrontier(url)
+	if err != nil {
+		return fmt.Errorf("frontier: %v", err)
+	}
+	if fr != nil && fr.done {
+		g.log().Info("crawl already finished", "url", url)
+		return g.finish(ctx)
+	}
+	if fr != nil && len(fr.pages) > 0 {
+		queue, below, first, crawled = fr.restorePages(visited)
+		for _, f := range fr.found {
+			seen[f.url] = true
+			sources[f.url] = f.page
+			if f.kind == foundPhoto {
+				links = append(links, f.url)
+				resolved[f.url] = f.done
+			} else {
+				images = append(images, f.url)
+			}
+		}
+		g.log().Info("resuming crawl", "url", url, "depth", first, "pages", len(queue)+len(below), "photos", len(links), "images", len(images))
+	} else if g.Options.Sitemap || isSitemapURL(url) {
+		sm, err := g.readSitemaps(ctx, url)
+		if cerr := g.canceledErr(ctx); cerr != nil {
+			return cerr
+		}
+		if err != nil {
+			return fmt.Errorf("sitemap: %v", err)
+		}
+		g.log().Info("read sitemap", "url", url, "pages", len(sm.Pages), "images", len(sm.Images))
+
+		queue = nil
+		for _, p := range sm.Pages {
+			if !visited[p] && g.follows(p) {
+				visited[p] = true
+				queue = append(queue, p)
+			}
+		}
+		for _, ref := range sm.Images {
+			if !seen[ref.URL] {
+				sources[ref.URL] = ref.Page
+			}
+			n := len(images)
+			images = g.appendFound(images, seen, ref.Page, ref.URL)
+			g.remember(g.frontier.find(foundImage, ref.Page, images[n:]...))
+		}
+		g.remember(g.frontier.queue(0, queue...))
+	} else {
+		g.remember(g.frontier.queue(0, url))
+	}
+
+crawl:
+	for depth := first; depth <= g.Options.Depth && len(queue) > 0; depth++ {
+		next := below
+		below = nil
+		// queue grows while it is walked, by the next pages of galleries
+		for i := 0; i < len(queue); i++ {
+			u := queue[i]
+			if g.canceledErr(ctx) != nil {
+				break crawl
+			}
+			if u != url && !g.allowed(ctx, u) {
+				g.skip(u, RobotsReason)
+				capped = true
+				continue
+			}
+			if g.Options.MaxPages > 0 && crawled == g.Options.MaxPages {
+				g.log().Debug("max pages reached", "max_pages", g.Options.MaxPages)
+				capped = true
+				break crawl
+			}
+			crawled++
+
+			var page *Page
+			attempts, err := g.retry(ctx, u, func() (err error) {
+				page, err = g.Collector.Collect(ctx, u)
+				return err
+			})
+			if err != nil && ctx.Err() != nil {
+				break crawl
+			}
+			g.remember(g.frontier.visit(u))
+			if err != nil {
+				// Without the start page there is nothing to grab
+				if u == url {
+					return err
+				}
+				g.failures.attempt()
+				g.fail(Failure{URL: u, Err: err, Attempts: attempts})
+				continue
+			}
+			g.failures.page()
+			collected[u], collected[page.URL] = true, true
+			g.log().Info("crawled page", "url", u, "depth", depth, "photos", len(page.Links), "images", len(page.Images), "pages", len(page.Pages), "next", len(page.Next))
+
+			for _, found := range [][]string{page.Links, page.Images} {
+				for _, f := range found {
+					if !seen[f] {
+						sources[f] = page.URL
+					}
+				}
+			}
+			n, m := len(links), len(images)
+			links = g.appendFound(links, seen, page.URL, page.Links...)
+			images = g.appendFound(images, seen, page.URL, page.Images...)
+			g.remember(g.frontier.find(foundPhoto, page.URL, links[n:]...))
+			g.remember(g.frontier.find(foundImage, page.URL, images[m:]...))
+			n, m = len(queue), len(next)
+			for _, p := range page.Next {
+				if !visited[p] && g.follows(p) {
+					visited[p] = true
+					queue = append(queue, p)
+				}
+			}
+			for _, p := range page.Pages {
+				if !visited[p] && g.follows(p) {
+					visited[p] = true
+					next = append(next, p)
+				}
+			}
+			g.remember(g.frontier.queue(depth, queue[n:]...))
+			g.remember(g.frontier.queue(depth+1, next[m:]...))
+		}
+		queue = next
+	}
+	if g.links != nil {
+		g.failures.find(len(images))
+		if err := g.links.write("photo", links, sources); err != nil {
+			return fmt.Errorf("export links: %v", err)
+		}
+		if err := g.links.write("image", images, sources); err != nil {
+			return fmt.Errorf("export links: %v", err)
+		}
+		if err := g.canceledErr(ctx); err != nil {
+			return err
+		}
+		g.finishFrontier()
+		return nil
+	}
+
+	if g.Options.Limit > 0 && len(links) > g.Options.Limit {
+		links = links[:g.Options.Limit]
+		capped = true
+	}
+	var unresolved []string
+	for _, l := range links {
+		if !resolved[l] {
+			unresolved = append(unresolved, l)
+		}
+	}
+	for _, r := range g.resolveAll(ctx, unresolved) {
+		if r.canceled {
+			break
+		}
+		if r.disallowed {
+			g.skip(r.link, RobotsReason)
+			capped = true
+			g.remember(g.frontier.done(r.link))
+			continue
+		}
+		g.failures.attempt()
+		if r.err != nil {
+			g.fail(Failure{URL: r.link, Err: r.err, Attempts: r.attempts})
+			continue
+		}
+		collected[r.link] = true
+		for _, ref := range r.refs {
+			collected[ref.Page] = true
+			if !seen[ref.URL] {
+				sources[ref.URL] = ref.Page
+			}
+			if ref.Data != nil {
+				g.Downloader.Hold(ref.URL, ref.Data)
+			}
+			n := len(images)
+			images = g.appendFound(images, seen, ref.Page, ref.URL)
+			g.remember(g.frontier.find(foundImage, ref.Page, images[n:]...))
+		}
+		g.remember(g.frontier.done(r.link))
+	}
+
+	g.failures.find(len(images))
+	// Images on pages that failed aren't gone, only unseen
+	complete := g.canceledErr(ctx) == nil && len(g.Failures()) == failed
+	if g.Options.Diff && g.manifest != nil {
+		if complete {
+			g.diffGone(collected, seen)
+		}
+		images = g.diffNew(images)
+	}
+	// Nor are those of pages left out, deleting them would be for good
+	if g.Options.Mirror && g.Options.MirrorDelete && g.manifest != nil && complete && !capped && !g.Options.DryRun {
+		g.deleteGone(collected, seen)
+	}
+
+	images = g.prioritize(images, sources)
+	g.layOut(images, sources)
+	referers := sources
+	if !g.Options.Referer {
+		referers = nil
+	}
+	if err := g.fetchAll(ctx, images, referers); err != nil {
+		return err
+	}
+
+	err = g.finish(ctx)
+	if g.canceledErr(ctx) == nil && g.quota.err() == nil {
+		g.finishFrontier()
+	}
+	return err
+}
+
+// follows reports whether the page p found while crawling is to be
+// crawled too.
+func (g *Grabber) follows(p string) bool {
+	return g.scope.allows(p) && g.filter.matches(p)
+}
+
+// appendNew appends the urls to list that aren't in seen yet.
+func appendNew(list []string, seen map[string]bool, urls ...string) []string {
+	for _, u := range urls {
+		if !seen[u] {
+			seen[u] = true
+			list = append(list, u)
+		}
+	}
+	return list
+}
+
+// Download fetches every url into Options.Dir. Once ctx is done the
+// downloads under way are interrupted and no new ones started.
+func (g *Grabber) Download(ctx context.Context, urls []string) error {
+	ctx, cancel := g.runContext(ctx)
+	defer cancel()
+	return g.ended(g.download(ctx, urls))
+}
+
+func (g *Grabber) download(ctx context.Context, urls []string) error {
+	if err := g.prepare(ctx); err != nil {
+		if cerr := g.canceledErr(ctx); cerr != nil {
+			return cerr
+		}
+		return err
+	}
+	if err := g.browserCookies(urls...); err != nil {
+		return err
+	}
+
+	g.scope = newDomainScope(g.Options.AllowedDomains, "")
+	g.failures.find(len(urls))
+	g.layOut(urls, nil)
+	if err := g.fetchAll(ctx, g.prioritize(urls, nil), nil); err != nil {
+		return err
+	}
+
+	return g.finish(ctx)
+}
+
+// fetchAll downloads urls, running up to Options.Concurrency downloads at
+// the same time. Urls that don't pass Options.Extensions, that the manifest
+// already has or that robots.txt disallows when Options.Robots is set are
+// skipped. Only the first Options.Limit of the rest are downloaded if it is
+// set. With Options.Preflight nothing is downloaded if the sizes reported by
+// the servers don't fit on the disk. Urls found in referers are downloaded
+// with the page they were found on as the Referer. Playlists that aren't
+// stitched are only written to the JSON manifest. Urls left once ctx is
+// done are skipped.
+func (g *Grabber) fetchAll(ctx context.Context, urls []string, referers map[string]string) error {
+	var queue []string
+	for i, u := range urls {
+		if !g.Options.accepts(u) || !g.filter.matches(u) {
+			g.skip(u, "filtered")
+			continue
+		}
+		if !g.scope.allows(u) {
+			g.skip(u, DomainReason)
+			continue
+		}
+		if g.frontier.has(u) || g.manifest != nil && g.manifest.Has(u) && !g.Options.Mirror {
+			g.skip(u, "already downloaded")
+			continue
+		}
+		if !g.allowed(ctx, u) {
+			g.skip(u, RobotsReason)
+			continue
+		}
+		if g.Options.Limit > 0 && len(queue) == g.Options.Limit {
+			g.log().Debug("limit reached", "limit", g.Options.Limit, "left", len(urls)-i)
+			break
+		}
+		queue = append(queue, u)
+	}
+
+	if g.Options.Preflight && !g.Options.DryRun {
+		if err := g.preflight(ctx, queue, referers); err != nil {
+			return err
+		}
+	}
+
+	jobs := make(chan string)
+	sched := newHostScheduler(queue, g.Options.HostConcurrency)
+
+	var wg sync.WaitGroup
+	for i := 0; i < g.Options.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for u := range jobs {
+				g.fetchOne(ctx, u, referers[u])
+				sched.done(u)
+			}
+		}()
+	}
+
+	for {
+		u, ok := sched.take()
+		if !ok {
+			break
+		}
+		g.failures.attempt()
+		if g.OnQueue != nil {
+			g.OnQueue(u)
+		}
+		jobs <- u
+	}
+	close(jobs)
+	wg.Wait()
+
+	return nil
+}
+
+// fetchOne downloads u, found on the page referer, for a worker of
+// fetchAll and records what came of it.
+func (g *Grabber) fetchOne(ctx context.Context, u, referer string) {
+	if kind := playlistKind(u); kind != "" && !(kind == playlistHLS && g.Downloader.Stitch) {
+		g.exportPlaylist(u, referer)
+		return
+	}
+	if g.canceledErr(ctx) != nil {
+		g.skip(u, CanceledReason)
+		return
+	}
+	// A mirror downloads what it has only if it changed, which a dry run
+	// asks the server about
+	var old ManifestEntry
+	if g.Options.Mirror && g.manifest != nil {
+		old, _ = g.manifest.entry(u)
+	}
+	if old.URL != "" && g.Options.DryRun {
+		changed, err := g.changed(ctx, old, referer)
+		if err != nil && ctx.Err() != nil {
+			g.skip(u, CanceledReason)
+			return
+		}
+		if err != nil {
+			g.fail(Failure{URL: u, Err: err})
+			return
+		}
+		if !changed {
+			g.skip(u, UnchangedReason)
+			return
+		}
+	}
+	if g.Options.DryRun {
+		g.estimate(ctx, u, referer)
+		return
+	}
+	if g.quota.err() != nil {
+		g.skip(u, QuotaReason)
+		return
+	}
+
+	ok, err := g.beforeDownload(u, referer)
+	if err != nil {
+		g.fail(Failure{URL: u, Err: err})
+		return
+	}
+	if !ok {
+		g.skip(u, HookReason)
+		return
+	}
+
+	var file *File
+	attempts, err := g.retry(ctx, u, func() (err error) {
+		file, err = g.Downloader.DownloadFrom(ctx, u, referer)
+		return err
+	})
+	if err != nil && ctx.Err() != nil {
+		g.skip(u, CanceledReason)
+		return
+	}
+	var skipErr *SkipError
+	if errors.As(err, &skipErr) {
+		g.skip(u, skipErr.Reason)
+		return
+	}
+	var quotaErr *QuotaError
+	if errors.Is(err, syscall.ENOSPC) {
+		quotaErr = &QuotaError{Reason: "disk full"}
+	}
+	if quotaErr != nil || errors.As(err, &quotaErr) {
+		g.quota.stop(quotaErr)
+		g.skip(u, QuotaReason)
+		return
+	}
+	if err != nil {
+		g.fail(Failure{URL: u, Err: err, Attempts: attempts})
+		return
+	}
+	if old.URL != "" {
+		g.replace(file, old)
+	}
+	if g.AfterDownload != nil {
+		if err := g.AfterDownload(file); err != nil {
+			g.fail(Failure{URL: u, Err: fmt.Errorf("after download: %v", err), Attempts: attempts})
+			return
+		}
+	}
+	if g.manifest != nil {
+		if err := g.manifest.Add(file); err != nil {
+			g.fail(Failure{URL: u, Err: fmt.Errorf("manifest: %v", err), Attempts: attempts})
+			return
+		}
+	}
+	if g.records != nil {
+		if err := g.records.write(newRecord(file)); err != nil {
+			g.fail(Failure{URL: u, Err: fmt.Errorf("json manifest: %v", err), Attempts: attempts})
+			return
+		}
+	}
+	if g.catalog != nil {
+		if err := g.catalog.add(file, g.Downloader.fileExif(file)); err != nil {
+			g.fail(Failure{URL: u, Err: fmt.Errorf("catalog: %v", err), Attempts: attempts})
+			return
+		}
+	}
+	g.remember(g.frontier.done(u))
+	switch {
+	case file.DuplicateOf != "" && g.Options.Dedup == DedupSkip:
+		g.skip(u, "duplicate")
+	case file.SimilarTo != "" && g.Options.NearDup == NearDupSkip:
+		g.skip(u, "near duplicate")
+	default:
+		g.quota.add(file.Size)
+		g.failures.download(file.Size)
+		g.log().Info("downloaded", "url", u, "path", file.Path, "bytes", file.Size)
+		if g.OnDownload != nil {
+			g.OnDownload(file)
+		}
+		if g.exec != nil {
+			g.runExec(ctx, file)
+		}
+	}
+}
+
+// estimate reports what downloading url would do.
+func (g *Grabber) estimate(ctx context.Context, url, referer string) {
+	var e *Estimate
+	attempts, err := g.retry(ctx, url, func() (err error) {
+		e, err = g.Downloader.Estimate(ctx, url, referer)
+		return err
+	})
+	if err != nil && ctx.Err() != nil {
+		g.skip(url, CanceledReason)
+		return
+	}
+	if err != nil {
+		g.fail(Failure{URL: url, Err: err, Attempts: attempts})
+		return
+	}
+	if g.OnEstimate != nil {
+		g.OnEstimate(e)
+	}
+}
+
+// allowed reports whether url may be fetched, which is always the case
+// unless Options.Robots is set.
+func (g *Grabber) allowed(ctx context.Context, url string) bool {
+	return g.robots == nil || g.robots.allowed(ctx, url)
+}
+
+func (g *Grabber) skip(url, reason string) {
+	g.log().Info("skipped", "url", url, "reason", reason)
+	g.failures.skip(reason)
+	if g.OnSkip != nil {
+		g.OnSkip(url, reason)
+	}
+}
+
+func (g *Grabber) fail(f Failure) {
+	g.log().Error("failed", "url", f.URL, "err", f.Err, "attempts", f.Attempts, "permanent", f.Permanent())
+	g.failures.fail(f)
+	if g.OnError != nil {
+		g.OnError(f)
+	}
+	g.checkFailureRate()
+}
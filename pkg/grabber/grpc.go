@@ -0,0 +1,254 @@
+package grabber
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/d3z41k/image-grabber/pkg/grabpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// GRPC returns a gRPC server offering the jobs of s as the grabpb.Grabber
+// service. Calls need the Token of s as bearer token in the authorization
+// metadata.
+func (s *Server) GRPC(opts ...grpc.ServerOption) *grpc.Server {
+	opts = append(opts,
+		grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, next grpc.UnaryHandler) (interface{}, error) {
+			if err := s.authorize(ctx); err != nil {
+				return nil, err
+			}
+			return next(ctx, req)
+		}),
+		grpc.StreamInterceptor(func(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo, next grpc.StreamHandler) error {
+			if err := s.authorize(ss.Context()); err != nil {
+				return err
+			}
+			return next(srv, ss)
+		}))
+	g := grpc.NewServer(opts...)
+	grabpb.RegisterGrabberServer(g, grpcService{s: s})
+	return g
+}
+
+// authorize checks the token of a gRPC call.
+func (s *Server) authorize(ctx context.Context) error {
+	if s.Token == "" {
+		return nil
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, v := range md.Get("authorization") {
+		token, _ := strings.CutPrefix(v, "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(s.Token)) == 1 {
+			return nil
+		}
+	}
+	return status.Error(codes.Unauthenticated, "missing or wrong token")
+}
+
+// grpcService is the grabpb.Grabber service of a Server.
+type grpcService struct {
+	grabpb.UnimplementedGrabberServer
+	s *Server
+}
+
+func (g grpcService) SubmitJob(_ context.Context, req *grabpb.SubmitJobRequest) (*grabpb.Job, error) {
+	jr, err := jobRequest(req.GetSpec())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	info, err := g.s.Submit(jr)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return jobProto(info), nil
+}
+
+func (g grpcService) GetJob(_ context.Context, req *grabpb.GetJobRequest) (*grabpb.Job, error) {
+	info, err := g.s.Job(req.GetId())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return jobProto(info), nil
+}
+
+func (g grpcService) ListJobs(context.Context, *grabpb.ListJobsRequest) (*grabpb.ListJobsResponse, error) {
+	resp := &grabpb.ListJobsResponse{}
+	for _, info := range g.s.Jobs() {
+		resp.Jobs = append(resp.Jobs, jobProto(info))
+	}
+	return resp, nil
+}
+
+func (g grpcService) CancelJob(_ context.Context, req *grabpb.CancelJobRequest) (*grabpb.Job, error) {
+	info, err := g.s.CancelJob(req.GetId())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return jobProto(info), nil
+}
+
+func (g grpcService) StreamProgress(req *grabpb.StreamProgressRequest, stream grpc.ServerStreamingServer[grabpb.ProgressEvent]) error {
+	events, stop, err := g.s.Watch(req.GetId())
+	if err != nil {
+		return grpcError(err)
+	}
+	defer stop()
+
+	ctx := stream.Context()
+	ended := false
+	for {
+		var e JobEvent
+		var ok bool
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case e, ok = <-events:
+		}
+		if !ok {
+			if ended {
+				return nil
+			}
+			// The end was dropped on the way, the last word is the job as
+			// it ended
+			info, err := g.s.Job(req.GetId())
+			if err != nil {
+				return grpcError(err)
+			}
+			return stream.Send(&grabpb.ProgressEvent{Event: &grabpb.ProgressEvent_Job{Job: jobProto(info)}})
+		}
+
+		var pe grabpb.ProgressEvent
+		switch {
+		case e.Progress != nil:
+			pe.Event = &grabpb.ProgressEvent_Progress{Progress: progressProto(*e.Progress)}
+		case e.File != nil:
+			pe.Event = &grabpb.ProgressEvent_File{File: fileProto(*e.File)}
+		case e.Job != nil:
+			pe.Event = &grabpb.ProgressEvent_Job{Job: jobProto(*e.Job)}
+			ended = e.Job.State != JobQueued && e.Job.State != JobRunning
+		}
+		if err := stream.Send(&pe); err != nil {
+			return err
+		}
+	}
+}
+
+// grpcError turns an error of the Server into a gRPC status.
+func grpcError(err error) error {
+	switch {
+	case errors.Is(err, ErrNoSuchJob):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, ErrJobEnded):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+// jobRequest turns spec into the JobRequest of the REST API, its fields
+// winning over the same options in spec.Options.
+func jobRequest(spec *grabpb.JobSpec) (JobRequest, error) {
+	opts := spec.GetOptions().AsMap()
+	set := func(name string, v interface{}, isSet bool) {
+		if isSet {
+			opts[name] = v
+		}
+	}
+	set("dir", spec.GetDir(), spec.GetDir() != "")
+	set("manifest", spec.GetManifest(), spec.GetManifest() != "")
+	set("concurrency", spec.GetConcurrency(), spec.GetConcurrency() != 0)
+	set("depth", spec.GetDepth(), spec.GetDepth() != 0)
+	set("link_selector", spec.GetLinkSelector(), spec.GetLinkSelector() != "")
+	set("image_selector", spec.GetImageSelector(), spec.GetImageSelector() != "")
+	set("extensions", spec.GetExtensions(), len(spec.GetExtensions()) > 0)
+	set("thumbnails", spec.GetThumbnails(), spec.GetThumbnails() != 0)
+	set("headers", spec.GetHeaders(), len(spec.GetHeaders()) > 0)
+
+	req := JobRequest{URLs: spec.GetUrls()}
+	switch spec.GetCommand() {
+	case grabpb.Command_COMMAND_DOWNLOAD:
+		req.Command = "download"
+	default:
+		req.Command = "crawl"
+	}
+	if len(opts) > 0 {
+		data, err := json.Marshal(opts)
+		if err != nil {
+			return JobRequest{}, err
+		}
+		req.Options = data
+	}
+	return req, nil
+}
+
+var jobStates = map[string]grabpb.JobState{
+	JobQueued:   grabpb.JobState_JOB_STATE_QUEUED,
+	JobRunning:  grabpb.JobState_JOB_STATE_RUNNING,
+	JobDone:     grabpb.JobState_JOB_STATE_DONE,
+	JobFailed:   grabpb.JobState_JOB_STATE_FAILED,
+	JobCanceled: grabpb.JobState_JOB_STATE_CANCELED,
+}
+
+func jobProto(info JobInfo) *grabpb.Job {
+	j := &grabpb.Job{
+		Id:      info.ID,
+		Command: grabpb.Command_COMMAND_CRAWL,
+		Urls:    info.URLs,
+		Dir:     info.Dir,
+		State:   jobStates[info.State],
+		Error:   info.Error,
+		Created: timeProto(info.Created),
+		Started: timeProto(info.Started),
+		Ended:   timeProto(info.Ended),
+		Report: &grabpb.Report{
+			Pages:      int32(info.Report.Pages),
+			Found:      int32(info.Report.Found),
+			Downloaded: int32(info.Report.Downloaded),
+			Bytes:      info.Report.Bytes,
+		},
+	}
+	if info.Command == "download" {
+		j.Command = grabpb.Command_COMMAND_DOWNLOAD
+	}
+	if len(info.Report.Skipped) > 0 {
+		j.Report.Skipped = make(map[string]int32, len(info.Report.Skipped))
+		for reason, n := range info.Report.Skipped {
+			j.Report.Skipped[reason] = int32(n)
+		}
+	}
+	for _, f := range info.Report.Failed {
+		j.Report.Failed = append(j.Report.Failed, &grabpb.FailedURL{
+			Url: f.URL, Error: f.Error, Attempts: int32(f.Attempts), Permanent: f.Permanent, Corrupt: f.Corrupt,
+		})
+	}
+	for _, p := range info.Active {
+		j.Active = append(j.Active, progressProto(p))
+	}
+	for _, f := range info.Files {
+		j.Files = append(j.Files, fileProto(f))
+	}
+	return j
+}
+
+func progressProto(p FileProgress) *grabpb.FileProgress {
+	return &grabpb.FileProgress{Url: p.URL, File: p.File, Written: p.Written, Total: p.Total, Done: p.Done}
+}
+
+func fileProto(f JobFile) *grabpb.File {
+	return &grabpb.File{Url: f.URL, Path: f.Path, Thumb: f.Thumb, Size: f.Size, Width: int32(f.Width), Height: int32(f.Height)}
+}
+
+// timeProto leaves zero times unset.
+func timeProto(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
@@ -0,0 +1,79 @@
+package grabber
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// HookReason is the reason urls dropped by Grabber.OnLinkFound or
+// Grabber.BeforeDownload are skipped.
+const HookReason = "dropped by hook"
+
+// DownloadRequest is a url about to be downloaded, as Grabber.BeforeDownload
+// sees it.
+type DownloadRequest struct {
+	URL string
+	// Referer is the page the url was found on, empty if unknown or
+	// Options.Referer is off.
+	Referer string
+	// Name is the file the url is saved as, relative to Options.Dir. Empty
+	// names it as always. The extension is still fixed to match the content
+	// and a number added if another file has the name.
+	Name string
+}
+
+// appendFound appends the urls found on page to list that aren't in seen
+// yet, that are in the allowed domains, pass the url filter and that
+// OnLinkFound keeps.
+func (g *Grabber) appendFound(list []string, seen map[string]bool, page string, urls ...string) []string {
+	if g.OnLinkFound == nil && g.scope == nil && g.filter == nil {
+		return appendNew(list, seen, urls...)
+	}
+	for _, u := range urls {
+		if seen[u] {
+			continue
+		}
+		seen[u] = true
+		if !g.scope.allows(u) {
+			g.skip(u, DomainReason)
+			continue
+		}
+		if !g.filter.matches(u) {
+			g.skip(u, "filtered")
+			continue
+		}
+		if g.OnLinkFound != nil && !g.OnLinkFound(u, page) {
+			g.skip(u, HookReason)
+			continue
+		}
+		list = append(list, u)
+	}
+	return list
+}
+
+// beforeDownload runs BeforeDownload for u, returning false if it is to
+// be skipped.
+func (g *Grabber) beforeDownload(u, referer string) (bool, error) {
+	if g.BeforeDownload == nil {
+		return true, nil
+	}
+	req := &DownloadRequest{URL: u, Referer: referer}
+	if !g.BeforeDownload(req) {
+		return false, nil
+	}
+	if req.Name != "" {
+		if !filepath.IsLocal(req.Name) {
+			return false, fmt.Errorf("hook named it %q, which is outside of the output directory", req.Name)
+		}
+		g.names.Store(u, filepath.Clean(req.Name))
+	}
+	return true, nil
+}
+
+// destination returns the name BeforeDownload picked for url, "" if none.
+func (g *Grabber) destination(url string) string {
+	if name, ok := g.names.Load(url); ok {
+		return name.(string)
+	}
+	return ""
+}
@@ -0,0 +1,85 @@
+package grabber
+
+import (
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// hostScheduler hands out the downloads of a run one host after the other,
+// round robin, so a host with many images doesn't hold back the others
+// until it is done. With a limit no host gets more than that many
+// downloads at once, the workers go on with the other hosts meanwhile
+// instead of all waiting on a slow one.
+type hostScheduler struct {
+	limit int
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	hosts   []string
+	queues  map[string][]string
+	running map[string]int
+	next    int
+	left    int
+}
+
+// newHostScheduler queues urls by host, in the order they came in.
+func newHostScheduler(urls []string, limit int) *hostScheduler {
+	s := &hostScheduler{
+		limit:   limit,
+		queues:  make(map[string][]string),
+		running: make(map[string]int),
+		left:    len(urls),
+	}
+	s.cond = sync.NewCond(&s.mu)
+	for _, u := range urls {
+		host := urlHost(u)
+		if _, ok := s.queues[host]; !ok {
+			s.hosts = append(s.hosts, host)
+		}
+		s.queues[host] = append(s.queues[host], u)
+	}
+	return s
+}
+
+// urlHost returns the lowercased host of u, empty if it has none.
+func urlHost(u string) string {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(parsed.Hostname())
+}
+
+// take returns the next url of the next host below the limit, waiting
+// for a download to be done if all of them are at it. It returns false
+// once every url was handed out.
+func (s *hostScheduler) take() (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for s.left > 0 {
+		for i := range s.hosts {
+			host := s.hosts[(s.next+i)%len(s.hosts)]
+			queue := s.queues[host]
+			if len(queue) == 0 || s.limit > 0 && s.running[host] >= s.limit {
+				continue
+			}
+			s.queues[host] = queue[1:]
+			s.running[host]++
+			s.left--
+			s.next = (s.next + i + 1) % len(s.hosts)
+			return queue[0], true
+		}
+		s.cond.Wait()
+	}
+	return "", false
+}
+
+// done records that the download of u handed out by take is over.
+func (s *hostScheduler) done(u string) {
+	s.mu.Lock()
+	s.running[urlHost(u)]--
+	s.mu.Unlock()
+	s.cond.Broadcast()
+}
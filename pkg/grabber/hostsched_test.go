@@ -0,0 +1,55 @@
+package grabber
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHostConcurrency(t *testing.T) {
+	for _, limit := range []int{1, 2} {
+		t.Run(fmt.Sprint(limit), func(t *testing.T) {
+			var mu sync.Mutex
+			running, most := 0, 0
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				running++
+				most = max(most, running)
+				mu.Unlock()
+				time.Sleep(20 * time.Millisecond)
+				mu.Lock()
+				running--
+				mu.Unlock()
+				w.Header().Set("Content-Type", "application/octet-stream")
+				fmt.Fprintf(w, "content of %s", r.URL.Path)
+			}))
+			defer srv.Close()
+
+			opts := DefaultOptions()
+			opts.Dir = t.TempDir()
+			opts.Media = nil
+			opts.Concurrency = 8
+			opts.HostConcurrency = limit
+			g, err := New(opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer g.Close()
+			var urls []string
+			for i := 0; i < 8; i++ {
+				urls = append(urls, fmt.Sprintf("%s/%d.bin", srv.URL, i))
+			}
+			if err := g.Download(context.Background(), urls); err != nil {
+				t.Fatal(err)
+			}
+
+			if most > limit {
+				t.Errorf("%d requests to the host at once, want at most %d", most, limit)
+			}
+		})
+	}
+}
@@ -0,0 +1,19 @@
+//go:build darwin || dragonfly || freebsd || netbsd || openbsd
+
+package grabber
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+// processInstance tells process pid apart from the processes that had its
+// pid before the last boot, by the time of the boot.
+func processInstance(pid int) string {
+	tv, err := unix.SysctlTimeval("kern.boottime")
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%d.%06d", tv.Sec, tv.Usec)
+}
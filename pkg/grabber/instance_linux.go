@@ -0,0 +1,18 @@
+//go:build linux
+
+package grabber
+
+import (
+	"os"
+	"strings"
+)
+
+// processInstance tells process pid apart from the processes that had its
+// pid before the last boot, by the id of the boot.
+func processInstance(pid int) string {
+	b, err := os.ReadFile("/proc/sys/kernel/random/boot_id")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
@@ -0,0 +1,9 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd && !windows
+
+package grabber
+
+// processInstance can't tell processes with the same pid apart on this
+// platform.
+func processInstance(pid int) string {
+	return ""
+}
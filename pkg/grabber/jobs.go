@@ -0,0 +1,351 @@
+package grabber
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/robfig/cron/v3"
+	"gopkg.in/yaml.v3"
+)
+
+// Job is a grab run on a schedule, as listed in a jobs file:
+//
+//	concurrency: 4        # options for every job
+//	jobs:
+//	  - name: cats
+//	    schedule: "0 */6 * * *"
+//	    urls:
+//	      - https://example.com/cats
+//	    output: cats
+//	    link_selector: a.thumb
+//	  - name: dogs
+//	    schedule: "@daily"
+//	    command: download
+//	    urls:
+//	      - https://example.com/dog.jpg
+//	    output: dogs
+type Job struct {
+	Name string `yaml:"name" toml:"name"`
+	// Schedule is a cron expression like "30 2 * * *", or one of @hourly,
+	// @daily, @weekly and @every 90m.
+	Schedule string `yaml:"schedule" toml:"schedule"`
+	// Command is crawl, the default, or download.
+	Command string `yaml:"command" toml:"command"`
+	// URLs are the pages to crawl, or the images to download.
+	URLs    []string `yaml:"urls" toml:"urls"`
+	Options `yaml:",inline"`
+
+	schedule cron.Schedule
+}
+
+// LoadJobs reads the jobs file at path on top of opts. The options at the
+// top of the file apply to every job, the options of a job only to it. The
+// format is picked by extension like for LoadConfig.
+func LoadJobs(path string, opts Options) ([]Job, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var jobs []Job
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".toml":
+		var file struct {
+			Options
+			Jobs []toml.Primitive `toml:"jobs"`
+		}
+		file.Options = opts
+		md, err := toml.Decode(string(data), &file)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		for _, p := range file.Jobs {
+			job := Job{Options: file.Options.forJob()}
+			if err := md.PrimitiveDecode(p, &job); err != nil {
+				return nil, fmt.Errorf("%s: %v", path, err)
+			}
+			jobs = append(jobs, job)
+		}
+	default:
+		var file struct {
+			Options `yaml:",inline"`
+			Jobs    []yaml.Node `yaml:"jobs"`
+		}
+		file.Options = opts
+		if err := yaml.Unmarshal(data, &file); err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		for _, n := range file.Jobs {
+			job := Job{Options: file.Options.forJob()}
+			if err := n.Decode(&job); err != nil {
+				return nil, fmt.Errorf("%s: %v", path, err)
+			}
+			jobs = append(jobs, job)
+		}
+	}
+	if len(jobs) == 0 {
+		return nil, fmt.Errorf("%s: no jobs", path)
+	}
+
+	dirs := make(map[string]string)
+	names := make(map[string]bool)
+	for i := range jobs {
+		job := &jobs[i]
+		if err := job.prepare(); err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		if names[job.Name] {
+			return nil, fmt.Errorf("%s: there are two jobs named %s", path, job.Name)
+		}
+		names[job.Name] = true
+		// They would write the same manifest at the same time
+		dir := filepath.Clean(job.Dir)
+		if other, ok := dirs[dir]; ok {
+			return nil, fmt.Errorf("%s: jobs %s and %s both write to %s", path, other, job.Name, job.Dir)
+		}
+		dirs[dir] = job.Name
+	}
+	return jobs, nil
+}
+
+// forJob returns a copy of o whose headers a job can add to without
+// changing those of the other jobs.
+func (o Options) forJob() Options {
+	if o.Headers != nil {
+		h := make(map[string]string, len(o.Headers))
+		for k, v := range o.Headers {
+			h[k] = v
+		}
+		o.Headers = h
+	}
+	return o
+}
+
+// prepare checks the job and parses its schedule.
+func (j *Job) prepare() error {
+	if j.Name == "" {
+		return errors.New("a job has no name")
+	}
+	for i, ext := range j.Extensions {
+		j.Extensions[i] = strings.TrimPrefix(strings.ToLower(ext), ".")
+	}
+	switch j.Command {
+	case "":
+		j.Command = "crawl"
+	case "crawl", "download":
+	default:
+		return fmt.Errorf("job %s: unknown command %q", j.Name, j.Command)
+	}
+	if len(j.URLs) == 0 {
+		return fmt.Errorf("job %s: no urls", j.Name)
+	}
+	if j.DryRun {
+		return fmt.Errorf("job %s: jobs can't be dry runs", j.Name)
+	}
+	if err := j.Options.Validate(); err != nil {
+		return fmt.Errorf("job %s: %v", j.Name, err)
+	}
+
+	s, err := cron.ParseStandard(j.Schedule)
+	if err != nil {
+		return fmt.Errorf("job %s: schedule: %v", j.Name, err)
+	}
+	j.schedule = s
+	return nil
+}
+
+// JobStatus is how a Job of a Scheduler fares.
+type JobStatus struct {
+	Name     string `json:"name"`
+	Schedule string `json:"schedule"`
+	Running  bool   `json:"running"`
+	Runs     int    `json:"runs"`
+	// Next is when the job runs next.
+	Next time.Time `json:"next"`
+	// The rest is about the last run, zero before the first.
+	LastStart  time.Time `json:"last_start,omitzero"`
+	LastEnd    time.Time `json:"last_end,omitzero"`
+	LastError  string    `json:"last_error,omitempty"`
+	Downloaded int       `json:"downloaded"`
+	Bytes      int64     `json:"bytes"`
+	Failed     int       `json:"failed"`
+}
+
+// Scheduler runs jobs on their schedules in a single process. A job never
+// runs twice at the same time, a run that takes longer than the schedule
+// skips the runs it overlaps.
+type Scheduler struct {
+	Jobs []Job
+	// StatusFile is written with the status of all jobs whenever one
+	// starts or ends. Empty writes none.
+	StatusFile string
+	// Logger gets what the jobs do, nil logs nothing.
+	Logger *slog.Logger
+
+	mu     sync.Mutex
+	status map[string]*JobStatus
+	// saveMu keeps the jobs from writing the status file at once.
+	saveMu sync.Mutex
+}
+
+// Run runs the jobs until ctx is done, which interrupts the running ones,
+// then waits for them to save what they downloaded.
+func (s *Scheduler) Run(ctx context.Context) error {
+	s.mu.Lock()
+	s.status = make(map[string]*JobStatus)
+	now := time.Now()
+	for _, job := range s.Jobs {
+		s.status[job.Name] = &JobStatus{Name: job.Name, Schedule: job.Schedule, Next: job.schedule.Next(now)}
+	}
+	s.mu.Unlock()
+	if err := s.save(); err != nil {
+		return err
+	}
+
+	var wg sync.WaitGroup
+	for _, job := range s.Jobs {
+		wg.Add(1)
+		go func(job Job) {
+			defer wg.Done()
+			for {
+				next := s.next(job.Name)
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(time.Until(next)):
+				}
+				s.run(ctx, job)
+			}
+		}(job)
+	}
+	wg.Wait()
+	return nil
+}
+
+func (s *Scheduler) next(name string) time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.status[name].Next
+}
+
+// run runs job once and records how it went.
+func (s *Scheduler) run(ctx context.Context, job Job) {
+	log := orDiscard(s.Logger).With("job", job.Name)
+	s.update(job.Name, func(st *JobStatus) {
+		st.Running = true
+		st.LastStart = time.Now()
+	})
+	log.Info("job started")
+
+	rep, err := runJob(ctx, job, log)
+
+	s.update(job.Name, func(st *JobStatus) {
+		st.Running = false
+		st.Runs++
+		st.LastEnd = time.Now()
+		st.LastError = ""
+		if err != nil {
+			st.LastError = err.Error()
+		}
+		st.Downloaded, st.Bytes, st.Failed = rep.Downloaded, rep.Bytes, len(rep.Failed)
+		// Runs missed while this one ran are skipped
+		st.Next = job.schedule.Next(st.LastEnd)
+	})
+	if err != nil {
+		log.Error("job failed", "err", err, "downloaded", rep.Downloaded, "failed", len(rep.Failed))
+	} else {
+		log.Info("job done", "downloaded", rep.Downloaded, "bytes", rep.Bytes)
+	}
+}
+
+// runJob grabs what job says and returns the report of the run.
+func runJob(ctx context.Context, job Job, log *slog.Logger) (Report, error) {
+	g, err := New(job.Options)
+	if err != nil {
+		return Report{}, err
+	}
+	g.SetLogger(log)
+
+	if job.Command == "download" {
+		err = g.Download(ctx, job.URLs)
+	} else {
+		for _, u := range job.URLs {
+			if crawlErr := g.Crawl(ctx, u); crawlErr != nil {
+				log.Warn("crawl failed", "url", u, "err", crawlErr)
+				err = crawlErr
+			}
+		}
+	}
+	if closeErr := g.Close(); err == nil {
+		err = closeErr
+	}
+	return g.Report(), err
+}
+
+// update changes the status of the job name and saves it.
+func (s *Scheduler) update(name string, fn func(*JobStatus)) {
+	s.mu.Lock()
+	fn(s.status[name])
+	s.mu.Unlock()
+
+	if err := s.save(); err != nil {
+		orDiscard(s.Logger).Warn("can't save the job status", "err", err)
+	}
+}
+
+// Status returns the status of every job, by name.
+func (s *Scheduler) Status() []JobStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := make([]JobStatus, 0, len(s.status))
+	for _, st := range s.status {
+		list = append(list, *st)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// save writes the status of the jobs to StatusFile through a tmp file.
+func (s *Scheduler) save() error {
+	if s.StatusFile == "" {
+		return nil
+	}
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+
+	data, err := json.MarshalIndent(s.Status(), "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.StatusFile + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.StatusFile)
+}
+
+// ReadJobStatus reads the status file written by a Scheduler.
+func ReadJobStatus(path string) ([]JobStatus, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var list []JobStatus
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return list, nil
+}
@@ -0,0 +1,105 @@
+package grabber
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// The parts of an Options.Layout.
+const (
+	// LayoutHost is the host of the page an image was found on, or of the
+	// image if the page is unknown.
+	LayoutHost = "host"
+	// LayoutPage is the last segment of the path of the page an image was
+	// found on, index for the home page.
+	LayoutPage = "page"
+	// LayoutDate is the day of the run, as 2006-01-02.
+	LayoutDate = "date"
+)
+
+// layoutParts splits a layout into its parts, checking them.
+func layoutParts(layout string) ([]string, error) {
+	if layout == "" {
+		return nil, nil
+	}
+	parts := strings.Split(layout, "/")
+	seen := make(map[string]bool)
+	for _, p := range parts {
+		switch p {
+		case LayoutHost, LayoutPage, LayoutDate:
+		default:
+			return nil, fmt.Errorf("bad layout %q, want host, page or date separated by /", layout)
+		}
+		if seen[p] {
+			return nil, fmt.Errorf("bad layout %q, %s is in it twice", layout, p)
+		}
+		seen[p] = true
+	}
+	return parts, nil
+}
+
+// layOut puts the urls in the directories the parts of Options.Layout give
+// for each, pages being where they were found, keeping the name they would
+// get otherwise. A name BeforeDownload picks wins over it.
+func (g *Grabber) layOut(urls []string, pages map[string]string) {
+	parts, _ := layoutParts(g.Options.Layout)
+	if len(parts) == 0 {
+		return
+	}
+	date := time.Now().Format("2006-01-02")
+	for _, u := range urls {
+		dirs := make([]string, 0, len(parts))
+		for _, p := range parts {
+			switch p {
+			case LayoutHost:
+				host := urlHost(pages[u])
+				if host == "" {
+					host = urlHost(u)
+				}
+				dirs = append(dirs, layoutDir(host))
+			case LayoutPage:
+				dirs = append(dirs, layoutDir(pageSlug(pages[u])))
+			case LayoutDate:
+				dirs = append(dirs, date)
+			}
+		}
+		if dir := filepath.Join(dirs...); filepath.IsLocal(dir) {
+			g.dirs.Store(u, dir)
+		}
+	}
+}
+
+// subdir returns the directory layOut put url in.
+func (g *Grabber) subdir(url string) string {
+	if dir, ok := g.dirs.Load(url); ok {
+		return dir.(string)
+	}
+	return ""
+}
+
+// pageSlug returns the last segment of the path of page, index for the
+// home page and "" if page is unknown.
+func pageSlug(page string) string {
+	u, err := url.Parse(page)
+	if err != nil || page == "" {
+		return ""
+	}
+	slug := path.Base(strings.TrimSuffix(u.Path, "/"))
+	if slug == "." || slug == "/" || slug == "" {
+		return "index"
+	}
+	return strings.TrimSuffix(slug, path.Ext(slug))
+}
+
+// layoutDir makes s fit as a single directory or file name on every
+// system, see safeFileName, "_" if nothing is left of it.
+func layoutDir(s string) string {
+	if s = safeFileName(s); s == "" {
+		return "_"
+	}
+	return s
+}
@@ -0,0 +1,37 @@
+package grabber
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLayoutKeepsDispositionName(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Disposition", `attachment; filename="photo.bin"`)
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write([]byte("the photo"))
+	}))
+	defer srv.Close()
+
+	opts := DefaultOptions()
+	opts.Dir = t.TempDir()
+	opts.Layout = LayoutHost
+	opts.Media = nil
+	g, err := New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+	if err := g.Download(context.Background(), []string{srv.URL + "/download?id=1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := filepath.Join(opts.Dir, "127.0.0.1", "photo.bin")
+	if _, err := os.Stat(want); err != nil {
+		t.Errorf("not saved as %s: %v", want, err)
+	}
+}
@@ -0,0 +1,41 @@
+package grabber
+
+import "strings"
+
+// defaultLazyAttributes are where lazy loading scripts commonly keep the
+// real image url while src holds a placeholder.
+var defaultLazyAttributes = []string{
+	"data-srcset",
+	"data-lazy-srcset",
+	"data-src",
+	"data-lazy-src",
+	"data-original",
+	"data-lazy",
+	"data-url",
+}
+
+// imageSource picks the image url of an element from its attributes: the
+// lazy attributes in order, then srcset, src and href. Attributes named
+// like *srcset are parsed as srcset, taking the biggest candidate. Inline
+// data: urls, often placeholders, are only taken if there is nothing else.
+func imageSource(attr func(name string) string, lazy []string) string {
+	inline := ""
+	for _, names := range [][]string{lazy, {"srcset", "src", "href"}} {
+		for _, name := range names {
+			v := strings.TrimSpace(attr(name))
+			if strings.HasSuffix(name, "srcset") {
+				v = bestSrcset(v)
+			}
+			switch {
+			case v == "":
+			case isDataURL(v):
+				if inline == "" {
+					inline = v
+				}
+			default:
+				return v
+			}
+		}
+	}
+	return inline
+}
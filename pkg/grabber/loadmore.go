@@ -0,0 +1,80 @@
+package grabber
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/chromedp/chromedp"
+)
+
+// loadMoreTimeout is the longest a click on the load more button waits for
+// new elements to show up.
+const loadMoreTimeout = 10 * time.Second
+
+// clickLoadMore clicks the c.LoadMore button of the page in the tab of ctx
+// for as long as it is there and every click brings more elements matching
+// count, a JavaScript expression counting them, but at most c.MaxLoadMore
+// times and only until there are c.ScrollItems of them.
+func (c *Collector) clickLoadMore(ctx context.Context, idle *networkIdle, count, pageURL string) error {
+	// A JSON string is a valid JavaScript string literal
+	quoted, _ := json.Marshal(c.LoadMore)
+	click := `(() => {
+		const e = document.querySelector(` + string(quoted) + `);
+		if (!e || e.disabled || e.getClientRects().length === 0) return false;
+		e.scrollIntoView({block: "center"});
+		e.click();
+		return true;
+	})()`
+
+	var items int
+	if err := chromedp.Run(ctx, chromedp.Evaluate(count, &items)); err != nil {
+		return err
+	}
+	clicks := 0
+	for c.MaxLoadMore == 0 || clicks < c.MaxLoadMore {
+		if c.ScrollItems > 0 && items >= c.ScrollItems {
+			break
+		}
+		var clicked bool
+		if err := chromedp.Run(ctx, chromedp.Evaluate(click, &clicked)); err != nil {
+			return err
+		}
+		if !clicked {
+			break
+		}
+		clicks++
+		idle.wait(scrollIdle, scrollTimeout)
+
+		more, err := waitForMore(ctx, count, items)
+		if err != nil {
+			return err
+		}
+		if more <= items {
+			break
+		}
+		items = more
+	}
+	orDiscard(c.Logger).Debug("clicked load more", "url", pageURL, "clicks", clicks, "items", items)
+	return nil
+}
+
+// waitForMore evaluates count until it is above items, for loadMoreTimeout
+// at the most, and returns its last value.
+func waitForMore(ctx context.Context, count string, items int) (int, error) {
+	deadline := time.Now().Add(loadMoreTimeout)
+	for {
+		var n int
+		if err := chromedp.Run(ctx, chromedp.Evaluate(count, &n)); err != nil {
+			return 0, err
+		}
+		if n > items || !time.Now().Before(deadline) {
+			return n, nil
+		}
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
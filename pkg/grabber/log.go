@@ -0,0 +1,28 @@
+package grabber
+
+import "log/slog"
+
+// discard is the logger of everything no logger was set for.
+var discard = slog.New(slog.DiscardHandler)
+
+// orDiscard returns l, or a logger dropping everything if l is nil.
+func orDiscard(l *slog.Logger) *slog.Logger {
+	if l == nil {
+		return discard
+	}
+	return l
+}
+
+// SetLogger makes the grabber, its Collector and its Downloader log to l.
+// Pages and downloads are logged at info level, the requests behind them at
+// debug level, retries at warn level and urls that failed for good at error
+// level, each with the url as a field. Nothing is logged without a logger.
+func (g *Grabber) SetLogger(l *slog.Logger) {
+	g.Logger = l
+	g.Collector.Logger = l
+	g.Downloader.Logger = l
+}
+
+func (g *Grabber) log() *slog.Logger {
+	return orDiscard(g.Logger)
+}
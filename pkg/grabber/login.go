@@ -0,0 +1,103 @@
+package grabber
+
+import (
+	"context"
+	"errors"
+	"os"
+
+	"github.com/chromedp/cdproto/storage"
+	"github.com/chromedp/chromedp"
+)
+
+// LoginOptions describe the login form filled in before grabbing, for
+// galleries that only show full size images to logged in users.
+type LoginOptions struct {
+	// URL is the page with the login form. Empty skips the login.
+	URL string `json:"url,omitempty" yaml:"url" toml:"url"`
+	// UsernameSelector and PasswordSelector select the form fields.
+	UsernameSelector string `json:"username_selector,omitempty" yaml:"username_selector" toml:"username_selector"`
+	PasswordSelector string `json:"password_selector,omitempty" yaml:"password_selector" toml:"password_selector"`
+	// SubmitSelector is the button clicked to log in. Empty submits the
+	// form of the password field.
+	SubmitSelector string `json:"submit_selector,omitempty" yaml:"submit_selector" toml:"submit_selector"`
+	// WaitSelector is an element that only shows up once logged in, which
+	// is waited for after submitting. Empty waits for the next page to load.
+	WaitSelector string `json:"wait_selector,omitempty" yaml:"wait_selector" toml:"wait_selector"`
+
+	Username string `json:"username,omitempty" yaml:"username" toml:"username"`
+	// Password is never written to the resume state, PasswordEnv names an
+	// environment variable to take it from instead.
+	Password    string `json:"-" yaml:"password" toml:"password"`
+	PasswordEnv string `json:"password_env,omitempty" yaml:"password_env" toml:"password_env"`
+}
+
+// password returns Password, or the value of PasswordEnv if it is unset.
+func (o LoginOptions) password() string {
+	if o.Password == "" && o.PasswordEnv != "" {
+		return os.Getenv(o.PasswordEnv)
+	}
+	return o.Password
+}
+
+// validate checks that the form can be filled in.
+func (o LoginOptions) validate() error {
+	if o.URL == "" {
+		return nil
+	}
+	if o.UsernameSelector == "" || o.PasswordSelector == "" {
+		return errors.New("login needs a username and a password selector")
+	}
+	return nil
+}
+
+// Login fills in and submits the login form described by opts in a chrome
+// tab and puts the cookies of the resulting session into c.Jar, so the
+// crawl and the downloads that follow are logged in too. It gives up when
+// ctx is done.
+func (c *Collector) Login(ctx context.Context, opts LoginOptions) error {
+	ctx, cancel := withTimeout(ctx, c.PageTimeout)
+	defer cancel()
+	return timeoutError(ctx, c.login(ctx, opts), c.PageTimeout)
+}
+
+// login is Login without the timeout.
+func (c *Collector) login(ctx context.Context, opts LoginOptions) error {
+	ctx, cancel, err := c.browser.tab(ctx)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	if err := c.Limiter.WaitURL(ctx, opts.URL); err != nil {
+		return err
+	}
+
+	actions := []chromedp.Action{
+		chromedp.Navigate(opts.URL),
+		chromedp.WaitVisible(opts.UsernameSelector),
+		chromedp.SendKeys(opts.UsernameSelector, opts.Username),
+		chromedp.SendKeys(opts.PasswordSelector, opts.password()),
+	}
+	if opts.SubmitSelector != "" {
+		actions = append(actions, chromedp.Click(opts.SubmitSelector, chromedp.NodeVisible))
+	} else {
+		actions = append(actions, chromedp.Submit(opts.PasswordSelector))
+	}
+	if opts.WaitSelector != "" {
+		actions = append(actions, chromedp.WaitVisible(opts.WaitSelector))
+	} else {
+		actions = append(actions, chromedp.WaitReady("body"))
+	}
+	if c.Jar != nil {
+		actions = append(actions, chromedp.ActionFunc(func(ctx context.Context) error {
+			cookies, err := storage.GetCookies().Do(ctx)
+			if err != nil {
+				return err
+			}
+			setCookies(c.Jar, chromeCookies(cookies))
+			return nil
+		}))
+	}
+
+	return chromedp.Run(ctx, actions...)
+}
@@ -0,0 +1,272 @@
+package grabber
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// manifestSaveEvery is how many new entries are buffered before the
+// manifest is written out again, on top of the final save.
+const manifestSaveEvery = 25
+
+// ManifestEntry records a url that was downloaded.
+type ManifestEntry struct {
+	URL string `json:"url"`
+	// Page is the page the url was found on, if known.
+	Page string `json:"page,omitempty"`
+	// Path is relative to the directory of the manifest.
+	Path string `json:"path"`
+	// Name is the file name of the url, if it had to be changed to be
+	// safe on every system.
+	Name   string `json:"name,omitempty"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+	DHash  string `json:"dhash,omitempty"`
+	// Thumb is the thumbnail of the image relative to the manifest too,
+	// if one was made.
+	Thumb string `json:"thumb,omitempty"`
+	// Modified is the Last-Modified time the server sent, if any.
+	Modified time.Time `json:"modified,omitzero"`
+	ETag     string    `json:"etag,omitempty"`
+	Time     time.Time `json:"time"`
+}
+
+// Manifest maps source urls to the files they were saved as, so re-running
+// against the same gallery only downloads what is new.
+type Manifest struct {
+	mu      sync.Mutex
+	path    string
+	dirty   int
+	entries map[string]ManifestEntry
+	// saveEvery is manifestSaveEvery, or 0 to only save at the end.
+	saveEvery int
+
+	// storage holds the manifest and the files instead of the local disk
+	// if set, path is then the name of the manifest in it and root the url
+	// it was opened with.
+	storage Storage
+	root    string
+}
+
+// LoadManifest reads the manifest at path. A missing file is an empty
+// manifest that will be created on the first Save.
+func LoadManifest(path string) (*Manifest, error) {
+	m := &Manifest{path: path, entries: make(map[string]ManifestEntry), saveEvery: manifestSaveEvery}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return m, m.load(data)
+}
+
+// loadStoredManifest reads the manifest name from the Storage s opened from
+// the url root.
+func loadStoredManifest(s Storage, root, name string) (*Manifest, error) {
+	m := &Manifest{path: name, entries: make(map[string]ManifestEntry), saveEvery: manifestSaveEvery, storage: s, root: root}
+	// An archive takes every file only once
+	if _, ok := s.(*archiveStorage); ok {
+		m.saveEvery = 0
+	}
+
+	r, err := s.Open(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return m, m.load(data)
+}
+
+// load adds the entries of the manifest file data.
+func (m *Manifest) load(data []byte) error {
+	var entries []ManifestEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return err
+	}
+	for _, e := range entries {
+		m.entries[e.URL] = e
+	}
+
+	return nil
+}
+
+// Has reports whether url was downloaded before and its file is still there
+// with the recorded size.
+func (m *Manifest) Has(url string) bool {
+	m.mu.Lock()
+	e, ok := m.entries[url]
+	m.mu.Unlock()
+	if !ok {
+		return false
+	}
+
+	if m.storage != nil {
+		size, err := m.storage.Stat(e.Path)
+		return err == nil && size == e.Size
+	}
+	info, err := os.Stat(filepath.Join(filepath.Dir(m.path), e.Path))
+	return err == nil && info.Size() == e.Size
+}
+
+// index adds the files of the manifest to the checksum and perceptual
+// hash indexes.
+func (m *Manifest) index(x *HashIndex, p *PerceptualIndex) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, e := range m.entries {
+		path := m.location(e.Path)
+		x.add(e.SHA256, path)
+		if e.DHash != "" {
+			p.add(e.DHash, path)
+		}
+	}
+}
+
+// Add records a downloaded file, saving the manifest every few entries.
+func (m *Manifest) Add(f *File) error {
+	sum := f.SHA256
+	if sum == "" {
+		var err error
+		if sum, err = fileChecksum(f.Path); err != nil {
+			return err
+		}
+	}
+
+	var thumb string
+	if f.Thumb != "" {
+		thumb = m.relative(f.Thumb)
+	}
+
+	var name string
+	if raw := urlFileName(f.URL); safeFileName(raw) != raw {
+		name = raw
+	}
+
+	m.mu.Lock()
+	m.entries[f.URL] = ManifestEntry{URL: f.URL, Page: f.Page, Path: m.relative(f.Path), Name: name, Size: f.Size, SHA256: sum, DHash: f.PerceptualHash, Thumb: thumb, Modified: f.Modified, ETag: f.ETag, Time: time.Now()}
+	m.dirty++
+	save := m.saveEvery > 0 && m.dirty >= m.saveEvery
+	m.mu.Unlock()
+
+	if save {
+		return m.Save()
+	}
+	return nil
+}
+
+// usage returns the number and total size of the files in the manifest.
+// Urls saved as the same file count once.
+func (m *Manifest) usage() (int, int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var bytes int64
+	paths := make(map[string]bool)
+	for _, e := range m.entries {
+		if !paths[e.Path] {
+			paths[e.Path] = true
+			bytes += e.Size
+		}
+	}
+	return len(paths), bytes
+}
+
+// relative returns the path p relative to the manifest.
+func (m *Manifest) relative(p string) string {
+	if m.storage != nil {
+		return strings.TrimPrefix(p, strings.TrimSuffix(m.root, "/")+"/")
+	}
+	if rel, err := filepath.Rel(filepath.Dir(m.path), p); err == nil {
+		return rel
+	}
+	return p
+}
+
+// Save writes the manifest to disk through a tmp file, so a crash while
+// writing never leaves a truncated manifest behind.
+func (m *Manifest) Save() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entries := make([]ManifestEntry, 0, len(m.entries))
+	for _, e := range m.entries {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].URL < entries[j].URL })
+
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	if m.storage != nil {
+		if err := m.saveStored(data); err != nil {
+			return err
+		}
+	} else {
+		if err := os.WriteFile(m.path+".tmp", data, 0600); err != nil {
+			return err
+		}
+		if err := os.Rename(m.path+".tmp", m.path); err != nil {
+			return err
+		}
+	}
+
+	m.dirty = 0
+	return nil
+}
+
+// saveStored is Save for a manifest in a Storage.
+func (m *Manifest) saveStored(data []byte) error {
+	w, err := m.storage.Create(m.path + ".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		w.Abort()
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return m.storage.Rename(m.path+".tmp", m.path)
+}
+
+// fileChecksum returns the hex encoded SHA-256 of a file.
+func fileChecksum(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
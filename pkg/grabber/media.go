@@ -0,0 +1,88 @@
+package grabber
+
+import (
+	"errors"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// The kinds of media Options.Media can ask for.
+const (
+	MediaImage = "image"
+	MediaVideo = "video"
+)
+
+// videoSelector selects the videos embedded in a page and the links to
+// video files.
+const videoSelector = `video[src], video source[src], ` +
+	`a[href$=".mp4"], a[href$=".webm"], a[href$=".mov"], a[href$=".m4v"], a[href$=".m3u8"], a[href$=".mpd"]`
+
+// gifLinkSelector selects the links to animated gifs, which galleries often
+// use for short clips.
+const gifLinkSelector = `a[href$=".gif"]`
+
+// videoFileExtensions are the extensions of the video files grabbed.
+var videoFileExtensions = map[string]bool{
+	".mp4":  true,
+	".webm": true,
+	".mov":  true,
+	".m4v":  true,
+	".ogv":  true,
+}
+
+// mediaKind tells from its extension whether u is an image or a video, ""
+// if the extension doesn't say.
+func mediaKind(u string) string {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return ""
+	}
+	ext := strings.ToLower(path.Ext(parsed.Path))
+	switch {
+	case isImageExtension(ext):
+		return MediaImage
+	case videoFileExtensions[ext], ext == ".m3u8", ext == ".mpd":
+		return MediaVideo
+	}
+	return ""
+}
+
+// wants reports whether Media asks for the kind of media. Empty Media only
+// asks for images.
+func (o Options) wants(kind string) bool {
+	if len(o.Media) == 0 {
+		return kind == MediaImage
+	}
+	for _, m := range o.Media {
+		if m == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// mediaSelector is ImageSelector extended to the kinds of media asked for.
+func (o Options) mediaSelector() string {
+	var selectors []string
+	if o.wants(MediaImage) {
+		if o.ImageSelector != "" {
+			selectors = append(selectors, o.ImageSelector)
+		}
+		selectors = append(selectors, gifLinkSelector)
+	}
+	if o.wants(MediaVideo) {
+		selectors = append(selectors, videoSelector)
+	}
+	return strings.Join(selectors, ", ")
+}
+
+// validateMedia checks that Media only asks for kinds of media there are.
+func (o Options) validateMedia() error {
+	for _, m := range o.Media {
+		if m != MediaImage && m != MediaVideo {
+			return errors.New("unknown media " + m + ", want image or video")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package grabber
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// UnchangedReason is the reason images the server says didn't change
+// since they were saved are skipped with Options.Mirror.
+const UnchangedReason = "unchanged"
+
+// Changed asks the server with a HEAD request whether url changed since
+// it was saved as e, by its ETag or else its Last-Modified time. An image
+// for which neither was recorded, or the server sends neither, counts as
+// unchanged, as nothing tells otherwise.
+func (d *Downloader) Changed(ctx context.Context, url, referer string, e ManifestEntry) (bool, error) {
+	if e.ETag == "" && e.Modified.IsZero() || checkURL(url) != nil {
+		return false, nil
+	}
+
+	ctx, cancel := withTimeout(ctx, d.Timeout)
+	defer cancel()
+	resp, err := d.head(ctx, url, referer)
+	if err != nil {
+		return false, timeoutError(ctx, err, d.Timeout)
+	}
+	resp.Body.Close()
+
+	if etag := resp.Header.Get("ETag"); etag != "" && e.ETag != "" {
+		return weakETag(etag) != weakETag(e.ETag), nil
+	}
+	if t, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil && !e.Modified.IsZero() {
+		return !t.Equal(e.Modified), nil
+	}
+	return false, nil
+}
+
+// weakETag drops the W/ prefix of a weak entity tag, as servers add and
+// drop it depending on the encoding.
+func weakETag(etag string) string {
+	return strings.TrimPrefix(etag, "W/")
+}
+
+// entry returns what the manifest has of url.
+func (m *Manifest) entry(url string) (ManifestEntry, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, ok := m.entries[url]
+	return e, ok
+}
+
+// remove drops url from the manifest and returns what it had of it, with
+// whether another url was saved as the same file.
+func (m *Manifest) remove(url string) (e ManifestEntry, shared, ok bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if e, ok = m.entries[url]; !ok {
+		return e, false, false
+	}
+	delete(m.entries, url)
+	m.dirty++
+	for _, other := range m.entries {
+		if other.Path == e.Path {
+			shared = true
+			break
+		}
+	}
+	return e, shared, true
+}
+
+// location returns the path of the file rel of the manifest, as the
+// Downloader names it.
+func (m *Manifest) location(rel string) string {
+	if m.storage != nil {
+		return rel
+	}
+	return filepath.Join(filepath.Dir(m.path), rel)
+}
+
+// changed reports whether the url of e, which the manifest has, would be
+// downloaded again by Options.Mirror. Urls whose file is gone would,
+// without asking the server.
+func (g *Grabber) changed(ctx context.Context, e ManifestEntry, referer string) (bool, error) {
+	if !g.manifest.Has(e.URL) {
+		return true, nil
+	}
+	var changed bool
+	_, err := g.retry(ctx, e.URL, func() (err error) {
+		changed, err = g.Downloader.Changed(ctx, e.URL, referer, e)
+		return err
+	})
+	return changed, err
+}
+
+// replace puts the new download f of a changed url in the place of the
+// file old was saved as. Files of a Storage, and downloads that turned out
+// to be the same as another file, stay where they are.
+func (g *Grabber) replace(f *File, old ManifestEntry) {
+	if g.Downloader.Storage != nil || f.DuplicateOf != "" || f.SimilarTo != "" {
+		return
+	}
+	path := g.manifest.location(old.Path)
+	if path == f.Path {
+		return
+	}
+	if filepath.Ext(path) != filepath.Ext(f.Path) {
+		// The image changed its type too, keep the new name
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			g.log().Warn("can't remove the old file", "path", path, "err", err)
+		}
+		return
+	}
+	if err := os.Rename(f.Path, path); err != nil {
+		g.log().Warn("can't replace the old file", "path", path, "err", err)
+		return
+	}
+	f.Path = path
+}
+
+// deleteGone removes the files of the images of pages, the ones the crawl
+// visited, the manifest has that it didn't find again, along with their
+// thumbnails, and drops them from the manifest. Images of other pages,
+// as those of other galleries of the site, are left alone, and so are
+// files another url was also saved as.
+func (g *Grabber) deleteGone(pages, found map[string]bool) {
+	gone := g.manifest.gone(pages, found)
+	g.failures.diff(nil, gone)
+	for _, u := range gone {
+		e, shared, ok := g.manifest.remove(u)
+		if !ok || shared {
+			continue
+		}
+		for _, rel := range []string{e.Path, e.Thumb} {
+			if rel == "" {
+				continue
+			}
+			var err error
+			if s := g.Downloader.Storage; s != nil {
+				err = s.Remove(rel)
+			} else {
+				err = os.Remove(g.manifest.location(rel))
+			}
+			if err != nil && !errors.Is(err, fs.ErrNotExist) {
+				g.log().Warn("can't delete the file of a gone image", "url", u, "path", rel, "err", err)
+			}
+		}
+		g.log().Info("deleted gone image", "url", u, "path", e.Path)
+	}
+}
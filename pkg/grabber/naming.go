@@ -0,0 +1,269 @@
+package grabber
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// ErrBadURL is wrapped by the errors of urls that can't be fetched as they
+// aren't valid http or https urls.
+var ErrBadURL = errors.New("bad url")
+
+// checkURL returns an ErrBadURL if rawURL isn't an http or https url with
+// a host.
+func checkURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrBadURL, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("%w: %q is not an http or https url", ErrBadURL, rawURL)
+	}
+	return nil
+}
+
+// getFileName returns the last segment of the path of a url made safe to
+// save as on every system, see safeFileName, "" if there is none or it
+// isn't a url. The query parameters other than the tracking ones are added
+// to it, so image.php?id=1 and image.php?id=2 aren't both image.php.
+func getFileName(fullUrlFile string, tracking []string) string {
+	name := urlFileName(fullUrlFile)
+	if q := fileNameQuery(fullUrlFile, tracking); q != "" && name != "" {
+		ext := filepath.Ext(name)
+		name = strings.TrimSuffix(name, ext) + "_" + q + ext
+	}
+	return safeFileName(name)
+}
+
+// maxQueryName is the longest query fileNameQuery spells out, longer ones
+// are hashed.
+const maxQueryName = 32
+
+// fileNameQuery returns the query parameters of a url that aren't in
+// tracking for its file name, as id-1_page-2 for ?page=2&id=1, or a hash of
+// them if that would be long or odd looking. It is "" if there are none.
+func fileNameQuery(fullUrlFile string, tracking []string) string {
+	fileUrl, err := url.Parse(fullUrlFile)
+	if err != nil {
+		return ""
+	}
+	query := normalizeQuery(fileUrl.RawQuery, tracking)
+	if query == "" {
+		return ""
+	}
+
+	var parts []string
+	for _, p := range strings.Split(query, "&") {
+		k, v, _ := strings.Cut(p, "=")
+		k, _ = url.QueryUnescape(k)
+		v, _ = url.QueryUnescape(v)
+		if v == "" {
+			parts = append(parts, k)
+		} else {
+			parts = append(parts, k+"-"+v)
+		}
+	}
+	name := strings.Join(parts, "_")
+	if len(name) > maxQueryName || strings.IndexFunc(name, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.')
+	}) >= 0 {
+		return shortHash([]byte(query))
+	}
+	return name
+}
+
+// urlFileName returns the last segment of the path of a url, decoded, ""
+// if there is none. Urls that don't parse, as with a stray %, still give
+// the segment they end with.
+func urlFileName(fullUrlFile string) string {
+	path := fullUrlFile
+	if fileUrl, err := url.Parse(fullUrlFile); err == nil {
+		path = fileUrl.Path
+	} else if i := strings.IndexAny(path, "?#"); i >= 0 {
+		path = path[:i]
+	}
+	segments := strings.Split(path, "/")
+
+	return decodeFileName(segments[len(segments)-1])
+}
+
+// decodeFileName decodes what is left percent-encoded in name, as in the
+// names of urls encoded twice or of Content-Disposition headers, if that
+// gives valid UTF-8, and brings it to Unicode NFC so a name written on
+// macOS and elsewhere is the same file.
+func decodeFileName(name string) string {
+	if strings.Contains(name, "%") {
+		if decoded, err := url.PathUnescape(name); err == nil && utf8.ValidString(decoded) {
+			name = decoded
+		}
+	}
+	return norm.NFC.String(strings.ToValidUTF8(name, "_"))
+}
+
+// maxFileName is the longest file name safeFileName returns, in bytes,
+// leaving room below the 255 of most file systems for the suffixes of
+// .tmp files and of claimName.
+const maxFileName = 200
+
+// windowsReserved are the device names Windows won't create a file as,
+// whatever the extension.
+var windowsReserved = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// safeFileName makes name a file name every system can create, so the
+// same files come out on Windows as anywhere else: the characters Windows
+// reserves and control characters become _, trailing dots and spaces are
+// dropped, device names like CON get a _ in front and names longer than
+// maxFileName are cut, keeping the extension, with a hash of the whole
+// name added to keep them apart.
+func safeFileName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if r < ' ' || r == 0x7f || strings.ContainsRune(`<>:"/\|?*`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	name = strings.TrimRight(strings.TrimSpace(name), ". ")
+	if name == "" {
+		return ""
+	}
+
+	stem, _, _ := strings.Cut(name, ".")
+	if windowsReserved[strings.ToUpper(strings.TrimSpace(stem))] {
+		name = "_" + name
+	}
+
+	if len(name) > maxFileName {
+		ext := filepath.Ext(name)
+		if len(ext) > 16 {
+			ext = ""
+		}
+		suffix := "-" + shortHash([]byte(name)) + ext
+		cut := name[:maxFileName-len(suffix)]
+		// Don't cut a character in two
+		for !utf8.ValidString(cut) {
+			cut = cut[:len(cut)-1]
+		}
+		name = cut + suffix
+	}
+	return name
+}
+
+// dispositionFileName returns the file name from the Content-Disposition
+// header of resp, e.g. attachment; filename="photo.jpg", or "" if there is
+// none. The RFC 5987 filename* form is decoded by mime. Any directory part
+// is dropped so the name can't escape the output directory.
+func dispositionFileName(resp *http.Response) string {
+	cd := resp.Header.Get("Content-Disposition")
+	if cd == "" {
+		return ""
+	}
+
+	_, params, err := mime.ParseMediaType(cd)
+	if err != nil {
+		return ""
+	}
+
+	name := params["filename"]
+	if i := strings.LastIndexAny(name, `/\`); i >= 0 {
+		name = name[i+1:]
+	}
+	name = decodeFileName(name)
+	if name == "." || name == ".." {
+		return ""
+	}
+	return safeFileName(name)
+}
+
+// sameHost reports whether both urls point to the same host.
+func sameHost(a, b string) bool {
+	ua, err := url.Parse(a)
+	if err != nil {
+		return false
+	}
+	ub, err := url.Parse(b)
+	if err != nil {
+		return false
+	}
+
+	return strings.EqualFold(ua.Hostname(), ub.Hostname())
+}
+
+// isHTTP reports whether u is an http or https url, as opposed to mailto:,
+// javascript: and the like.
+func isHTTP(u string) bool {
+	return strings.HasPrefix(u, "http://") || strings.HasPrefix(u, "https://")
+}
+
+// shortHash returns the first 8 hex digits of the SHA-256 of data.
+func shortHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:4])
+}
+
+// claimName returns the name a file of the given size and checksum should
+// be saved as: name itself if it is free or already holds the same bytes,
+// otherwise name with a short hash of the content appended, e.g.
+// photo-1a2b3c4d.jpg, so that different images with the same name don't
+// overwrite each other.
+func claimName(name string, size int64, sum string) (string, error) {
+	return claimNameWith(name, sum, func(candidate string) (bool, error) {
+		return freeOrSame(candidate, size, sum)
+	})
+}
+
+// claimNameWith is claimName with free telling whether a candidate name is
+// free or already holds the same bytes.
+func claimNameWith(name, sum string, free func(candidate string) (bool, error)) (string, error) {
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext) + "-" + sum[:8]
+
+	candidate := name
+	for i := 1; ; i++ {
+		ok, err := free(candidate)
+		if err != nil || ok {
+			return candidate, err
+		}
+
+		candidate = base + ext
+		if i > 1 {
+			candidate = fmt.Sprintf("%s-%d%s", base, i, ext)
+		}
+	}
+}
+
+// freeOrSame reports whether nothing exists at name or it has the given
+// size and checksum.
+func freeOrSame(name string, size int64, sum string) (bool, error) {
+	info, err := os.Stat(name)
+	if os.IsNotExist(err) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if info.Size() != size {
+		return false, nil
+	}
+
+	existing, err := fileChecksum(name)
+	if err != nil {
+		return false, err
+	}
+
+	return existing == sum, nil
+}
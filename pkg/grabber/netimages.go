@@ -0,0 +1,61 @@
+package grabber
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+)
+
+// networkImages records the images a tab loads, as told by the responses
+// chrome gets rather than the page, so images a script draws or sets as a
+// background are seen too.
+type networkImages struct {
+	mu   sync.Mutex
+	urls []string
+	seen map[string]bool
+}
+
+// watchImages starts recording the images loaded by the tab of ctx until
+// it is closed. It has to be called before the page is opened to see all
+// of them.
+func watchImages(ctx context.Context) (*networkImages, error) {
+	w := &networkImages{seen: make(map[string]bool)}
+	chromedp.ListenTarget(ctx, func(ev interface{}) {
+		e, ok := ev.(*network.EventResponseReceived)
+		if !ok || e.Response == nil || !isHTTP(e.Response.URL) ||
+			!strings.HasPrefix(strings.ToLower(e.Response.MimeType), "image/") {
+			return
+		}
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		if !w.seen[e.Response.URL] {
+			w.seen[e.Response.URL] = true
+			w.urls = append(w.urls, e.Response.URL)
+		}
+	})
+	if err := chromedp.Run(ctx, network.Enable()); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// add returns refs with the images loaded so far that aren't in it yet,
+// as found on pageURL.
+func (w *networkImages) add(refs []ImageRef, pageURL string) []ImageRef {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	have := make(map[string]bool, len(refs))
+	for _, ref := range refs {
+		have[ref.URL] = true
+	}
+	for _, u := range w.urls {
+		if !have[u] {
+			refs = append(refs, ImageRef{URL: u, Page: pageURL})
+		}
+	}
+	return refs
+}
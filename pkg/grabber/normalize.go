@@ -0,0 +1,108 @@
+package grabber
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// defaultTrackingParams are the query parameters analytics and ad networks
+// add to links, which never change the image they point to. A trailing *
+// matches any parameter starting with the rest.
+var defaultTrackingParams = []string{
+	"utm_*",
+	"fbclid",
+	"gclid",
+	"gclsrc",
+	"dclid",
+	"msclkid",
+	"yclid",
+	"igshid",
+	"mc_cid",
+	"mc_eid",
+	"_ga",
+	"_gl",
+	"ref_src",
+}
+
+// normalizeURL returns the canonical form of an http or https url, so the
+// same page or image found under cosmetically different urls is only
+// fetched once: the scheme and host lowercased, the default port, the
+// fragment, dot segments and the tracking parameters dropped and the other
+// parameters sorted by name. Other urls are returned as they are.
+func normalizeURL(rawURL string, tracking []string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return rawURL
+	}
+
+	u.Host = strings.ToLower(u.Host)
+	if port := u.Port(); port == "80" && u.Scheme == "http" || port == "443" && u.Scheme == "https" {
+		u.Host = strings.TrimSuffix(u.Host, ":"+port)
+	}
+	// Resolving an empty reference drops the dot segments of the path
+	u = u.ResolveReference(&url.URL{})
+	if u.Path == "" {
+		u.Path = "/"
+	}
+	u.Fragment, u.RawFragment = "", ""
+	u.RawQuery = normalizeQuery(u.RawQuery, tracking)
+	u.ForceQuery = false
+
+	return u.String()
+}
+
+// normalizeQuery drops the empty and the tracking parameters of query and
+// sorts the others by name, keeping their encoding and the order of the
+// values of repeated ones.
+func normalizeQuery(query string, tracking []string) string {
+	if query == "" {
+		return ""
+	}
+
+	var params []string
+	for _, p := range strings.Split(query, "&") {
+		if p != "" && !isTrackingParam(queryName(p), tracking) {
+			params = append(params, p)
+		}
+	}
+	sort.SliceStable(params, func(i, j int) bool {
+		return queryName(params[i]) < queryName(params[j])
+	})
+	return strings.Join(params, "&")
+}
+
+// queryName returns the unescaped name of the query parameter p.
+func queryName(p string) string {
+	name, _, _ := strings.Cut(p, "=")
+	if unescaped, err := url.QueryUnescape(name); err == nil {
+		return unescaped
+	}
+	return name
+}
+
+// isTrackingParam reports whether name matches one of the tracking
+// parameters, ignoring case.
+func isTrackingParam(name string, tracking []string) bool {
+	name = strings.ToLower(name)
+	for _, t := range tracking {
+		t = strings.ToLower(t)
+		if prefix, ok := strings.CutSuffix(t, "*"); ok {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		} else if name == t {
+			return true
+		}
+	}
+	return false
+}
+
+// normalize returns the canonical form of u, or u as it is if
+// c.NormalizeURLs is off.
+func (c *Collector) normalize(u string) string {
+	if !c.NormalizeURLs {
+		return u
+	}
+	return normalizeURL(u, c.TrackingParams)
+}
@@ -0,0 +1,615 @@
+package grabber
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Options configures a Grabber.
+type Options struct {
+	// Dir is the directory the images are saved into, or the url of a
+	// Storage to upload them to instead: s3://bucket/prefix,
+	// gs://bucket/prefix, azblob://container/prefix, sftp://host/dir or
+	// webdav(s)://host/path. A .zip, .tar or .tar.gz file is an archive
+	// the images are written into.
+	Dir string `json:"dir" yaml:"output" toml:"output"`
+	// Concurrency is the number of downloads running at the same time.
+	Concurrency int `json:"concurrency" yaml:"concurrency" toml:"concurrency"`
+	// MaxConnsPerHost caps the connections open to a host at once, which
+	// the downloads and page requests to it then share. 0 is no limit.
+	MaxConnsPerHost int `json:"max_conns_per_host,omitempty" yaml:"max_conns_per_host" toml:"max_conns_per_host"`
+	// LinkSelector selects the links to photo detail pages.
+	LinkSelector string `json:"link_selector" yaml:"link_selector" toml:"link_selector"`
+	// ImageSelector selects the elements whose srcset, src or href is an
+	// image to download.
+	ImageSelector string `json:"image_selector" yaml:"image_selector" toml:"image_selector"`
+	// LazyAttributes are the attributes holding the real url of lazy
+	// loaded images, looked at before srcset, src and href. Names ending
+	// in srcset are parsed as srcset.
+	LazyAttributes []string `json:"lazy_attributes" yaml:"lazy_attributes" toml:"lazy_attributes"`
+	// MetaImages also grabs the og:image and twitter:image of every page.
+	MetaImages bool `json:"meta_images" yaml:"meta_images" toml:"meta_images"`
+	// EmbeddedImages also grabs the images found in the JSON-LD and the
+	// other JSON data, like __NEXT_DATA__, embedded in every page.
+	EmbeddedImages bool `json:"embedded_images" yaml:"embedded_images" toml:"embedded_images"`
+	// ClickSelector is clicked on photo detail pages to reveal the image,
+	// unless an Extractor registered for the site knows better.
+	ClickSelector string `json:"click_selector" yaml:"click_selector" toml:"click_selector"`
+	// StaticFirst looks for the full size image of photo detail pages in
+	// their plain HTML first, as the file ClickSelector links to or the
+	// images ImageSelector matches, and only opens them in chrome if there
+	// is none. Pages of sites with an Extractor of their own always are.
+	StaticFirst bool `json:"static_first,omitempty" yaml:"static_first" toml:"static_first"`
+	// NetworkImages also grabs every image chrome loads while a photo
+	// detail page is open, for the ones a script draws or sets as a
+	// background that no element of the page points to.
+	NetworkImages bool `json:"network_images,omitempty" yaml:"network_images" toml:"network_images"`
+	// Scroll opens the pages in chrome and scrolls down until no more links
+	// and images load, for galleries with infinite scrolling. MaxScrolls
+	// and ScrollItems stop it after that many scrolls or once that many
+	// links and images are there, 0 is no limit.
+	Scroll      bool `json:"scroll,omitempty" yaml:"scroll" toml:"scroll"`
+	MaxScrolls  int  `json:"max_scrolls" yaml:"max_scrolls" toml:"max_scrolls"`
+	ScrollItems int  `json:"scroll_items,omitempty" yaml:"scroll_items" toml:"scroll_items"`
+	// Tabs is how many photo detail pages are opened in chrome at the same
+	// time, each in a tab of its own. 0 opens one at a time.
+	Tabs int `json:"tabs" yaml:"tabs" toml:"tabs"`
+	// LoadMoreSelector is the "load more" button of galleries that load the
+	// rest of their photos on a click. The pages are opened in chrome and
+	// the button clicked until it is gone or stops bringing more links and
+	// images, but at most MaxLoadMore times, 0 is no limit, and only until
+	// there are ScrollItems of them.
+	LoadMoreSelector string `json:"load_more_selector,omitempty" yaml:"load_more_selector" toml:"load_more_selector"`
+	MaxLoadMore      int    `json:"max_load_more" yaml:"max_load_more" toml:"max_load_more"`
+	// Screenshots saves a PNG screenshot of the biggest element matching
+	// ScreenshotSelector of photo detail pages that have no image to
+	// download, like images drawn on a canvas or held in blob: urls.
+	Screenshots        bool   `json:"screenshots,omitempty" yaml:"screenshots" toml:"screenshots"`
+	ScreenshotSelector string `json:"screenshot_selector" yaml:"screenshot_selector" toml:"screenshot_selector"`
+	// ExportLinks makes Crawl write the photo links and images it finds
+	// to this CSV file, along with the page they were found on, instead of
+	// grabbing them.
+	ExportLinks string `json:"export_links,omitempty" yaml:"export_links" toml:"export_links"`
+	// Layout saves the downloads into subdirectories of Dir named after
+	// these parts separated by /: host, page or date, e.g. host/page. A
+	// name Grabber.BeforeDownload picks wins over it.
+	Layout string `json:"layout,omitempty" yaml:"layout" toml:"layout"`
+	// Fsync flushes every download to the disk before it gets its name,
+	// so a power loss never leaves a truncated file looking complete. The
+	// partial downloads of a run that went down are moved out of the way
+	// by the next one rather than resumed, see Grabber.Close.
+	Fsync bool `json:"fsync,omitempty" yaml:"fsync" toml:"fsync"`
+	// DryRun crawls and asks the servers about every image without
+	// downloading them or writing anything to disk.
+	DryRun bool `json:"dry_run,omitempty" yaml:"dry_run" toml:"dry_run"`
+	// Preflight asks the servers for the size of every image before
+	// downloading any and stops if they won't fit on the disk.
+	Preflight bool `json:"preflight,omitempty" yaml:"preflight" toml:"preflight"`
+	// Browser configures chrome for the photo detail pages.
+	Browser BrowserOptions `json:"browser" yaml:"browser" toml:"browser"`
+	// Sitemap crawls the pages listed in the sitemaps of the site of the
+	// start page instead of the start page. Start urls ending in .xml or
+	// .xml.gz are always read as sitemaps.
+	Sitemap bool `json:"sitemap,omitempty" yaml:"sitemap" toml:"sitemap"`
+	// Depth is how many levels of same-host links are crawled below the
+	// start page. 0 only visits the start page.
+	Depth int `json:"depth" yaml:"depth" toml:"depth"`
+	// Pagination follows the links to the next page of a gallery, marked
+	// rel="next" or numbered as in ?page=2 or /page/2, without counting
+	// them as a level deeper.
+	Pagination bool `json:"pagination" yaml:"pagination" toml:"pagination"`
+	// AllowedDomains are the only hosts, with their subdomains, crawling
+	// and downloading may touch. The registered domain of the start page is
+	// always in, so a crawl of www.example.com still grabs the images on
+	// img.cdn.example.com. Empty puts no limit on downloads.
+	AllowedDomains []string `json:"allowed_domains,omitempty" yaml:"allowed_domains" toml:"allowed_domains"`
+	// SpanHosts crawls the pages linked on other hosts too, as far as
+	// AllowedDomains lets it, instead of only those on the host of the page
+	// linking to them.
+	SpanHosts bool `json:"span_hosts,omitempty" yaml:"span_hosts" toml:"span_hosts"`
+	// NormalizeURLs brings the links and images found to a canonical form
+	// before telling them apart, so the same one isn't fetched under urls
+	// differing only in case, port, fragment, parameter order or the
+	// TrackingParams they carry.
+	NormalizeURLs bool `json:"normalize_urls" yaml:"normalize_urls" toml:"normalize_urls"`
+	// TrackingParams are the query parameters NormalizeURLs drops. A
+	// trailing * matches any parameter starting with the rest.
+	TrackingParams []string `json:"tracking_params" yaml:"tracking_params" toml:"tracking_params"`
+	// MaxPages caps the number of pages crawled, 0 is no limit.
+	MaxPages int `json:"max_pages,omitempty" yaml:"max_pages" toml:"max_pages"`
+	// Limit caps the number of photo pages resolved and images downloaded,
+	// 0 is no limit.
+	Limit int `json:"limit,omitempty" yaml:"limit" toml:"limit"`
+	// Headers are sent with every request, by colly, chrome and the
+	// downloader alike.
+	Headers map[string]string `json:"headers,omitempty" yaml:"headers" toml:"headers"`
+	// UserAgent replaces the default user agent of all requests if set.
+	UserAgent string `json:"user_agent,omitempty" yaml:"user_agent" toml:"user_agent"`
+	// Auth is the USER:PASSWORD sent with HTTP Basic auth, Bearer the token
+	// sent as a bearer token, along with the Headers. An Authorization
+	// header of Headers wins over both. Neither is written to the resume
+	// state, so they have to be given again to resume.
+	Auth   string `json:"-" yaml:"auth" toml:"auth"`
+	Bearer string `json:"-" yaml:"bearer" toml:"bearer"`
+	// Proxies are used round robin for the requests, as http, https or
+	// socks5 urls. Chrome only uses the first one, unless
+	// Browser.ProxyServer is set.
+	Proxies []string `json:"proxies,omitempty" yaml:"proxies" toml:"proxies"`
+	// ProxyFile is a file listing more proxies, one per line.
+	ProxyFile string `json:"proxy_file,omitempty" yaml:"proxy_file" toml:"proxy_file"`
+	// ProxyMaxFails is how many requests in a row may fail through a
+	// proxy before it is taken out of the rotation, 0 never does.
+	ProxyMaxFails int `json:"proxy_max_fails" yaml:"proxy_max_fails" toml:"proxy_max_fails"`
+	// ProxyRecheck is how long a dead proxy rests before it is tried
+	// again, 0 keeps it out for the rest of the run.
+	ProxyRecheck time.Duration `json:"proxy_recheck,omitempty" yaml:"proxy_recheck" toml:"proxy_recheck"`
+	// Resolve pins hosts to an address as HOST:IP, for all requests and
+	// chrome alike, instead of looking them up, e.g. to grab from the new
+	// server of a host during a migration.
+	Resolve []string `json:"resolve,omitempty" yaml:"resolve" toml:"resolve"`
+	// IPVersion only connects over IPv4 if 4 or IPv6 if 6, for hosts whose
+	// other addresses are broken. 0 uses both. Chrome isn't held to it.
+	IPVersion int `json:"ip_version,omitempty" yaml:"ip_version" toml:"ip_version"`
+	// TLS configures the certificates trusted and sent, for hosts behind
+	// a private CA.
+	TLS TLSOptions `json:"tls" yaml:"tls" toml:"tls"`
+	// CookiesFile is a Netscape cookies.txt or JSON file with cookies to
+	// start the session with, e.g. exported from a logged in browser.
+	CookiesFile string `json:"cookies_file,omitempty" yaml:"cookies_file" toml:"cookies_file"`
+	// CookiesFromBrowser reads the cookies of the sites grabbed from the
+	// cookie store of this browser, to grab as the user logged in there.
+	CookiesFromBrowser CookieBrowser `json:"cookies_from_browser,omitempty" yaml:"cookies_from_browser" toml:"cookies_from_browser"`
+	// Login is filled in before grabbing to get a logged in session.
+	Login LoginOptions `json:"login" yaml:"login" toml:"login"`
+	// Referer sends the page an image was found on as the Referer of its
+	// download, unless Headers already has one.
+	Referer bool `json:"referer" yaml:"referer" toml:"referer"`
+	// Extensions limits downloads to files with one of these extensions
+	// (lower case, without the dot). Empty means everything.
+	Extensions []string `json:"extensions,omitempty" yaml:"extensions" toml:"extensions"`
+	// Types are the only content types grabbed, named as jpeg, png, webp or
+	// image/png. Urls whose extension says they are of another type aren't
+	// downloaded, and downloads that turn out to be one are dropped, as
+	// are the HTML error pages some servers send instead of an image.
+	// Empty means everything.
+	Types []string `json:"types,omitempty" yaml:"types" toml:"types"`
+	// AcceptRegex and RejectRegex filter the urls of the pages crawled, the
+	// photo links and the images: only those matching AcceptRegex, if set,
+	// and not matching RejectRegex are taken. The start page always is.
+	AcceptRegex string `json:"accept_regex,omitempty" yaml:"accept_regex" toml:"accept_regex"`
+	RejectRegex string `json:"reject_regex,omitempty" yaml:"reject_regex" toml:"reject_regex"`
+
+	// Rewrite are s/pattern/replacement/ rules the urls of the images
+	// found are rewritten with, in turn, as many sites map their
+	// thumbnails to the originals in a predictable way. Unlike
+	// FullSizeRules there is no falling back to the url found.
+	Rewrite []string `json:"rewrite,omitempty" yaml:"rewrite" toml:"rewrite"`
+	// FullSize downloads the original of images found as a resized copy
+	// where it can be guessed, as from photo_small.jpg, photo-300x200.jpg,
+	// /thumbs/photo.jpg or photo.jpg?w=300, falling back to the image found
+	// if the guess doesn't download. The biggest srcset candidate is always
+	// taken.
+	FullSize bool `json:"full_size,omitempty" yaml:"full_size" toml:"full_size"`
+	// FullSizeRules are s/pattern/replacement/ rules turning image urls
+	// into the url of their original, tried before the guesses of FullSize
+	// and falling back the same way.
+	FullSizeRules []string `json:"full_size_rules,omitempty" yaml:"full_size_rules" toml:"full_size_rules"`
+	// KeepErrorPages keeps the web pages some hosts answer file urls with,
+	// like a "please log in" page, in the error-pages directory of Dir to
+	// look into. They fail either way.
+	KeepErrorPages bool `json:"keep_error_pages" yaml:"keep_error_pages" toml:"keep_error_pages"`
+	// ImagesOnly rejects downloads that turn out not to be images, or
+	// videos if Media asks for them.
+	ImagesOnly bool `json:"images_only" yaml:"images_only" toml:"images_only"`
+	// Media are the kinds of media grabbed, MediaImage and MediaVideo,
+	// empty is only images. Videos are taken from <video> elements and
+	// links to video files.
+	Media []string `json:"media" yaml:"media" toml:"media"`
+	// Playlists says what happens to the HLS and DASH playlists of
+	// videos.
+	Playlists Playlists `json:"playlists" yaml:"playlists" toml:"playlists"`
+
+	// MinBytes, MinWidth and MinHeight drop images below these sizes.
+	MinBytes  int64 `json:"min_bytes,omitempty" yaml:"min_bytes" toml:"min_bytes"`
+	MinWidth  int   `json:"min_width,omitempty" yaml:"min_width" toml:"min_width"`
+	MinHeight int   `json:"min_height,omitempty" yaml:"min_height" toml:"min_height"`
+	// ProbeBytes, with MinWidth or MinHeight, first fetches only this many
+	// bytes of every image to check its dimensions, and the rest only if
+	// they pass, so the thumbnails dropped aren't downloaded in full.
+	// Servers without range support send the whole image anyway.
+	ProbeBytes int64 `json:"probe_bytes,omitempty" yaml:"probe_bytes" toml:"probe_bytes"`
+	// MaxBytesPerFile skips the downloads bigger than this, by their
+	// Content-Length or, without one, as soon as more than that came in.
+	MaxBytesPerFile int64 `json:"max_bytes_per_file,omitempty" yaml:"max_bytes_per_file" toml:"max_bytes_per_file"`
+
+	// Dedup says what to do with downloads whose content was saved before.
+	Dedup Dedup `json:"dedup" yaml:"dedup" toml:"dedup"`
+
+	// NearDup says what to do with images that look like one saved before
+	// and NearDupDistance how many of the 64 dHash bits may differ.
+	NearDup         NearDup `json:"near_dup" yaml:"near_dup" toml:"near_dup"`
+	NearDupDistance int     `json:"near_dup_distance" yaml:"near_dup_distance" toml:"near_dup_distance"`
+
+	// Convert re-encodes downloaded images, as from:to format pairs like
+	// webp:jpeg. Only the formats listed are touched, and images that
+	// can't be converted are kept as they are. AVIF needs a build with
+	// the avif tag. ConvertQuality is the JPEG quality.
+	Convert        []string `json:"convert,omitempty" yaml:"convert" toml:"convert"`
+	ConvertQuality int      `json:"convert_quality" yaml:"convert_quality" toml:"convert_quality"`
+	// Thumbnails writes a thumbnail of every image, at most this many
+	// pixels wide and high, into the thumbs directory of Dir. 0 writes
+	// none.
+	Thumbnails int `json:"thumbnails,omitempty" yaml:"thumbnails" toml:"thumbnails"`
+	// ServerTimes gives the downloaded files the Last-Modified time their
+	// server sent, rather than the time of the grab. Only files on the
+	// local disk get it, the manifest records it for all.
+	ServerTimes bool `json:"server_times" yaml:"server_times" toml:"server_times"`
+	// Verify checks downloads against the Content-MD5 or MD5 ETag their
+	// server sent and retries those that don't match.
+	Verify bool `json:"verify" yaml:"verify" toml:"verify"`
+
+	// MaxFiles and MaxTotalSize stop the run with a QuotaError once Dir
+	// holds that many files or bytes, counting those the manifest has from
+	// earlier runs. 0 is no limit.
+	MaxFiles     int   `json:"max_files,omitempty" yaml:"max_files" toml:"max_files"`
+	MaxTotalSize int64 `json:"max_total_size,omitempty" yaml:"max_total_size" toml:"max_total_size"`
+
+	// Manifest is the file, relative to Dir, recording what was downloaded
+	// so later runs can skip it. Empty disables the manifest.
+	Manifest string `json:"manifest" yaml:"manifest" toml:"manifest"`
+	// JSONManifest is a file that gets a JSON object appended for every
+	// grabbed image, for other tools to index. Empty writes none.
+	JSONManifest string `json:"json_manifest,omitempty" yaml:"json_manifest" toml:"json_manifest"`
+	// Catalog is a SQLite database recording every grabbed image, see
+	// Catalog. Empty keeps none.
+	Catalog string `json:"catalog,omitempty" yaml:"catalog" toml:"catalog"`
+	// Frontier is the file, relative to Dir, a crawl keeps the pages it
+	// has yet to visit, the photo links it has yet to resolve and the
+	// images it has yet to download in, so a killed run can go on where it
+	// stopped. Empty keeps none.
+	Frontier string `json:"frontier" yaml:"frontier" toml:"frontier"`
+	// Diff only downloads the images of a crawl the Manifest doesn't have
+	// yet, even if their files are gone, and reports them as added along
+	// with the images the Manifest has from the crawled pages that weren't
+	// found again, for watching galleries change. Images only end up in
+	// the latter if the Manifest knows the page they were found on, which
+	// it only does with Referer.
+	Diff bool `json:"diff,omitempty" yaml:"diff" toml:"diff"`
+	// Mirror makes Dir match the crawled galleries: the images the
+	// Manifest has are requested again with the ETag and Last-Modified
+	// time they were saved with, so the server only sends them if they
+	// changed, and replace the old file. Those whose file is gone are
+	// downloaded again in full. MirrorDelete also deletes the files of the
+	// images the Manifest has from the crawled pages that weren't found
+	// again, which like Diff needs Referer. Nothing is deleted after a
+	// crawl cut short by MaxPages, Limit or robots.txt.
+	Mirror       bool `json:"mirror,omitempty" yaml:"mirror" toml:"mirror"`
+	MirrorDelete bool `json:"mirror_delete,omitempty" yaml:"mirror_delete" toml:"mirror_delete"`
+	// Resume goes on with the crawl in the Frontier of an earlier run of
+	// the same start url instead of starting over.
+	Resume bool `json:"-" yaml:"-" toml:"-"`
+
+	// Rate limits the requests per second to every host, 0 is no limit.
+	Rate float64 `json:"rate,omitempty" yaml:"rate" toml:"rate"`
+	// Delay is waited between requests to the same host, plus a random
+	// part of up to RandomDelay.
+	Delay       time.Duration `json:"delay,omitempty" yaml:"delay" toml:"delay"`
+	RandomDelay time.Duration `json:"random_delay,omitempty" yaml:"random_delay" toml:"random_delay"`
+	// HostRates limit the requests per second to the hosts in it, in
+	// place of Rate, e.g. to go easy on a small site while grabbing from
+	// a CDN at full speed.
+	HostRates map[string]float64 `json:"host_rates,omitempty" yaml:"host_rates" toml:"host_rates"`
+	// Priority orders the downloads by these rules, the first before the
+	// next, so the images that matter most are there if the run is cut
+	// short: originals, newest or url=REGEXP for the images matching it.
+	// The downloads of every host still take turns with the other hosts.
+	// Limit keeps the images that come first.
+	Priority []string `json:"priority,omitempty" yaml:"priority" toml:"priority"`
+	// HostConcurrency caps the downloads running at the same time from a
+	// single host, 0 is no limit. The downloads take turns between the
+	// hosts either way, so one with many images doesn't hold back the
+	// others.
+	HostConcurrency int `json:"host_concurrency,omitempty" yaml:"host_concurrency" toml:"host_concurrency"`
+
+	// Robots skips the pages and images the robots.txt of their host
+	// disallows.
+	Robots bool `json:"robots,omitempty" yaml:"robots" toml:"robots"`
+
+	// Retries is how many times a transient failure is retried.
+	Retries int `json:"retries" yaml:"retries" toml:"retries"`
+	// Backoff is the delay before the first retry, doubled on every retry.
+	Backoff time.Duration `json:"backoff" yaml:"backoff" toml:"backoff"`
+	// Jitter spreads retry delays by +/- this fraction.
+	Jitter float64 `json:"jitter" yaml:"jitter" toml:"jitter"`
+
+	// Timeout is the longest a single attempt at downloading an image may
+	// take, and PageTimeout the longest a page may take, whether it is
+	// fetched or opened in chrome. Both are retried like other transient
+	// failures. TotalTimeout stops the run once it has taken that long,
+	// saving what it got like a cancel. 0 is no limit.
+	Timeout      time.Duration `json:"timeout,omitempty" yaml:"timeout" toml:"timeout"`
+	PageTimeout  time.Duration `json:"page_timeout,omitempty" yaml:"page_timeout" toml:"page_timeout"`
+	TotalTimeout time.Duration `json:"total_timeout,omitempty" yaml:"total_timeout" toml:"total_timeout"`
+
+	// Exec is a shell command run for every file saved, with {} replaced by
+	// its path, or the path added at the end if there is no {}. Files in a
+	// storage pass their url instead of a path. The url, page and path are
+	// also in $GRAB_URL, $GRAB_PAGE and $GRAB_FILE. A command that fails
+	// fails the url.
+	Exec string `json:"exec,omitempty" yaml:"exec" toml:"exec"`
+	// ExecJobs is how many commands run at the same time, 0 is the number
+	// of CPUs.
+	ExecJobs int `json:"exec_jobs,omitempty" yaml:"exec_jobs" toml:"exec_jobs"`
+
+	// Webhook is a url a summary of the run is posted to when the grabber
+	// is closed, see WebhookPayload. Empty posts nothing.
+	Webhook string `json:"webhook,omitempty" yaml:"webhook" toml:"webhook"`
+	// WebhookFormat is guessed from the url of the webhook if empty.
+	WebhookFormat WebhookFormat `json:"webhook_format,omitempty" yaml:"webhook_format" toml:"webhook_format"`
+	// WebhookFailureRate also posts to the webhook, once, as soon as more
+	// than this fraction of the urls tried failed. 0 never does.
+	WebhookFailureRate float64 `json:"webhook_failure_rate,omitempty" yaml:"webhook_failure_rate" toml:"webhook_failure_rate"`
+}
+
+// DefaultOptions returns the options used by the grab command.
+func DefaultOptions() Options {
+	return Options{
+		Dir:                ".",
+		Concurrency:        4,
+		LinkSelector:       `a[href*="/photo/"]`,
+		ImageSelector:      "img, picture source[srcset], picture source[data-srcset]",
+		LazyAttributes:     defaultLazyAttributes,
+		MetaImages:         true,
+		EmbeddedImages:     true,
+		MaxScrolls:         50,
+		MaxLoadMore:        50,
+		Tabs:               1,
+		ScreenshotSelector: "canvas, img",
+		Browser:            BrowserOptions{Headless: true},
+		Pagination:         true,
+		NormalizeURLs:      true,
+		TrackingParams:     defaultTrackingParams,
+		Media:              []string{MediaImage},
+		Playlists:          PlaylistsExport,
+		ProxyMaxFails:      3,
+		Referer:            true,
+		Dedup:              DedupSkip,
+		NearDup:            NearDupOff,
+		NearDupDistance:    5,
+		ConvertQuality:     90,
+		ServerTimes:        true,
+		Verify:             true,
+		KeepErrorPages:     true,
+		Manifest:           ".grab-manifest.json",
+		Frontier:           ".grab-frontier.db",
+		Retries:            3,
+		Backoff:            time.Second,
+		Jitter:             0.2,
+		PageTimeout:        time.Minute,
+	}
+}
+
+// Validate checks the options for values that can't work.
+func (o Options) Validate() error {
+	if o.Concurrency < 1 {
+		return errors.New("concurrency must be at least 1")
+	}
+	if o.Tabs < 0 {
+		return errors.New("tabs must not be negative")
+	}
+	if _, err := layoutParts(o.Layout); err != nil {
+		return err
+	}
+	if _, err := parsePriority(o.Priority); err != nil {
+		return err
+	}
+	if o.HostConcurrency < 0 {
+		return errors.New("host concurrency must not be negative")
+	}
+	for host, rate := range o.HostRates {
+		if rate < 0 {
+			return fmt.Errorf("rate of %s must not be negative", host)
+		}
+	}
+	if o.MaxConnsPerHost < 0 {
+		return errors.New("max connections per host must not be negative")
+	}
+	if o.Depth < 0 {
+		return errors.New("depth must not be negative")
+	}
+	if o.Limit < 0 {
+		return errors.New("limit must not be negative")
+	}
+	if o.MaxPages < 0 {
+		return errors.New("max pages must not be negative")
+	}
+	if o.MaxScrolls < 0 || o.ScrollItems < 0 || o.MaxLoadMore < 0 {
+		return errors.New("scroll and load more limits must not be negative")
+	}
+	if o.Rate < 0 || o.Delay < 0 || o.RandomDelay < 0 {
+		return errors.New("rate and delays must not be negative")
+	}
+	if o.Retries < 0 {
+		return errors.New("retries must not be negative")
+	}
+	if o.Jitter < 0 || o.Jitter > 1 {
+		return errors.New("jitter must be between 0 and 1")
+	}
+	if o.Timeout < 0 || o.PageTimeout < 0 || o.TotalTimeout < 0 {
+		return errors.New("timeouts must not be negative")
+	}
+	if o.Dedup.enabled() {
+		if err := new(Dedup).Set(string(o.Dedup)); err != nil {
+			return err
+		}
+	}
+	if o.NearDup.enabled() {
+		if err := new(NearDup).Set(string(o.NearDup)); err != nil {
+			return err
+		}
+	}
+	if o.NearDupDistance < 0 || o.NearDupDistance > 64 {
+		return errors.New("near duplicate distance must be between 0 and 64")
+	}
+	if _, err := parseRewriteRules(o.Rewrite); err != nil {
+		return err
+	}
+	if _, err := parseRewriteRules(o.FullSizeRules); err != nil {
+		return err
+	}
+	if _, err := o.contentTypes(); err != nil {
+		return err
+	}
+	if _, err := o.urlFilter(); err != nil {
+		return err
+	}
+	if _, err := o.conversions(); err != nil {
+		return err
+	}
+	if len(o.Convert) > 0 && (o.ConvertQuality < 1 || o.ConvertQuality > 100) {
+		return errors.New("convert quality must be between 1 and 100")
+	}
+	if o.MaxFiles < 0 || o.MaxTotalSize < 0 {
+		return errors.New("quotas must not be negative")
+	}
+	if o.ExecJobs < 0 {
+		return errors.New("exec jobs must not be negative")
+	}
+	if o.Thumbnails < 0 {
+		return errors.New("thumbnail size must not be negative")
+	}
+	if o.MinBytes < 0 || o.MinWidth < 0 || o.MinHeight < 0 {
+		return errors.New("minimum sizes must not be negative")
+	}
+	if o.ProbeBytes < 0 {
+		return errors.New("probe bytes must not be negative")
+	}
+	if o.MaxBytesPerFile < 0 {
+		return errors.New("maximum bytes per file must not be negative")
+	}
+	if o.MaxBytesPerFile > 0 && o.MaxBytesPerFile < o.MinBytes {
+		return errors.New("maximum bytes per file must not be below the minimum bytes")
+	}
+	if err := o.Browser.validate(); err != nil {
+		return err
+	}
+	if o.ProxyMaxFails < 0 || o.ProxyRecheck < 0 {
+		return errors.New("proxy failures and recheck must not be negative")
+	}
+	for _, p := range o.Proxies {
+		if _, err := parseProxy(p); err != nil {
+			return err
+		}
+	}
+	if err := o.validateMedia(); err != nil {
+		return err
+	}
+	if o.Playlists != "" {
+		if err := new(Playlists).Set(string(o.Playlists)); err != nil {
+			return err
+		}
+	}
+	if o.Auth != "" && o.Bearer != "" {
+		return errors.New("auth and bearer can't be used together")
+	}
+	if o.Auth != "" && !strings.Contains(o.Auth, ":") {
+		return errors.New("auth must be USER:PASSWORD")
+	}
+	if err := o.validateIP(); err != nil {
+		return err
+	}
+	if err := new(CookieBrowser).Set(string(o.CookiesFromBrowser)); err != nil {
+		return err
+	}
+	if err := o.TLS.validate(); err != nil {
+		return err
+	}
+	if err := o.Login.validate(); err != nil {
+		return err
+	}
+	if err := o.validateWebhook(); err != nil {
+		return err
+	}
+	if err := o.validateDomains(); err != nil {
+		return err
+	}
+	if o.Diff && o.Manifest == "" {
+		return errors.New("diff needs a manifest to compare with")
+	}
+	if o.Mirror && o.Manifest == "" {
+		return errors.New("mirror needs a manifest to compare with")
+	}
+	if o.Mirror && o.Diff {
+		return errors.New("mirror already downloads what is new, it can't be a diff")
+	}
+	if o.Dir == "" {
+		return errors.New("output directory must not be empty")
+	}
+	return nil
+}
+
+// headers returns Headers with UserAgent and the Authorization of Auth or
+// Bearer added, if they are set.
+func (o Options) headers() map[string]string {
+	auth := o.authorization()
+	if o.UserAgent == "" && auth == "" {
+		return o.Headers
+	}
+
+	h := make(map[string]string, len(o.Headers)+2)
+	if auth != "" {
+		h["Authorization"] = auth
+	}
+	for k, v := range o.Headers {
+		if o.UserAgent != "" && strings.EqualFold(k, "User-Agent") {
+			continue
+		}
+		if strings.EqualFold(k, "Authorization") {
+			delete(h, "Authorization")
+		}
+		h[k] = v
+	}
+	if o.UserAgent != "" {
+		h["User-Agent"] = o.UserAgent
+	}
+	return h
+}
+
+// authorization returns the Authorization header of Auth or Bearer, empty
+// if neither is set.
+func (o Options) authorization() string {
+	switch {
+	case o.Auth != "":
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(o.Auth))
+	case o.Bearer != "":
+		return "Bearer " + o.Bearer
+	}
+	return ""
+}
+
+// accepts reports whether the url passes the media and extension filters.
+// An empty extension filter accepts everything.
+func (o Options) accepts(u string) bool {
+	if kind := mediaKind(u); kind != "" && !o.wants(kind) {
+		return false
+	}
+	if !o.acceptsType(u) {
+		return false
+	}
+	if len(o.Extensions) == 0 {
+		return true
+	}
+
+	ext := strings.TrimPrefix(strings.ToLower(filepath.Ext(getFileName(u, o.TrackingParams))), ".")
+	for _, e := range o.Extensions {
+		if ext == e {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,69 @@
+package grabber
+
+import (
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// nextLinkSelector selects the links a page marks as leading to its next page.
+const nextLinkSelector = `a[rel~="next"][href], link[rel~="next"][href]`
+
+// pageParams are the query parameters galleries commonly number their pages
+// with, as in ?page=2.
+var pageParams = []string{"page", "p", "pg", "paged", "pagenum"}
+
+// pagePath matches paths numbering their pages as in /gallery/page/2.
+var pagePath = regexp.MustCompile(`^(.*?)/page/(\d+)/?$`)
+
+// isNextPage reports whether link is the page after cur, going by the page
+// number in its query or path with everything else the same. Pages without
+// a number count as the first.
+func isNextPage(cur, link *url.URL) bool {
+	if cur.Host != link.Host {
+		return false
+	}
+
+	if m := pagePath.FindStringSubmatch(link.Path); m != nil {
+		if link.RawQuery != cur.RawQuery {
+			return false
+		}
+		base, n := strings.TrimSuffix(cur.Path, "/"), 1
+		if c := pagePath.FindStringSubmatch(cur.Path); c != nil {
+			base, n = c[1], atoi(c[2])
+		}
+		return m[1] == base && atoi(m[2]) == n+1
+	}
+
+	if link.Path != cur.Path {
+		return false
+	}
+	lq, cq := link.Query(), cur.Query()
+	for _, name := range pageParams {
+		next, err := strconv.Atoi(lq.Get(name))
+		if err != nil {
+			continue
+		}
+		n := 1
+		if v := cq.Get(name); v != "" {
+			if n, err = strconv.Atoi(v); err != nil {
+				continue
+			}
+		}
+		if next != n+1 {
+			continue
+		}
+		lq.Del(name)
+		cq.Del(name)
+		return lq.Encode() == cq.Encode()
+	}
+
+	return false
+}
+
+// atoi is strconv.Atoi for strings known to be numbers.
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
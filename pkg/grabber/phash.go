@@ -0,0 +1,161 @@
+package grabber
+
+import (
+	"fmt"
+	"image"
+	"math/bits"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// NearDup says what happens to a download that looks the same as an image
+// saved before, e.g. the same photo served at another size or compression.
+type NearDup string
+
+const (
+	// NearDupOff doesn't compare images.
+	NearDupOff NearDup = "off"
+	// NearDupFlag keeps the download but reports what it looks like.
+	NearDupFlag NearDup = "flag"
+	// NearDupSkip drops the download.
+	NearDupSkip NearDup = "skip"
+)
+
+// Set implements flag.Value.
+func (n *NearDup) Set(v string) error {
+	switch NearDup(v) {
+	case NearDupOff, NearDupFlag, NearDupSkip:
+		*n = NearDup(v)
+		return nil
+	}
+	return fmt.Errorf("unknown near duplicate mode %q, want off, flag or skip", v)
+}
+
+func (n NearDup) String() string {
+	return string(n)
+}
+
+// enabled reports whether images are compared at all. The zero value is
+// the same as NearDupOff.
+func (n NearDup) enabled() bool {
+	return n != NearDupOff && n != ""
+}
+
+// dHashSamples is how many pixels per axis are averaged for each cell of
+// the hash grid, so big images don't have to be visited pixel by pixel.
+const dHashSamples = 8
+
+// dHash computes the 64 bit difference hash of an image: it is shrunk to a
+// 9x8 grayscale grid and every bit tells whether a cell is brighter than its
+// right neighbour. Resizing and recompressing barely change it, so similar
+// images have hashes that differ in only a few bits.
+func dHash(img image.Image) uint64 {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w == 0 || h == 0 {
+		return 0
+	}
+
+	var grid [8][9]float64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 9; x++ {
+			var sum float64
+			for sy := 0; sy < dHashSamples; sy++ {
+				for sx := 0; sx < dHashSamples; sx++ {
+					px := b.Min.X + (x*dHashSamples+sx)*w/(9*dHashSamples)
+					py := b.Min.Y + (y*dHashSamples+sy)*h/(8*dHashSamples)
+					r, g, bl, _ := img.At(px, py).RGBA()
+					sum += 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(bl)
+				}
+			}
+			grid[y][x] = sum
+		}
+	}
+
+	var hash uint64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			hash <<= 1
+			if grid[y][x] > grid[y][x+1] {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// fileDHash decodes the image at path and returns its dHash as hex.
+func fileDHash(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%016x", dHash(img)), nil
+}
+
+// PerceptualIndex remembers the dHash of every image saved so far.
+type PerceptualIndex struct {
+	mu      sync.Mutex
+	entries []perceptualEntry
+}
+
+type perceptualEntry struct {
+	hash uint64
+	path string
+}
+
+// NewPerceptualIndex creates an empty index.
+func NewPerceptualIndex() *PerceptualIndex {
+	return &PerceptualIndex{}
+}
+
+// match returns the closest image within maxDistance differing bits of
+// hash, or "" if there is none. Otherwise hash is added under path, in the
+// same step so concurrent downloads of the same photo can't both get in.
+func (x *PerceptualIndex) match(hash, path string, maxDistance int) (string, error) {
+	h, err := strconv.ParseUint(hash, 16, 64)
+	if err != nil {
+		return "", err
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	best, bestDistance := "", maxDistance+1
+	for _, e := range x.entries {
+		if e.path == path {
+			continue
+		}
+		if d := bits.OnesCount64(e.hash ^ h); d < bestDistance {
+			best, bestDistance = e.path, d
+		}
+	}
+	if best != "" {
+		if _, err := os.Stat(best); err == nil {
+			return best, nil
+		}
+	}
+
+	x.entries = append(x.entries, perceptualEntry{hash: h, path: path})
+	return "", nil
+}
+
+func (x *PerceptualIndex) add(hash, path string) {
+	h, err := strconv.ParseUint(hash, 16, 64)
+	if err != nil {
+		return
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	x.entries = append(x.entries, perceptualEntry{hash: h, path: path})
+}
@@ -0,0 +1,266 @@
+package grabber
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Playlists says what happens to HLS (.m3u8) and DASH (.mpd) playlists,
+// which stream a video in segments rather than as a single file.
+type Playlists string
+
+const (
+	// PlaylistsExport downloads nothing and writes the playlist url to the
+	// JSON manifest, for tools like ffmpeg or yt-dlp.
+	PlaylistsExport Playlists = "export"
+	// PlaylistsStitch downloads the segments of HLS playlists into a
+	// single file. DASH playlists are still exported.
+	PlaylistsStitch Playlists = "stitch"
+)
+
+// Set implements flag.Value.
+func (p *Playlists) Set(v string) error {
+	switch Playlists(v) {
+	case PlaylistsExport, PlaylistsStitch:
+		*p = Playlists(v)
+		return nil
+	}
+	return fmt.Errorf("unknown playlist mode %q, want export or stitch", v)
+}
+
+func (p Playlists) String() string {
+	return string(p)
+}
+
+// The kinds of playlists.
+const (
+	playlistHLS  = "hls"
+	playlistDASH = "dash"
+)
+
+// playlistKind tells from its extension whether u is an HLS or DASH
+// playlist, "" if it is neither.
+func playlistKind(u string) string {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return ""
+	}
+	switch strings.ToLower(path.Ext(parsed.Path)) {
+	case ".m3u8":
+		return playlistHLS
+	case ".mpd":
+		return playlistDASH
+	}
+	return ""
+}
+
+// exportPlaylist records the playlist url in the JSON manifest instead of
+// downloading it.
+func (g *Grabber) exportPlaylist(url, referer string) {
+	if g.records != nil {
+		r := Record{Page: referer, URL: url, Playlist: playlistKind(url), Time: time.Now().UTC()}
+		if err := g.records.write(r); err != nil {
+			g.fail(Failure{URL: url, Err: fmt.Errorf("json manifest: %v", err), Attempts: 1})
+			return
+		}
+	}
+	g.skip(url, "playlist exported")
+}
+
+var (
+	hlsBandwidth = regexp.MustCompile(`(?:^|,)BANDWIDTH=(\d+)`)
+	hlsMethod    = regexp.MustCompile(`(?:^|,)METHOD=([^,]+)`)
+	hlsURI       = regexp.MustCompile(`(?:^|,)URI="([^"]*)"`)
+)
+
+// hlsPlaylist is a parsed m3u8 file: either a master playlist listing the
+// variants of a stream or a media playlist listing its segments.
+type hlsPlaylist struct {
+	// variants are the urls of the streams of a master playlist and
+	// bandwidths their bits per second.
+	variants   []string
+	bandwidths []int
+	// init is the segment with the headers of fragmented MP4 streams.
+	init     string
+	segments []string
+	// encrypted is set if the segments are encrypted, which isn't
+	// supported.
+	encrypted bool
+}
+
+// parseM3U8 parses an m3u8 playlist, resolving the urls in it against base.
+func parseM3U8(base *url.URL, data []byte) (*hlsPlaylist, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	if !scanner.Scan() || !strings.HasPrefix(strings.TrimSpace(scanner.Text()), "#EXTM3U") {
+		return nil, errors.New("not an m3u8 playlist")
+	}
+
+	resolve := func(ref string) string {
+		u, err := base.Parse(ref)
+		if err != nil {
+			return ""
+		}
+		return u.String()
+	}
+
+	p := &hlsPlaylist{}
+	bandwidth := -1
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "#EXT-X-STREAM-INF:"):
+			bandwidth = 0
+			if m := hlsBandwidth.FindStringSubmatch(line[len("#EXT-X-STREAM-INF:"):]); m != nil {
+				bandwidth, _ = strconv.Atoi(m[1])
+			}
+		case strings.HasPrefix(line, "#EXT-X-KEY:"):
+			if m := hlsMethod.FindStringSubmatch(line[len("#EXT-X-KEY:"):]); m != nil && m[1] != "NONE" {
+				p.encrypted = true
+			}
+		case strings.HasPrefix(line, "#EXT-X-MAP:"):
+			if m := hlsURI.FindStringSubmatch(line[len("#EXT-X-MAP:"):]); m != nil {
+				p.init = resolve(m[1])
+			}
+		case strings.HasPrefix(line, "#"):
+		case bandwidth >= 0:
+			p.variants = append(p.variants, resolve(line))
+			p.bandwidths = append(p.bandwidths, bandwidth)
+			bandwidth = -1
+		default:
+			p.segments = append(p.segments, resolve(line))
+		}
+	}
+	return p, scanner.Err()
+}
+
+// best returns the variant with the highest bandwidth.
+func (p *hlsPlaylist) best() string {
+	best := 0
+	for i, b := range p.bandwidths {
+		if b > p.bandwidths[best] {
+			best = i
+		}
+	}
+	return p.variants[best]
+}
+
+// hlsSegments returns the segments of the HLS playlist at playlistURL, those
+// of its best variant for a master playlist, with the init segment first.
+func (d *Downloader) hlsSegments(ctx context.Context, playlistURL, referer string) ([]string, error) {
+	for i := 0; i < 3; i++ {
+		resp, err := d.get(ctx, playlistURL, referer)
+		if err != nil {
+			return nil, err
+		}
+		data, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		p, err := parseM3U8(resp.Request.URL, data)
+		if err != nil {
+			return nil, err
+		}
+		if len(p.variants) > 0 {
+			playlistURL = p.best()
+			continue
+		}
+		if p.encrypted {
+			return nil, &SkipError{Reason: "encrypted playlist"}
+		}
+		if len(p.segments) == 0 {
+			return nil, errors.New("empty playlist")
+		}
+		if p.init != "" {
+			return append([]string{p.init}, p.segments...), nil
+		}
+		return p.segments, nil
+	}
+	return nil, errors.New("too many nested playlists")
+}
+
+// stitch downloads the segments of the HLS playlist at playlistURL one after
+// another into a single file, named like the playlist. Unlike downloads,
+// stitching starts over after an interruption.
+func (d *Downloader) stitch(ctx context.Context, playlistURL, referer string) (*File, error) {
+	segments, err := d.hlsSegments(ctx, playlistURL, referer)
+	if err != nil {
+		return nil, err
+	}
+
+	name := d.fileName(playlistURL)
+	name = strings.TrimSuffix(name, path.Ext(name)) + ".ts"
+	if d.Storage != nil {
+		pr, pw := io.Pipe()
+		go func() {
+			pw.CloseWithError(d.copySegments(ctx, pw, segments, referer))
+		}()
+		f, err := d.store(playlistURL, name, pr, 0)
+		pr.CloseWithError(err)
+		return f, err
+	}
+	fileName := filepath.Join(d.Dir, name)
+	tmpName := fileName + "." + shortHash([]byte(playlistURL)) + ".tmp"
+
+	out, err := os.Create(tmpName)
+	if err != nil {
+		return nil, err
+	}
+	defer out.Close()
+
+	h := sha256.New()
+	p := Progress{URL: playlistURL, File: fileName}
+	counter := &WriteCounter{Progress: func(total uint64) {
+		p.Written = total
+		d.progress(p)
+	}}
+	if err := d.copySegments(ctx, io.MultiWriter(out, h, counter), segments, referer); err != nil {
+		out.Close()
+		os.Remove(tmpName)
+		return nil, err
+	}
+
+	return d.finish(out, tmpName, p, hex.EncodeToString(h.Sum(nil)))
+}
+
+// copySegments downloads segments one after another into w.
+func (d *Downloader) copySegments(ctx context.Context, w io.Writer, segments []string, referer string) error {
+	for _, seg := range segments {
+		resp, err := d.get(ctx, seg, referer)
+		if err != nil {
+			return fmt.Errorf("segment %s: %w", seg, err)
+		}
+		_, err = io.Copy(w, resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("segment %s: %w", seg, err)
+		}
+	}
+	return nil
+}
+
+// get requests url, failing on anything but a 2xx response.
+func (d *Downloader) get(ctx context.Context, url, referer string) (*http.Response, error) {
+	req, err := d.newRequest(ctx, http.MethodGet, url, referer)
+	if err != nil {
+		return nil, err
+	}
+	return d.do(ctx, req)
+}
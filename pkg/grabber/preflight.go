@@ -0,0 +1,69 @@
+package grabber
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/dustin/go-humanize"
+)
+
+// Preflight is the expected size of a batch of downloads.
+type Preflight struct {
+	Files int
+	// Bytes is the sum of the sizes the servers reported.
+	Bytes int64
+	// Unknown is how many of the files have no size reported.
+	Unknown int
+	// Free is the space left in the output directory, -1 if unknown.
+	Free int64
+}
+
+// preflight asks for the size of every url, running up to
+// Options.Concurrency requests at the same time, and fails if they add up
+// to more than the free space of Options.Dir. Urls whose size can't be
+// found out are left for the download to report. It fails with ErrCanceled
+// once ctx is done.
+func (g *Grabber) preflight(ctx context.Context, urls []string, referers map[string]string) error {
+	p := Preflight{Files: len(urls), Free: -1}
+	if free, err := freeSpace(g.Options.Dir); err == nil {
+		p.Free = int64(free)
+	}
+
+	var mu sync.Mutex
+	jobs := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < g.Options.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for u := range jobs {
+				e, err := g.Downloader.Estimate(ctx, u, referers[u])
+				mu.Lock()
+				if err == nil && e.Size >= 0 {
+					p.Bytes += e.Size
+				} else {
+					p.Unknown++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, u := range urls {
+		jobs <- u
+	}
+	close(jobs)
+	wg.Wait()
+	if err := g.canceledErr(ctx); err != nil {
+		return err
+	}
+
+	if g.OnPreflight != nil {
+		g.OnPreflight(p)
+	}
+	if p.Free >= 0 && p.Bytes > p.Free {
+		return fmt.Errorf("downloads need %s but only %s are free in %s",
+			humanize.Bytes(uint64(p.Bytes)), humanize.Bytes(uint64(p.Free)), g.Options.Dir)
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package grabber
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The priority rules of Options.Priority, besides url=REGEXP.
+const (
+	// PriorityOriginals downloads the images whose url doesn't look like
+	// a resized copy, as photo_small.jpg or /thumbs/photo.jpg, first.
+	PriorityOriginals = "originals"
+	// PriorityNewest downloads the images of the pages with the latest
+	// date in their url, as /2024/05/ or 2024-05-17, first. Images of
+	// pages without one come after those of pages with one.
+	PriorityNewest = "newest"
+)
+
+// pageDate matches the date in the url of a page, with or without the day.
+var pageDate = regexp.MustCompile(`(?:^|[^0-9])((?:19|20)[0-9]{2})[/_-]?(0[1-9]|1[0-2])(?:[/_-]?(0[1-9]|[12][0-9]|3[01]))?(?:[^0-9]|$)`)
+
+// priorityKey returns the key of an image under a rule, images with the
+// lower key go first.
+type priorityKey func(url, page string) string
+
+// parsePriority parses the rules of Options.Priority.
+func parsePriority(rules []string) ([]priorityKey, error) {
+	var keys []priorityKey
+	for _, rule := range rules {
+		switch {
+		case rule == PriorityOriginals:
+			keys = append(keys, func(url, _ string) string {
+				return rank(len(fullSizeVariants(url, nil, true)) > 0)
+			})
+		case rule == PriorityNewest:
+			keys = append(keys, func(_, page string) string {
+				m := pageDate.FindStringSubmatch(page)
+				if m == nil {
+					return "1"
+				}
+				date, _ := strconv.Atoi(m[1] + m[2] + m[3] + strings.Repeat("0", 2-len(m[3])))
+				return fmt.Sprintf("0%08d", 99999999-date)
+			})
+		case strings.HasPrefix(rule, "url="):
+			re, err := regexp.Compile(strings.TrimPrefix(rule, "url="))
+			if err != nil {
+				return nil, fmt.Errorf("bad priority rule %q: %v", rule, err)
+			}
+			keys = append(keys, func(url, _ string) string {
+				return rank(!re.MatchString(url))
+			})
+		default:
+			return nil, fmt.Errorf("unknown priority rule %q, want originals, newest or url=REGEXP", rule)
+		}
+	}
+	return keys, nil
+}
+
+// rank is the key of images that go last if later is set.
+func rank(later bool) string {
+	if later {
+		return "1"
+	}
+	return "0"
+}
+
+// prioritize returns urls ordered by Options.Priority, the first rule
+// before the next, keeping the order they were found in otherwise. pages
+// has the page each url was found on.
+func (g *Grabber) prioritize(urls []string, pages map[string]string) []string {
+	rules, _ := parsePriority(g.Options.Priority)
+	if len(rules) == 0 {
+		return urls
+	}
+
+	type item struct {
+		url  string
+		keys []string
+	}
+	items := make([]item, len(urls))
+	for i, u := range urls {
+		items[i] = item{url: u, keys: make([]string, len(rules))}
+		for j, key := range rules {
+			items[i].keys[j] = key(u, pages[u])
+		}
+	}
+	sort.SliceStable(items, func(a, b int) bool {
+		for j := range rules {
+			if items[a].keys[j] != items[b].keys[j] {
+				return items[a].keys[j] < items[b].keys[j]
+			}
+		}
+		return false
+	})
+
+	sorted := make([]string, len(items))
+	for i, it := range items {
+		sorted[i] = it.url
+	}
+	return sorted
+}
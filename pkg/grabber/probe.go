@@ -0,0 +1,71 @@
+package grabber
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+)
+
+// probes reports whether the download of an image starts with a probe of
+// its header, see Downloader.ProbeBytes.
+func (d *Downloader) probes() bool {
+	return d.ProbeBytes > 0 && (d.Filter.MinWidth > 0 || d.Filter.MinHeight > 0)
+}
+
+// probe requests the first ProbeBytes of url into out, the empty .tmp
+// file of its download, and checks the dimensions in their header
+// against the filter, as well as the size the server tells. It returns
+// how many bytes it wrote, which the download then resumes from with a
+// Range request. Images the filter drops fail with a SkipError before
+// the rest of them is fetched. Servers answering with the whole file,
+// as they do without range support, are left to the download, with
+// nothing written.
+func (d *Downloader) probe(ctx context.Context, out *os.File, url, referer string, since validators) (int64, int, error) {
+	req, err := d.newRequest(ctx, http.MethodGet, url, referer)
+	if err != nil {
+		return 0, 0, err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=0-%d", d.ProbeBytes-1))
+	since.set(req)
+
+	if err := d.Limiter.Wait(ctx, req.URL.Host); err != nil {
+		return 0, 0, err
+	}
+	orDiscard(d.Logger).Debug("probing", "url", url, "bytes", d.ProbeBytes)
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified:
+		return 0, resp.StatusCode, notModified()
+	case resp.StatusCode == http.StatusPartialContent:
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return 0, resp.StatusCode, nil
+	default:
+		return 0, resp.StatusCode, newStatusError(resp)
+	}
+
+	if total := contentRangeTotal(resp); total > 0 {
+		if err := d.Filter.checkBytes(total); err != nil {
+			return 0, resp.StatusCode, err
+		}
+	}
+	if err := saveIfRange(out.Name(), resp); err != nil {
+		return 0, resp.StatusCode, err
+	}
+	var head bytes.Buffer
+	n, err := io.Copy(io.MultiWriter(out, &head), io.LimitReader(resp.Body, d.ProbeBytes))
+	if err != nil {
+		return n, resp.StatusCode, err
+	}
+	if _, _, err := d.Filter.checkHeader(&head); err != nil {
+		return n, resp.StatusCode, err
+	}
+	return n, resp.StatusCode, nil
+}
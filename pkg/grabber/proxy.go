@@ -0,0 +1,181 @@
+package grabber
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// errNoProxy is returned when every proxy of the pool is dead.
+var errNoProxy = errors.New("all proxies are dead")
+
+// ProxyPool hands out its proxies round robin, one per request, and takes
+// the ones that keep failing out of the rotation.
+type ProxyPool struct {
+	// MaxFails is how many requests in a row may fail before a proxy is
+	// marked dead. 0 never marks proxies dead.
+	MaxFails int
+	// Recheck is how long a dead proxy rests before it is given another
+	// request, 0 keeps it dead for the rest of the run.
+	Recheck time.Duration
+	// OnDead is called when a proxy is marked dead.
+	OnDead func(proxy string, err error)
+
+	mu      sync.Mutex
+	proxies []*proxy
+	next    int
+	// direct is the transport of the requests without a proxy, nil for
+	// http.DefaultTransport.
+	direct *http.Transport
+}
+
+// proxy is a single proxy of the pool, with a transport of its own so
+// connections to it are reused.
+type proxy struct {
+	url       *url.URL
+	transport *http.Transport
+	fails     int
+	dead      time.Time
+}
+
+// NewProxyPool parses the proxy urls, which may be http, https or socks5.
+func NewProxyPool(urls []string) (*ProxyPool, error) {
+	p := &ProxyPool{}
+	for _, s := range urls {
+		u, err := parseProxy(s)
+		if err != nil {
+			return nil, err
+		}
+		t := http.DefaultTransport.(*http.Transport).Clone()
+		t.Proxy = http.ProxyURL(u)
+		p.proxies = append(p.proxies, &proxy{url: u, transport: t})
+	}
+	return p, nil
+}
+
+func parseProxy(s string) (*url.URL, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, fmt.Errorf("bad proxy %q: %v", s, err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("bad proxy %q, want an http, https or socks5 url", s)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("bad proxy %q, missing host", s)
+	}
+	return u, nil
+}
+
+// readProxyFile reads a proxy list with one url per line. Empty lines and
+// lines starting with # are ignored.
+func readProxyFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var urls []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			urls = append(urls, line)
+		}
+	}
+	return urls, scanner.Err()
+}
+
+// RoundTrip sends req through the next live proxy.
+func (p *ProxyPool) RoundTrip(req *http.Request) (*http.Response, error) {
+	px, err := p.pick()
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := px.transport.RoundTrip(req)
+	if err == nil && resp.StatusCode == http.StatusProxyAuthRequired {
+		p.report(px, errors.New(resp.Status))
+	} else {
+		p.report(px, err)
+	}
+	return resp, err
+}
+
+// pick returns the next proxy that is alive, or dead but rested long
+// enough to be tried again.
+func (p *ProxyPool) pick() (*proxy, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for i := 0; i < len(p.proxies); i++ {
+		px := p.proxies[p.next]
+		p.next = (p.next + 1) % len(p.proxies)
+
+		if px.dead.IsZero() {
+			return px, nil
+		}
+		if p.Recheck > 0 && time.Since(px.dead) >= p.Recheck {
+			// On probation: one request to tell whether it came back,
+			// the others keep avoiding it until then
+			px.dead = time.Now()
+			return px, nil
+		}
+	}
+	return nil, errNoProxy
+}
+
+// report records the outcome of a request through px.
+func (p *ProxyPool) report(px *proxy, err error) {
+	p.mu.Lock()
+	if err == nil {
+		px.fails, px.dead = 0, time.Time{}
+		p.mu.Unlock()
+		return
+	}
+
+	px.fails++
+	died := p.MaxFails > 0 && px.fails == p.MaxFails
+	if p.MaxFails > 0 && px.fails >= p.MaxFails {
+		px.dead = time.Now()
+	}
+	p.mu.Unlock()
+
+	if died && p.OnDead != nil {
+		p.OnDead(px.url.Redacted(), err)
+	}
+}
+
+// setTransport makes the pool send its requests with copies of t, one
+// per proxy, or with t itself if it has no proxies.
+func (p *ProxyPool) setTransport(t *http.Transport) {
+	p.direct = t
+	for _, px := range p.proxies {
+		px.transport = t.Clone()
+		px.transport.Proxy = http.ProxyURL(px.url)
+	}
+}
+
+// transport returns the pool as the transport of a client, or the direct
+// transport if the pool is empty.
+func (p *ProxyPool) transport() http.RoundTripper {
+	if p == nil {
+		return http.DefaultTransport
+	}
+	if len(p.proxies) == 0 {
+		if p.direct != nil {
+			return p.direct
+		}
+		return http.DefaultTransport
+	}
+	return p
+}
@@ -0,0 +1,103 @@
+package grabber
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/dustin/go-humanize"
+)
+
+// QuotaReason is the reason urls are skipped once the run is stopped by a
+// QuotaError.
+const QuotaReason = "quota reached"
+
+// QuotaError is returned by Crawl and Download when Options.MaxFiles or
+// Options.MaxTotalSize is reached or the disk is full. The downloads under
+// way are finished and the rest is left for a resumed run.
+type QuotaError struct {
+	Reason string
+}
+
+func (e *QuotaError) Error() string {
+	return "stopped: " + e.Reason
+}
+
+// quota counts the files in the output directory against Options.MaxFiles
+// and Options.MaxTotalSize, starting with those of the manifest.
+type quota struct {
+	mu       sync.Mutex
+	maxFiles int
+	maxBytes int64
+	files    int
+	bytes    int64
+	seeded   bool
+	stopped  *QuotaError
+}
+
+func newQuota(opts Options) *quota {
+	return &quota{maxFiles: opts.MaxFiles, maxBytes: opts.MaxTotalSize}
+}
+
+// seed counts the files the manifest m has from earlier runs, once.
+func (q *quota) seed(m *Manifest) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.seeded || m == nil {
+		return
+	}
+	q.seeded = true
+	files, bytes := m.usage()
+	q.files += files
+	q.bytes += bytes
+}
+
+// add counts a new file of size bytes.
+func (q *quota) add(size int64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.files++
+	q.bytes += size
+}
+
+// stop stops the run for err, unless it is stopped already.
+func (q *quota) stop(err *QuotaError) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.stopped == nil {
+		q.stopped = err
+	}
+}
+
+// err returns the QuotaError stopping the run, nil while there is room
+// for more files.
+func (q *quota) err() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.stopped == nil {
+		switch {
+		case q.maxFiles > 0 && q.files >= q.maxFiles:
+			q.stopped = &QuotaError{Reason: fmt.Sprintf("%d files reached", q.maxFiles)}
+		case q.maxBytes > 0 && q.bytes >= q.maxBytes:
+			q.stopped = &QuotaError{Reason: humanize.Bytes(uint64(q.maxBytes)) + " reached"}
+		}
+	}
+	if q.stopped == nil {
+		return nil
+	}
+	return q.stopped
+}
+
+// checkSpace fails with a QuotaError if size more bytes don't fit on the
+// disk of d.Dir. Disks whose free space can't be found out pass.
+func (d *Downloader) checkSpace(size int64) error {
+	free, err := freeSpace(d.Dir)
+	if err != nil || uint64(size) <= free {
+		return nil
+	}
+	return &QuotaError{Reason: fmt.Sprintf("disk full, %s more are needed but only %s are free in %s",
+		humanize.Bytes(uint64(size)), humanize.Bytes(free), d.Dir)}
+}
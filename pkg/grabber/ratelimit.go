@@ -0,0 +1,102 @@
+package grabber
+
+import (
+	"context"
+	"math/rand"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RateLimiter spaces out the requests made to each host, so small sites
+// aren't hammered into banning the grabber halfway through.
+type RateLimiter struct {
+	// Rate is the number of requests per second allowed per host,
+	// 0 means no limit.
+	Rate float64
+	// Rates are the requests per second allowed to the hosts in it, in
+	// place of Rate.
+	Rates map[string]float64
+	// Delay is waited between two requests to the same host, plus a
+	// random part of up to RandomDelay.
+	Delay       time.Duration
+	RandomDelay time.Duration
+
+	mu   sync.Mutex
+	next map[string]time.Time
+}
+
+// NewRateLimiter creates a limiter for rate requests per second and host
+// with at least delay plus up to randomDelay between them.
+func NewRateLimiter(rate float64, delay, randomDelay time.Duration) *RateLimiter {
+	return &RateLimiter{Rate: rate, Delay: delay, RandomDelay: randomDelay, next: make(map[string]time.Time)}
+}
+
+// rate returns the requests per second allowed to host.
+func (l *RateLimiter) rate(host string) float64 {
+	if r, ok := l.Rates[hostname(host)]; ok {
+		return r
+	}
+	return l.Rate
+}
+
+// hostname drops the port of host, if it has one.
+func hostname(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		return h
+	}
+	return host
+}
+
+// interval returns the gap to leave after a request to host.
+func (l *RateLimiter) interval(host string) time.Duration {
+	d := l.Delay
+	if rate := l.rate(host); rate > 0 {
+		if min := time.Duration(float64(time.Second) / rate); min > d {
+			d = min
+		}
+	}
+	if l.RandomDelay > 0 {
+		d += time.Duration(rand.Int63n(int64(l.RandomDelay)))
+	}
+	return d
+}
+
+// Wait blocks until a request to host may be made, or until ctx is done,
+// returning its error. Every caller reserves the next free slot for the
+// host, so concurrent downloads queue up instead of all firing once the
+// delay is over.
+func (l *RateLimiter) Wait(ctx context.Context, host string) error {
+	if l == nil || (l.Rate <= 0 && len(l.Rates) == 0 && l.Delay <= 0 && l.RandomDelay <= 0) {
+		return ctx.Err()
+	}
+	host = strings.ToLower(host)
+
+	l.mu.Lock()
+	now := time.Now()
+	slot := l.next[host]
+	if slot.Before(now) {
+		slot = now
+	}
+	l.next[host] = slot.Add(l.interval(host))
+	l.mu.Unlock()
+
+	t := time.NewTimer(time.Until(slot))
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// WaitURL is Wait for the host of rawURL.
+func (l *RateLimiter) WaitURL(ctx context.Context, rawURL string) error {
+	if u, err := url.Parse(rawURL); err == nil {
+		return l.Wait(ctx, u.Host)
+	}
+	return ctx.Err()
+}
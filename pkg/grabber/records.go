@@ -0,0 +1,79 @@
+package grabber
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+)
+
+// Record is a line of the JSON lines output, describing a grabbed image
+// for tools indexing the output directory.
+type Record struct {
+	// Page is the page the image was found on, if any.
+	Page        string `json:"page,omitempty"`
+	URL         string `json:"url"`
+	Path        string `json:"path"`
+	Bytes       int64  `json:"bytes"`
+	SHA256      string `json:"sha256,omitempty"`
+	ContentType string `json:"content_type"`
+	Width       int    `json:"width,omitempty"`
+	Height      int    `json:"height,omitempty"`
+	Status      int    `json:"status"`
+	DuplicateOf string `json:"duplicate_of,omitempty"`
+	SimilarTo   string `json:"similar_to,omitempty"`
+	Thumb       string `json:"thumb,omitempty"`
+	// Modified is the Last-Modified time the server sent, if any.
+	Modified time.Time `json:"modified,omitzero"`
+	// Playlist is hls or dash for playlists that weren't downloaded.
+	Playlist string    `json:"playlist,omitempty"`
+	Time     time.Time `json:"time"`
+}
+
+// newRecord describes f as grabbed now.
+func newRecord(f *File) Record {
+	return Record{
+		Page:        f.Page,
+		URL:         f.URL,
+		Path:        f.Path,
+		Bytes:       f.Size,
+		SHA256:      f.SHA256,
+		ContentType: f.ContentType,
+		Width:       f.Width,
+		Height:      f.Height,
+		Status:      f.Status,
+		DuplicateOf: f.DuplicateOf,
+		SimilarTo:   f.SimilarTo,
+		Thumb:       f.Thumb,
+		Modified:    f.Modified,
+		Time:        time.Now().UTC(),
+	}
+}
+
+// recordWriter appends records to a JSON lines file.
+type recordWriter struct {
+	mu  sync.Mutex
+	f   *os.File
+	enc *json.Encoder
+}
+
+// openRecords opens path for appending, so that resumed and repeated runs
+// add to the records of the earlier ones.
+func openRecords(path string) (*recordWriter, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &recordWriter{f: f, enc: json.NewEncoder(f)}, nil
+}
+
+func (w *recordWriter) write(r Record) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.enc.Encode(r)
+}
+
+func (w *recordWriter) Close() error {
+	return w.f.Close()
+}
@@ -0,0 +1,85 @@
+package grabber
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+)
+
+// Report sums up a run.
+type Report struct {
+	// Pages is the number of pages crawled.
+	Pages int `json:"pages"`
+	// Found is the number of image urls found on the pages, or given to
+	// Download.
+	Found      int   `json:"found"`
+	Downloaded int   `json:"downloaded"`
+	Bytes      int64 `json:"bytes"`
+	// Skipped counts the urls that weren't downloaded by reason.
+	Skipped map[string]int `json:"skipped,omitempty"`
+	Failed  []FailedURL    `json:"failed,omitempty"`
+	// Added are the images found that the manifest didn't have and
+	// Removed those of the crawled sites it has that weren't found again,
+	// with Options.Diff.
+	Added   []string      `json:"added,omitempty"`
+	Removed []string      `json:"removed,omitempty"`
+	Elapsed time.Duration `json:"-"`
+}
+
+// FailedURL is a Failure as it is written to the report file.
+type FailedURL struct {
+	URL       string `json:"url"`
+	Error     string `json:"error"`
+	Attempts  int    `json:"attempts"`
+	Permanent bool   `json:"permanent"`
+	Corrupt   bool   `json:"corrupt,omitempty"`
+}
+
+// Throughput returns the average bytes downloaded per second.
+func (r Report) Throughput() float64 {
+	if r.Elapsed <= 0 {
+		return 0
+	}
+	return float64(r.Bytes) / r.Elapsed.Seconds()
+}
+
+// WriteFile writes the report to path as JSON, with the elapsed time and
+// throughput in seconds.
+func (r Report) WriteFile(path string) error {
+	data, err := json.MarshalIndent(struct {
+		Report
+		ElapsedSeconds float64 `json:"elapsed_seconds"`
+		BytesPerSecond float64 `json:"bytes_per_second"`
+	}{r, r.Elapsed.Seconds(), r.Throughput()}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// Report sums up what the grabber did since it was created.
+func (g *Grabber) Report() Report {
+	s := g.failures
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r := Report{
+		Pages:      s.pages,
+		Found:      s.found,
+		Downloaded: s.downloaded,
+		Bytes:      s.bytes,
+		Elapsed:    time.Since(s.start),
+		Added:      append([]string(nil), s.added...),
+		Removed:    append([]string(nil), s.removed...),
+	}
+	if len(s.skipped) > 0 {
+		r.Skipped = make(map[string]int, len(s.skipped))
+		for reason, n := range s.skipped {
+			r.Skipped[reason] = n
+		}
+	}
+	for _, f := range s.failures {
+		r.Failed = append(r.Failed, FailedURL{URL: f.URL, Error: f.Err.Error(), Attempts: f.Attempts, Permanent: f.Permanent(), Corrupt: f.Corrupt()})
+	}
+	return r
+}
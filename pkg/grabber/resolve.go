@@ -0,0 +1,84 @@
+package grabber
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// parseResolve parses a HOST:IP override of Options.Resolve. The IP may be
+// an IPv6 address, in brackets or not.
+func parseResolve(s string) (host, ip string, err error) {
+	host, ip, ok := strings.Cut(s, ":")
+	host = strings.ToLower(strings.TrimSpace(host))
+	ip = strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(ip), "["), "]")
+	if !ok || host == "" || net.ParseIP(ip) == nil {
+		return "", "", fmt.Errorf("bad resolve %q, want HOST:IP", s)
+	}
+	return host, ip, nil
+}
+
+// resolveOverrides returns the addresses of Options.Resolve by host.
+func (o Options) resolveOverrides() (map[string]string, error) {
+	if len(o.Resolve) == 0 {
+		return nil, nil
+	}
+	hosts := make(map[string]string, len(o.Resolve))
+	for _, s := range o.Resolve {
+		host, ip, err := parseResolve(s)
+		if err != nil {
+			return nil, err
+		}
+		hosts[host] = ip
+	}
+	return hosts, nil
+}
+
+// validateIP checks Options.IPVersion and Options.Resolve.
+func (o Options) validateIP() error {
+	if o.IPVersion != 0 && o.IPVersion != 4 && o.IPVersion != 6 {
+		return fmt.Errorf("ip version must be 4 or 6, not %d", o.IPVersion)
+	}
+	_, err := o.resolveOverrides()
+	return err
+}
+
+// dialer returns the DialContext of the shared transport: it connects to
+// the address Options.Resolve pins a host to instead of looking it up,
+// and only over the IP version of Options.IPVersion if set. It returns
+// nil if neither is set, to keep the dialer of http.DefaultTransport.
+func (o Options) dialer() func(ctx context.Context, network, addr string) (net.Conn, error) {
+	hosts, _ := o.resolveOverrides()
+	if len(hosts) == 0 && o.IPVersion == 0 {
+		return nil
+	}
+
+	d := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if o.IPVersion != 0 {
+			network = fmt.Sprintf("tcp%d", o.IPVersion)
+		}
+		if host, port, err := net.SplitHostPort(addr); err == nil {
+			if ip, ok := hosts[strings.ToLower(host)]; ok {
+				addr = net.JoinHostPort(ip, port)
+			}
+		}
+		return d.DialContext(ctx, network, addr)
+	}
+}
+
+// hostResolverRules returns Options.Resolve as the --host-resolver-rules
+// of chrome, empty if there are none.
+func (o Options) hostResolverRules() string {
+	hosts, _ := o.resolveOverrides()
+	var rules []string
+	for host, ip := range hosts {
+		if strings.Contains(ip, ":") {
+			ip = "[" + ip + "]"
+		}
+		rules = append(rules, "MAP "+host+" "+ip)
+	}
+	return strings.Join(rules, ", ")
+}
@@ -0,0 +1,278 @@
+package grabber
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// maxBackoff caps the exponential delay between two attempts.
+const maxBackoff = time.Minute
+
+// statusError is returned when the server answers with a non 2xx status.
+type statusError struct {
+	Code       int
+	Status     string
+	RetryAfter time.Duration
+}
+
+func (e *statusError) Error() string {
+	return "bad status: " + e.Status
+}
+
+func newStatusError(resp *http.Response) *statusError {
+	err := &statusError{Code: resp.StatusCode, Status: resp.Status}
+	if secs, convErr := strconv.Atoi(resp.Header.Get("Retry-After")); convErr == nil && secs > 0 {
+		err.RetryAfter = time.Duration(secs) * time.Second
+	}
+	return err
+}
+
+// isRetryable tells transient errors (5xx, 429, timeouts, refused or
+// dropped connections, corrupt downloads) apart from permanent ones like a
+// 404, a full disk, a bad url, a certificate that doesn't verify or a host
+// that doesn't exist.
+func isRetryable(err error) bool {
+	if errors.Is(err, ErrBadURL) {
+		return false
+	}
+
+	var (
+		cve *tls.CertificateVerificationError
+		uae x509.UnknownAuthorityError
+		he  x509.HostnameError
+		cie x509.CertificateInvalidError
+	)
+	if errors.As(err, &cve) || errors.As(err, &uae) || errors.As(err, &he) || errors.As(err, &cie) {
+		return false
+	}
+	var de *net.DNSError
+	if errors.As(err, &de) {
+		return !de.IsNotFound && (de.IsTimeout || de.IsTemporary)
+	}
+
+	var ce *ChecksumError
+	if errors.As(err, &ce) {
+		return true
+	}
+
+	var se *statusError
+	if errors.As(err, &se) {
+		return se.Code >= 500 || se.Code == http.StatusTooManyRequests || se.Code == http.StatusRequestTimeout
+	}
+
+	if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	var ne net.Error
+	return errors.As(err, &ne) && ne.Timeout()
+}
+
+// withTimeout returns ctx ending after d, or ctx as it is if d is 0.
+func withTimeout(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if d <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, d)
+}
+
+// timeoutError says err is a timeout of d if ctx, made by withTimeout,
+// ended it.
+func timeoutError(ctx context.Context, err error, d time.Duration) error {
+	if err != nil && d > 0 && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("timed out after %v: %w", d, err)
+	}
+	return err
+}
+
+// backoff returns how long to wait before the given attempt (starting at 1
+// for the first retry): opts.Backoff doubled on each attempt, capped at
+// maxBackoff and spread by +/- opts.Jitter so parallel workers don't retry
+// in lockstep. A Retry-After sent by the server takes precedence.
+func backoff(opts Options, attempt int, err error) time.Duration {
+	var se *statusError
+	if errors.As(err, &se) && se.RetryAfter > 0 {
+		return se.RetryAfter
+	}
+
+	d := opts.Backoff << uint(attempt-1)
+	if d <= 0 || d > maxBackoff {
+		d = maxBackoff
+	}
+	if opts.Jitter > 0 {
+		d += time.Duration((rand.Float64()*2 - 1) * opts.Jitter * float64(d))
+	}
+
+	return d
+}
+
+// retry calls fn until it succeeds, fails with a permanent error,
+// Options.Retries retries have been used up or ctx is done. It returns the
+// number of attempts made along with the last error.
+func (g *Grabber) retry(ctx context.Context, url string, fn func() error) (int, error) {
+	attempt := 0
+	for {
+		attempt++
+		err := fn()
+		if err == nil || ctx.Err() != nil || !isRetryable(err) || attempt > g.Options.Retries {
+			return attempt, err
+		}
+
+		wait := backoff(g.Options, attempt, err)
+		g.log().Warn("retrying", "url", url, "attempt", attempt, "wait", wait, "err", err)
+		if g.OnRetry != nil {
+			g.OnRetry(url, attempt, wait, err)
+		}
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return attempt, err
+		}
+	}
+}
+
+// Failure is a single url that couldn't be grabbed.
+type Failure struct {
+	URL      string
+	Err      error
+	Attempts int
+}
+
+// Permanent reports whether the failure is one retrying can't fix, as
+// opposed to a transient one that ran out of retries.
+func (f Failure) Permanent() bool {
+	return !isRetryable(f.Err)
+}
+
+// Corrupt reports whether the download never matched the checksum of its
+// server.
+func (f Failure) Corrupt() bool {
+	var ce *ChecksumError
+	return errors.As(f.Err, &ce)
+}
+
+// summary collects the failures of a run so they can be reported at the end
+// instead of aborting on the first one, along with the counts of the Report.
+type summary struct {
+	mu       sync.Mutex
+	total    int
+	failures []Failure
+
+	start      time.Time
+	pages      int
+	found      int
+	downloaded int
+	bytes      int64
+	skipped    map[string]int
+	// added and removed are the images Options.Diff found new and gone.
+	added   []string
+	removed []string
+}
+
+func newSummary() *summary {
+	return &summary{start: time.Now(), skipped: make(map[string]int)}
+}
+
+func (s *summary) page() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pages++
+}
+
+func (s *summary) find(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.found += n
+}
+
+func (s *summary) download(size int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.downloaded++
+	s.bytes += size
+}
+
+func (s *summary) skip(reason string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.skipped[reason]++
+}
+
+func (s *summary) diff(added, removed []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.added = append(s.added, added...)
+	s.removed = append(s.removed, removed...)
+}
+
+func (s *summary) attempt() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.total++
+}
+
+func (s *summary) fail(f Failure) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.failures = append(s.failures, f)
+}
+
+// rate returns how many urls were tried and the fraction of them that
+// failed.
+func (s *summary) rate() (int, float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.total == 0 {
+		return 0, 0
+	}
+	return s.total, float64(len(s.failures)) / float64(s.total)
+}
+
+// FailedError is returned by a run that went through but where some urls
+// failed, see Grabber.Failures.
+type FailedError struct {
+	Failed int
+	// Tried counts the urls downloaded, skipped along the way or failed.
+	Tried int
+}
+
+func (e *FailedError) Error() string {
+	return fmt.Sprintf("%d of %d failed", e.Failed, e.Tried)
+}
+
+// Partial reports whether only some of the urls failed.
+func (e *FailedError) Partial() bool {
+	return e.Failed < e.Tried
+}
+
+// err returns a FailedError if anything failed.
+func (s *summary) err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.failures) == 0 {
+		return nil
+	}
+
+	return &FailedError{Failed: len(s.failures), Tried: s.total}
+}
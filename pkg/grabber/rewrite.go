@@ -0,0 +1,94 @@
+package grabber
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// RewriteRule is a sed style s/pattern/replacement/ rule rewriting urls.
+type RewriteRule struct {
+	Pattern     *regexp.Regexp
+	Replacement string
+}
+
+// ParseRewriteRule parses a rule written as s/pattern/replacement/, where
+// any character following the s may stand in for the /, and a trailing i
+// makes the pattern ignore case. The replacement refers to the groups of
+// the pattern as $1 or \1.
+func ParseRewriteRule(rule string) (*RewriteRule, error) {
+	if len(rule) < 4 || rule[0] != 's' {
+		return nil, fmt.Errorf("rewrite rule %q is not s/pattern/replacement/", rule)
+	}
+	delim := rule[1:2]
+	parts := splitUnescaped(rule[2:], delim[0])
+	if len(parts) != 3 || parts[2] != "" && parts[2] != "i" {
+		return nil, fmt.Errorf("rewrite rule %q is not s/pattern/replacement/", rule)
+	}
+	pattern := strings.ReplaceAll(parts[0], `\`+delim, delim)
+	if parts[2] == "i" {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("rewrite rule %q: %v", rule, err)
+	}
+	repl := strings.ReplaceAll(parts[1], `\`+delim, delim)
+	repl = sedGroup.ReplaceAllString(repl, "$${$1}")
+	return &RewriteRule{Pattern: re, Replacement: repl}, nil
+}
+
+// sedGroup is a \1 group reference of a sed replacement.
+var sedGroup = regexp.MustCompile(`\\(\d)`)
+
+// splitUnescaped splits s at every delim that isn't escaped by a backslash.
+func splitUnescaped(s string, delim byte) []string {
+	var parts []string
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case delim:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// Rewrite returns u rewritten by the rule, or u if the pattern doesn't
+// match.
+func (r *RewriteRule) Rewrite(u string) string {
+	return r.Pattern.ReplaceAllString(u, r.Replacement)
+}
+
+// parseRewriteRules parses every rule of rules.
+func parseRewriteRules(rules []string) ([]*RewriteRule, error) {
+	var parsed []*RewriteRule
+	for _, rule := range rules {
+		r, err := ParseRewriteRule(rule)
+		if err != nil {
+			return nil, err
+		}
+		parsed = append(parsed, r)
+	}
+	return parsed, nil
+}
+
+// rewrite returns u rewritten by every rule of c.Rewrite in turn.
+func (c *Collector) rewrite(u string) string {
+	for _, r := range c.Rewrite {
+		u = r.Rewrite(u)
+	}
+	return u
+}
+
+// imageURL returns the url an image found as u is downloaded from, u
+// rewritten and normalized.
+func (c *Collector) imageURL(u string) string {
+	if !isHTTP(u) {
+		return u
+	}
+	return c.normalize(c.rewrite(u))
+}
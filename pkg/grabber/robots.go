@@ -0,0 +1,94 @@
+package grabber
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"github.com/temoto/robotstxt"
+)
+
+// RobotsReason is the skip reason of urls that robots.txt disallows.
+const RobotsReason = "disallowed by robots.txt"
+
+// robots checks urls against the robots.txt of their host, fetched once per
+// host and cached for the rest of the run.
+type robots struct {
+	client    *http.Client
+	headers   map[string]string
+	limiter   *RateLimiter
+	userAgent string
+
+	mu    sync.Mutex
+	hosts map[string]*robotstxt.Group
+}
+
+func newRobots(client *http.Client, headers map[string]string, limiter *RateLimiter, userAgent string) *robots {
+	return &robots{client: client, headers: headers, limiter: limiter, userAgent: userAgent, hosts: make(map[string]*robotstxt.Group)}
+}
+
+// allowed reports whether robots.txt lets us fetch rawURL. Hosts whose
+// robots.txt can't be fetched or parsed allow everything, except for
+// server errors, which robotstxt takes as everything being disallowed.
+func (r *robots) allowed(ctx context.Context, rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil || !isHTTP(rawURL) {
+		return true
+	}
+
+	group := r.group(ctx, u)
+	if group == nil {
+		return true
+	}
+	path := u.EscapedPath()
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+	return group.Test(path)
+}
+
+// group returns the rules of the host of u that apply to our user agent.
+// Those of a fetch cut short by ctx aren't kept.
+func (r *robots) group(ctx context.Context, u *url.URL) *robotstxt.Group {
+	key := u.Scheme + "://" + u.Host
+
+	// Held while fetching so that concurrent downloads from a new host
+	// don't all request its robots.txt
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if group, ok := r.hosts[key]; ok {
+		return group
+	}
+	group := r.fetch(ctx, key)
+	if ctx.Err() == nil {
+		r.hosts[key] = group
+	}
+	return group
+}
+
+func (r *robots) fetch(ctx context.Context, origin string) *robotstxt.Group {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, origin+"/robots.txt", nil)
+	if err != nil {
+		return nil
+	}
+	for k, v := range r.headers {
+		req.Header.Set(k, v)
+	}
+
+	if err := r.limiter.Wait(ctx, req.URL.Host); err != nil {
+		return nil
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil
+	}
+	defer resp.Body.Close()
+
+	data, err := robotstxt.FromResponse(resp)
+	if err != nil {
+		return nil
+	}
+	return data.FindGroup(r.userAgent)
+}
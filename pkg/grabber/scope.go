@@ -0,0 +1,91 @@
+package grabber
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// DomainReason is the reason urls on hosts outside Options.AllowedDomains
+// are skipped.
+const DomainReason = "outside allowed domains"
+
+// domainScope is the hosts a run may touch: the allowed domains and their
+// subdomains.
+type domainScope struct {
+	domains []string
+}
+
+// newDomainScope returns the scope of Options.AllowedDomains, which also
+// takes in the registered domain of start, if any, so that the images a
+// site keeps on a CDN host like img.cdn.example.com are grabbed when
+// crawling www.example.com. Without allowed domains there is no limit and
+// it returns nil.
+func newDomainScope(allowed []string, start string) *domainScope {
+	if len(allowed) == 0 {
+		return nil
+	}
+	s := &domainScope{}
+	for _, d := range allowed {
+		s.domains = append(s.domains, domainName(d))
+	}
+	if u, err := url.Parse(start); err == nil && u.Hostname() != "" {
+		s.domains = append(s.domains, registeredDomain(u.Hostname()))
+	}
+	return s
+}
+
+// allows reports whether the host of rawURL is one of the domains or a
+// subdomain of one. A nil scope allows everything, as do data: urls.
+func (s *domainScope) allows(rawURL string) bool {
+	if s == nil || !isHTTP(rawURL) {
+		return true
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, d := range s.domains {
+		if host == d || strings.HasSuffix(host, "."+d) {
+			return true
+		}
+	}
+	return false
+}
+
+// domainName returns the domain d names, taking example.com out of
+// *.example.com, .example.com and https://example.com/ alike.
+func domainName(d string) string {
+	d = strings.ToLower(strings.TrimSpace(d))
+	if strings.Contains(d, "://") {
+		if u, err := url.Parse(d); err == nil {
+			d = u.Hostname()
+		}
+	}
+	d = strings.TrimPrefix(d, "*")
+	return strings.Trim(d, ".")
+}
+
+// registeredDomain returns the domain host was registered under, as
+// example.co.uk for img.example.co.uk, or host itself for IP addresses and
+// names like localhost.
+func registeredDomain(host string) string {
+	host = strings.ToLower(host)
+	if d, err := publicsuffix.EffectiveTLDPlusOne(host); err == nil {
+		return d
+	}
+	return host
+}
+
+// validateDomains checks Options.AllowedDomains.
+func (o Options) validateDomains() error {
+	for _, d := range o.AllowedDomains {
+		if domainName(d) == "" {
+			return fmt.Errorf("allowed domain %q names no domain", d)
+		}
+	}
+	return nil
+}
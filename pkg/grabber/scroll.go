@@ -0,0 +1,158 @@
+package grabber
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+)
+
+const (
+	// scrollIdle is how long the network has to be quiet after a scroll
+	// for the page to be done loading more.
+	scrollIdle = 500 * time.Millisecond
+	// scrollTimeout is the longest a scroll waits for the network to go
+	// quiet.
+	scrollTimeout = 10 * time.Second
+)
+
+// render opens pageURL in chrome and, with c.Scroll, scrolls to the bottom
+// until no more elements matching c.LinkSelector or c.ImageSelector appear,
+// c.MaxScrolls scrolls were made or c.ScrollItems of them are there. Then
+// the c.LoadMore button is clicked the same way. It returns the HTML of the
+// page as it is then.
+func (c *Collector) render(ctx context.Context, pageURL string) (string, error) {
+	ctx, cancel, err := c.openTab(ctx, pageURL)
+	if err != nil {
+		return "", err
+	}
+	defer cancel()
+
+	idle := watchNetwork(ctx)
+	if err := chromedp.Run(ctx, network.Enable()); err != nil {
+		return "", err
+	}
+
+	var selectors []string
+	for _, s := range []string{c.LinkSelector, c.ImageSelector} {
+		if s != "" {
+			selectors = append(selectors, s)
+		}
+	}
+	// A JSON string is a valid JavaScript string literal
+	quoted, _ := json.Marshal(strings.Join(selectors, ", "))
+	count := `document.querySelectorAll(` + string(quoted) + `).length`
+
+	last, scrolls := -1, 0
+	for c.Scroll {
+		var items int
+		if len(selectors) > 0 {
+			if err := chromedp.Run(ctx, chromedp.Evaluate(count, &items)); err != nil {
+				return "", err
+			}
+		}
+		if items == last ||
+			(c.ScrollItems > 0 && items >= c.ScrollItems) ||
+			(c.MaxScrolls > 0 && scrolls == c.MaxScrolls) {
+			orDiscard(c.Logger).Debug("scrolled page", "url", pageURL, "scrolls", scrolls, "items", items)
+			break
+		}
+		last = items
+
+		if err := chromedp.Run(ctx, chromedp.Evaluate(`window.scrollTo(0, document.body.scrollHeight)`, nil)); err != nil {
+			return "", err
+		}
+		scrolls++
+		idle.wait(scrollIdle, scrollTimeout)
+	}
+
+	if c.LoadMore != "" {
+		if len(selectors) == 0 {
+			count = `document.getElementsByTagName("*").length`
+		}
+		if err := c.clickLoadMore(ctx, idle, count, pageURL); err != nil {
+			return "", err
+		}
+	}
+
+	var html string
+	if err := chromedp.Run(ctx, chromedp.OuterHTML("html", &html, chromedp.ByQuery)); err != nil {
+		return "", err
+	}
+	if err := c.saveCookies(ctx); err != nil {
+		return "", err
+	}
+
+	return html, nil
+}
+
+// networkIdle keeps track of the requests a tab has in flight.
+type networkIdle struct {
+	mu       sync.Mutex
+	inflight map[network.RequestID]bool
+	last     time.Time
+}
+
+// watchNetwork starts tracking the requests of the tab of ctx.
+func watchNetwork(ctx context.Context) *networkIdle {
+	n := &networkIdle{inflight: make(map[network.RequestID]bool), last: time.Now()}
+	chromedp.ListenTarget(ctx, func(ev interface{}) {
+		n.mu.Lock()
+		defer n.mu.Unlock()
+
+		switch ev := ev.(type) {
+		case *network.EventRequestWillBeSent:
+			n.inflight[ev.RequestID] = true
+		case *network.EventLoadingFinished:
+			delete(n.inflight, ev.RequestID)
+		case *network.EventLoadingFailed:
+			delete(n.inflight, ev.RequestID)
+		default:
+			return
+		}
+		n.last = time.Now()
+	})
+	return n
+}
+
+// wait returns once no request has been in flight for quiet, or after
+// timeout at the latest.
+func (n *networkIdle) wait(quiet, timeout time.Duration) {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		time.Sleep(quiet / 5)
+
+		n.mu.Lock()
+		done := len(n.inflight) == 0 && time.Since(n.last) >= quiet
+		n.mu.Unlock()
+		if done {
+			return
+		}
+	}
+}
+
+// renderedPage answers every request with the HTML of a page rendered in
+// chrome, so that colly can parse it like any other page.
+type renderedPage struct {
+	html string
+}
+
+func (p renderedPage) RoundTrip(req *http.Request) (*http.Response, error) {
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"text/html; charset=utf-8"}},
+		Body:          io.NopCloser(strings.NewReader(p.html)),
+		ContentLength: int64(len(p.html)),
+		Request:       req,
+	}, nil
+}
@@ -0,0 +1,707 @@
+package grabber
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Job states reported by the Server.
+const (
+	JobQueued   = "queued"
+	JobRunning  = "running"
+	JobDone     = "done"
+	JobFailed   = "failed"
+	JobCanceled = "canceled"
+)
+
+// JobRequest is what is posted to a Server to start a job. Options are
+// applied on top of the defaults of the server.
+type JobRequest struct {
+	// Command is crawl, the default, or download.
+	Command string `json:"command"`
+	// URL is short for URLs with a single url.
+	URL     string          `json:"url,omitempty"`
+	URLs    []string        `json:"urls,omitempty"`
+	Options json.RawMessage `json:"options,omitempty"`
+}
+
+// FileProgress is a download under way.
+type FileProgress struct {
+	URL     string `json:"url"`
+	File    string `json:"file"`
+	Written uint64 `json:"written"`
+	// Total is 0 if the server didn't say.
+	Total uint64 `json:"total"`
+	Done  bool   `json:"done,omitempty"`
+}
+
+// JobFile is a file a job downloaded. Path and Thumb are relative to the
+// job directory.
+type JobFile struct {
+	URL    string `json:"url"`
+	Path   string `json:"path"`
+	Thumb  string `json:"thumb,omitempty"`
+	Size   int64  `json:"size"`
+	Width  int    `json:"width,omitempty"`
+	Height int    `json:"height,omitempty"`
+}
+
+// recentFiles is how many of the last files of a job JobInfo lists.
+const recentFiles = 100
+
+// JobInfo is what a Server tells about a job.
+type JobInfo struct {
+	ID      string    `json:"id"`
+	Command string    `json:"command"`
+	URLs    []string  `json:"urls"`
+	Dir     string    `json:"dir"`
+	State   string    `json:"state"`
+	Error   string    `json:"error,omitempty"`
+	Created time.Time `json:"created"`
+	Started time.Time `json:"started,omitzero"`
+	Ended   time.Time `json:"ended,omitzero"`
+	// Report is how far the job got, Active the downloads under way.
+	Report Report         `json:"report"`
+	Active []FileProgress `json:"active,omitempty"`
+	// Files are the last files downloaded, the newest first.
+	Files []JobFile `json:"files,omitempty"`
+}
+
+// JobEvent is something that happened to a job watched with Server.Watch.
+// One of its fields is set.
+type JobEvent struct {
+	// Progress is how far a download got, the last one is Done.
+	Progress *FileProgress
+	// File is a file the job saved.
+	File *JobFile
+	// Job is the job as it is after its state changed.
+	Job *JobInfo
+}
+
+// ErrNoSuchJob is returned by the methods of a Server for an unknown id.
+var ErrNoSuchJob = errors.New("no such job")
+
+// ErrJobEnded is returned by Server.CancelJob for jobs that already ended.
+var ErrJobEnded = errors.New("job ended")
+
+// Server runs grab jobs submitted over HTTP:
+//
+//	POST /jobs                 start a job, the body is a JobRequest
+//	GET  /jobs                 list the jobs
+//	GET  /jobs/{id}            the JobInfo of a job, with its progress
+//	POST /jobs/{id}/cancel     cancel a queued or running job
+//	GET  /jobs/{id}/manifest   the manifest of a job
+//	GET  /jobs/{id}/files/...  a file of a job writing to a directory
+//	GET  /                     the dashboard, a web page driving the API
+//
+// The token can also be given as the access_token query parameter, for
+// the images of the dashboard.
+//
+// Every job gets a directory of its own in Root, or the one its options
+// name there. The files the options name are taken relative to the job
+// directory, or to Root for jobs writing to an archive or a storage, none
+// can be outside of Root. As storages are written to with the credentials
+// of the server, jobs can only ask for one under Defaults.Dir, if that is
+// a storage url, and the password of a storage url is masked in JobInfo.
+type Server struct {
+	// Root is the directory the jobs write into.
+	Root string
+	// Defaults are the options jobs start from.
+	Defaults Options
+	// MaxRunning is how many jobs run at the same time, the others wait.
+	// 0 is 1.
+	MaxRunning int
+	// Token is the bearer token requests need, empty lets everybody in.
+	Token string
+	// Logger gets what the jobs do, nil logs nothing.
+	Logger *slog.Logger
+
+	once  sync.Once
+	mux   *http.ServeMux
+	slots chan struct{}
+
+	mu     sync.Mutex
+	jobs   map[string]*serverJob
+	lastID int
+	wg     sync.WaitGroup
+}
+
+// serverJob is a job of the Server, guarded by its mutex.
+type serverJob struct {
+	info    JobInfo
+	opts    Options
+	grabber *Grabber
+	active  map[string]FileProgress
+	files   []JobFile
+	cancel  chan struct{}
+	// watchers get the events of the job until it ends.
+	watchers map[chan JobEvent]bool
+}
+
+// watchBuffer is how many events a watcher can lag behind before the
+// events it misses are dropped.
+const watchBuffer = 256
+
+// ServeHTTP serves the API.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.init()
+
+	// The dashboard asks for the token itself
+	if s.Token != "" && r.URL.Path != "/" {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok {
+			token = r.URL.Query().Get("access_token")
+		}
+		if subtle.ConstantTimeCompare([]byte(token), []byte(s.Token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, errors.New("missing or wrong token"))
+			return
+		}
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) init() {
+	s.once.Do(func() {
+		n := s.MaxRunning
+		if n < 1 {
+			n = 1
+		}
+		s.slots = make(chan struct{}, n)
+		s.jobs = make(map[string]*serverJob)
+
+		s.mux = http.NewServeMux()
+		s.mux.HandleFunc("POST /jobs", s.handleCreate)
+		s.mux.HandleFunc("GET /jobs", s.handleList)
+		s.mux.HandleFunc("GET /jobs/{id}", s.handleGet)
+		s.mux.HandleFunc("POST /jobs/{id}/cancel", s.handleCancel)
+		s.mux.HandleFunc("GET /jobs/{id}/manifest", s.handleManifest)
+		s.mux.HandleFunc("GET /jobs/{id}/files/{path...}", s.handleFile)
+		s.mux.HandleFunc("GET /{$}", handleDashboard)
+	})
+}
+
+// Close cancels the jobs and waits for those running to save what they
+// downloaded.
+func (s *Server) Close() {
+	s.init()
+
+	s.mu.Lock()
+	for _, job := range s.jobs {
+		job.stop()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+// stop cancels job, once.
+func (job *serverJob) stop() {
+	select {
+	case <-job.cancel:
+	default:
+		close(job.cancel)
+	}
+}
+
+func (s *Server) handleCreate(w http.ResponseWriter, r *http.Request) {
+	var req JobRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	info, err := s.Submit(req)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	w.Header().Set("Location", "/jobs/"+info.ID)
+	writeJSON(w, http.StatusCreated, info)
+}
+
+// Submit starts the job req once one of the MaxRunning slots is free. It
+// fails if the request or its options are wrong.
+func (s *Server) Submit(req JobRequest) (JobInfo, error) {
+	s.init()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := strconv.Itoa(s.lastID + 1)
+	job, err := s.newJob(id, req)
+	if err != nil {
+		return JobInfo{}, err
+	}
+	s.lastID++
+	s.jobs[id] = job
+	s.wg.Add(1)
+	go s.run(job)
+	return s.infoLocked(job), nil
+}
+
+// newJob checks req and makes a job of it.
+func (s *Server) newJob(id string, req JobRequest) (*serverJob, error) {
+	switch req.Command {
+	case "":
+		req.Command = "crawl"
+	case "crawl", "download":
+	default:
+		return nil, fmt.Errorf("unknown command %q", req.Command)
+	}
+	urls := req.URLs
+	if req.URL != "" {
+		urls = append([]string{req.URL}, urls...)
+	}
+	if len(urls) == 0 {
+		return nil, errors.New("no urls")
+	}
+
+	opts := s.Defaults.forJob()
+	opts.Dir = id
+	if len(req.Options) > 0 {
+		dec := json.NewDecoder(bytes.NewReader(req.Options))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&opts); err != nil {
+			return nil, fmt.Errorf("options: %v", err)
+		}
+	}
+	if opts.DryRun {
+		return nil, errors.New("jobs can't be dry runs")
+	}
+	// Running commands is up to whoever runs the server
+	if opts.Exec != s.Defaults.Exec {
+		return nil, errors.New("jobs can't set exec")
+	}
+	// Nor is trusting any server or sending the client certificate of the
+	// server to them
+	if opts.TLS != s.Defaults.TLS {
+		return nil, errors.New("jobs can't set tls")
+	}
+	// The cookies of the browser of the server only go where it says
+	if opts.CookiesFromBrowser != s.Defaults.CookiesFromBrowser {
+		return nil, errors.New("jobs can't set cookies_from_browser")
+	}
+	// Nor do its credentials, storages only take jobs under its own
+	if IsRemote(opts.Dir) && archiveFormat(opts.Dir) == "" && !underStorage(opts.Dir, s.Defaults.Dir) {
+		return nil, errors.New("jobs can only write to a storage under the one of the server")
+	}
+	if err := s.confine(&opts); err != nil {
+		return nil, err
+	}
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &serverJob{
+		info:     JobInfo{ID: id, Command: req.Command, URLs: urls, Dir: redactDir(opts.Dir), State: JobQueued, Created: time.Now()},
+		opts:     opts,
+		active:   make(map[string]FileProgress),
+		cancel:   make(chan struct{}),
+		watchers: make(map[chan JobEvent]bool),
+	}, nil
+}
+
+// underStorage reports whether the storage url dir is root or in it, on the
+// same host as the same user.
+func underStorage(dir, root string) bool {
+	if !IsRemote(root) || archiveFormat(root) != "" {
+		return false
+	}
+	d, err := url.Parse(dir)
+	if err != nil {
+		return false
+	}
+	r, err := url.Parse(root)
+	if err != nil {
+		return false
+	}
+	if d.Scheme != r.Scheme || !strings.EqualFold(d.Host, r.Host) || d.User.Username() != r.User.Username() {
+		return false
+	}
+	dp, rp := path.Clean("/"+d.Path), path.Clean("/"+r.Path)
+	return rp == "/" || dp == rp || strings.HasPrefix(dp, rp+"/")
+}
+
+// confine puts the output and files of opts in Root, failing for those
+// that would be outside of it. Storage urls are left as they are.
+func (s *Server) confine(opts *Options) error {
+	// Archives are local files too
+	if !IsRemote(opts.Dir) || archiveFormat(opts.Dir) != "" {
+		if !filepath.IsLocal(opts.Dir) {
+			return errors.New("dir must be a relative path inside the root")
+		}
+		opts.Dir = filepath.Join(s.Root, opts.Dir)
+	}
+	if opts.Manifest != "" && !filepath.IsLocal(opts.Manifest) {
+		return errors.New("manifest must be a relative path inside the job directory")
+	}
+	if opts.Frontier != "" && !filepath.IsLocal(opts.Frontier) {
+		return errors.New("frontier must be a relative path inside the job directory")
+	}
+
+	// The files of jobs not writing to a directory go in Root
+	dir := opts.Dir
+	if IsRemote(opts.Dir) {
+		dir = s.Root
+	}
+	for _, f := range []struct {
+		name string
+		path *string
+	}{
+		{"json_manifest", &opts.JSONManifest},
+		{"catalog", &opts.Catalog},
+		{"export_links", &opts.ExportLinks},
+		{"cookies_file", &opts.CookiesFile},
+		{"proxy_file", &opts.ProxyFile},
+	} {
+		if *f.path == "" {
+			continue
+		}
+		if !filepath.IsLocal(*f.path) {
+			return fmt.Errorf("%s must be a relative path inside the job directory", f.name)
+		}
+		*f.path = filepath.Join(dir, *f.path)
+	}
+	return nil
+}
+
+// run waits for a slot and runs job.
+func (s *Server) run(job *serverJob) {
+	defer s.wg.Done()
+
+	select {
+	case s.slots <- struct{}{}:
+		defer func() { <-s.slots }()
+	case <-job.cancel:
+		s.mu.Lock()
+		job.info.State, job.info.Ended = JobCanceled, time.Now()
+		s.endLocked(job)
+		s.mu.Unlock()
+		return
+	}
+
+	log := orDiscard(s.Logger).With("job", job.info.ID)
+	g, err := New(job.opts)
+	s.mu.Lock()
+	job.info.Started = time.Now()
+	if err != nil {
+		job.info.State, job.info.Error, job.info.Ended = JobFailed, err.Error(), time.Now()
+		s.endLocked(job)
+		s.mu.Unlock()
+		log.Error("job failed", "err", err)
+		return
+	}
+	job.info.State = JobRunning
+	job.grabber = g
+	info := s.infoLocked(job)
+	s.emitLocked(job, JobEvent{Job: &info})
+	s.mu.Unlock()
+
+	g.SetLogger(log)
+	g.OnProgress = func(p Progress) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		fp := FileProgress{URL: p.URL, File: p.File, Written: p.Written, Total: p.Total, Done: p.Done}
+		if p.Done {
+			delete(job.active, p.URL)
+		} else {
+			job.active[p.URL] = fp
+		}
+		s.emitLocked(job, JobEvent{Progress: &fp})
+	}
+	g.OnDownload = func(f *File) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		jf := JobFile{URL: f.URL, Path: job.relative(f.Path), Thumb: job.relative(f.Thumb),
+			Size: f.Size, Width: f.Width, Height: f.Height}
+		job.files = append(job.files, jf)
+		if len(job.files) > recentFiles {
+			job.files = job.files[1:]
+		}
+		s.emitLocked(job, JobEvent{File: &jf})
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-job.cancel:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	log.Info("job started", "command", job.info.Command, "urls", len(job.info.URLs))
+
+	if job.info.Command == "download" {
+		err = g.Download(ctx, job.info.URLs)
+	} else {
+		for _, u := range job.info.URLs {
+			if crawlErr := g.Crawl(ctx, u); crawlErr != nil {
+				log.Warn("crawl failed", "url", u, "err", crawlErr)
+				err = crawlErr
+			}
+		}
+	}
+	if closeErr := g.Close(); err == nil {
+		err = closeErr
+	}
+
+	s.mu.Lock()
+	job.info.Ended = time.Now()
+	job.info.Report = g.Report()
+	job.active = make(map[string]FileProgress)
+	switch {
+	case errors.Is(err, ErrCanceled):
+		job.info.State = JobCanceled
+	case err != nil:
+		job.info.State, job.info.Error = JobFailed, err.Error()
+	default:
+		job.info.State = JobDone
+	}
+	s.endLocked(job)
+	s.mu.Unlock()
+	log.Info("job ended", "state", job.info.State, "downloaded", job.info.Report.Downloaded)
+}
+
+// infoLocked returns the JobInfo of job, with its report up to date while
+// it runs.
+func (s *Server) infoLocked(job *serverJob) JobInfo {
+	info := job.info
+	if job.grabber != nil && info.State == JobRunning {
+		info.Report = job.grabber.Report()
+	}
+	for _, p := range job.active {
+		info.Active = append(info.Active, p)
+	}
+	sort.Slice(info.Active, func(i, j int) bool { return info.Active[i].URL < info.Active[j].URL })
+	for i := len(job.files) - 1; i >= 0; i-- {
+		info.Files = append(info.Files, job.files[i])
+	}
+	return info
+}
+
+// emitLocked hands e to the watchers of job, dropping it for those that
+// lag behind.
+func (s *Server) emitLocked(job *serverJob, e JobEvent) {
+	for ch := range job.watchers {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+// endLocked tells the watchers of job how it ended and lets them go.
+func (s *Server) endLocked(job *serverJob) {
+	info := s.infoLocked(job)
+	s.emitLocked(job, JobEvent{Job: &info})
+	for ch := range job.watchers {
+		close(ch)
+	}
+	job.watchers = nil
+}
+
+// Watch returns the events of the job id as they happen, starting with
+// its state now. The channel is closed once the job ended, or by calling
+// stop.
+func (s *Server) Watch(id string) (events <-chan JobEvent, stop func(), err error) {
+	s.init()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job := s.jobs[id]
+	if job == nil {
+		return nil, nil, ErrNoSuchJob
+	}
+	ch := make(chan JobEvent, watchBuffer)
+	info := s.infoLocked(job)
+	ch <- JobEvent{Job: &info}
+	if job.watchers == nil {
+		close(ch)
+		return ch, func() {}, nil
+	}
+
+	job.watchers[ch] = true
+	return ch, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if job.watchers[ch] {
+			delete(job.watchers, ch)
+			close(ch)
+		}
+	}, nil
+}
+
+// Job returns the JobInfo of the job id.
+func (s *Server) Job(id string) (JobInfo, error) {
+	s.init()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job := s.jobs[id]
+	if job == nil {
+		return JobInfo{}, ErrNoSuchJob
+	}
+	return s.infoLocked(job), nil
+}
+
+// Jobs returns the JobInfo of every job, the oldest first.
+func (s *Server) Jobs() []JobInfo {
+	s.init()
+
+	s.mu.Lock()
+	list := make([]JobInfo, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		list = append(list, s.infoLocked(job))
+	}
+	s.mu.Unlock()
+
+	sort.Slice(list, func(i, j int) bool { return list[i].Created.Before(list[j].Created) })
+	return list
+}
+
+// CancelJob cancels the job id if it is queued or running. A running job
+// interrupts the requests under way and saves what it downloaded.
+func (s *Server) CancelJob(id string) (JobInfo, error) {
+	s.init()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job := s.jobs[id]
+	if job == nil {
+		return JobInfo{}, ErrNoSuchJob
+	}
+	info := s.infoLocked(job)
+	if info.State != JobQueued && info.State != JobRunning {
+		return info, fmt.Errorf("%w, it is %s", ErrJobEnded, info.State)
+	}
+	job.stop()
+	return info, nil
+}
+
+// relative returns the path p of a file of job relative to its directory.
+// Files in a storage keep their url.
+func (job *serverJob) relative(p string) string {
+	if p == "" || IsRemote(job.opts.Dir) {
+		return p
+	}
+	if rel, err := filepath.Rel(job.opts.Dir, p); err == nil {
+		return filepath.ToSlash(rel)
+	}
+	return p
+}
+
+func (s *Server) job(w http.ResponseWriter, r *http.Request) *serverJob {
+	s.mu.Lock()
+	job := s.jobs[r.PathValue("id")]
+	s.mu.Unlock()
+	if job == nil {
+		writeError(w, http.StatusNotFound, ErrNoSuchJob)
+	}
+	return job
+}
+
+func (s *Server) handleList(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.Jobs())
+}
+
+func (s *Server) handleGet(w http.ResponseWriter, r *http.Request) {
+	info, err := s.Job(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, info)
+}
+
+func (s *Server) handleCancel(w http.ResponseWriter, r *http.Request) {
+	info, err := s.CancelJob(r.PathValue("id"))
+	switch {
+	case errors.Is(err, ErrNoSuchJob):
+		writeError(w, http.StatusNotFound, err)
+	case err != nil:
+		writeError(w, http.StatusConflict, err)
+	default:
+		writeJSON(w, http.StatusAccepted, info)
+	}
+}
+
+func (s *Server) handleManifest(w http.ResponseWriter, r *http.Request) {
+	job := s.job(w, r)
+	if job == nil {
+		return
+	}
+	if job.opts.Manifest == "" {
+		writeError(w, http.StatusNotFound, errors.New("job has no manifest"))
+		return
+	}
+	if archiveFormat(job.opts.Dir) != "" {
+		writeError(w, http.StatusNotFound, errors.New("the manifest is in the archive"))
+		return
+	}
+
+	// The manifest is saved every few files, and at the end
+	var f io.ReadCloser
+	var err error
+	if IsRemote(job.opts.Dir) {
+		var st Storage
+		if st, err = OpenStorage(job.opts.Dir); err == nil {
+			f, err = st.Open(job.opts.Manifest)
+		}
+	} else {
+		f, err = os.Open(filepath.Join(job.opts.Dir, job.opts.Manifest))
+	}
+	if errors.Is(err, fs.ErrNotExist) {
+		writeJSON(w, http.StatusOK, []ManifestEntry{})
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer f.Close()
+
+	w.Header().Set("Content-Type", "application/json")
+	io.Copy(w, f)
+}
+
+func (s *Server) handleFile(w http.ResponseWriter, r *http.Request) {
+	job := s.job(w, r)
+	if job == nil {
+		return
+	}
+	if IsRemote(job.opts.Dir) {
+		writeError(w, http.StatusNotFound, errors.New("the files of the job are in "+job.info.Dir))
+		return
+	}
+
+	// DirFS takes no .. and no absolute paths
+	http.ServeFileFS(w, r, os.DirFS(job.opts.Dir), r.PathValue("path"))
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
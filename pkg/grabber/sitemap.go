@@ -0,0 +1,208 @@
+package grabber
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/gocolly/colly"
+	"github.com/temoto/robotstxt"
+)
+
+// Sitemap is what the sitemaps of a site list.
+type Sitemap struct {
+	// Pages are the absolute urls of the listed pages.
+	Pages []string
+	// Images are the images listed with <image:loc>, along with their page.
+	Images []ImageRef
+}
+
+// sitemapFile is a sitemap as sitemaps.org has it: a urlset of pages, with
+// the images of the image sitemap extension, or an index of more sitemaps.
+type sitemapFile struct {
+	URLs     []sitemapURL `xml:"url"`
+	Sitemaps []sitemapLoc `xml:"sitemap"`
+}
+
+type sitemapURL struct {
+	Loc    string       `xml:"loc"`
+	Images []sitemapLoc `xml:"image"`
+}
+
+type sitemapLoc struct {
+	Loc string `xml:"loc"`
+}
+
+// isSitemapURL reports whether u looks like a sitemap rather than a page.
+func isSitemapURL(u string) bool {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return false
+	}
+	p := strings.ToLower(parsed.Path)
+	return strings.HasSuffix(p, ".xml") || strings.HasSuffix(p, ".xml.gz")
+}
+
+// Sitemap reads the sitemap at sitemapURL, following sitemap indexes into
+// the sitemaps they list. Plain text sitemaps with one url per line and
+// gzipped sitemaps are read too. Nested sitemaps that fail are logged and
+// left out. The requests end when ctx is done.
+func (c *Collector) Sitemap(ctx context.Context, sitemapURL string) (*Sitemap, error) {
+	cc := c.colly(ctx)
+	sm := &Sitemap{}
+	pages := make(map[string]bool)
+	images := make(map[string]bool)
+
+	var parseErr error
+	cc.OnResponse(func(r *colly.Response) {
+		file, err := parseSitemap(r.Body)
+		if err != nil {
+			if r.Request.URL.String() == sitemapURL {
+				parseErr = err
+			} else {
+				orDiscard(c.Logger).Warn("bad sitemap", "url", r.Request.URL.String(), "err", err)
+			}
+			return
+		}
+
+		for _, u := range file.URLs {
+			page := c.normalize(r.Request.AbsoluteURL(strings.TrimSpace(u.Loc)))
+			if page == "" || !isHTTP(page) {
+				continue
+			}
+			if !pages[page] {
+				pages[page] = true
+				sm.Pages = append(sm.Pages, page)
+			}
+			for _, img := range u.Images {
+				src := c.imageURL(r.Request.AbsoluteURL(strings.TrimSpace(img.Loc)))
+				if src != "" && !images[src] {
+					images[src] = true
+					sm.Images = append(sm.Images, ImageRef{URL: src, Page: page})
+				}
+			}
+		}
+
+		for _, s := range file.Sitemaps {
+			loc := r.Request.AbsoluteURL(strings.TrimSpace(s.Loc))
+			if loc == "" {
+				continue
+			}
+			if err := r.Request.Visit(loc); err != nil && !errors.Is(err, colly.ErrAlreadyVisited) {
+				orDiscard(c.Logger).Warn("bad sitemap", "url", loc, "err", err)
+			}
+		}
+	})
+
+	if err := cc.Visit(sitemapURL); err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if parseErr != nil {
+		return nil, parseErr
+	}
+
+	orDiscard(c.Logger).Debug("read sitemap", "url", sitemapURL, "pages", len(sm.Pages), "images", len(sm.Images))
+
+	return sm, nil
+}
+
+// FindSitemaps returns the sitemaps the robots.txt of the site of pageURL
+// lists, or its /sitemap.xml if it lists none.
+func (c *Collector) FindSitemaps(ctx context.Context, pageURL string) []string {
+	u, err := url.Parse(pageURL)
+	if err != nil {
+		return nil
+	}
+	origin := u.Scheme + "://" + u.Host
+
+	var sitemaps []string
+	cc := c.colly(ctx)
+	cc.OnResponse(func(r *colly.Response) {
+		if data, err := robotstxt.FromBytes(r.Body); err == nil {
+			sitemaps = data.Sitemaps
+		}
+	})
+	cc.Visit(origin + "/robots.txt")
+
+	if len(sitemaps) == 0 {
+		sitemaps = []string{origin + "/sitemap.xml"}
+	}
+	return sitemaps
+}
+
+// parseSitemap reads an XML or plain text sitemap, gunzipping it first if
+// it is compressed.
+func parseSitemap(data []byte) (*sitemapFile, error) {
+	if bytes.HasPrefix(data, []byte{0x1f, 0x8b}) {
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		if data, err = io.ReadAll(zr); err != nil {
+			return nil, err
+		}
+	}
+
+	file := &sitemapFile{}
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] != '<' {
+		scanner := bufio.NewScanner(bytes.NewReader(trimmed))
+		for scanner.Scan() {
+			if line := strings.TrimSpace(scanner.Text()); line != "" {
+				file.URLs = append(file.URLs, sitemapURL{Loc: line})
+			}
+		}
+		return file, scanner.Err()
+	}
+
+	if err := xml.Unmarshal(data, file); err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+// readSitemaps reads the sitemap at url or, if url is a page, the sitemaps
+// FindSitemaps finds for its site. It only fails if none could be read.
+func (g *Grabber) readSitemaps(ctx context.Context, url string) (*Sitemap, error) {
+	sitemaps := []string{url}
+	if !isSitemapURL(url) {
+		sitemaps = g.Collector.FindSitemaps(ctx, url)
+	}
+
+	all := &Sitemap{}
+	var firstErr error
+	read := 0
+	for _, s := range sitemaps {
+		var sm *Sitemap
+		_, err := g.retry(ctx, s, func() (err error) {
+			sm, err = g.Collector.Sitemap(ctx, s)
+			return err
+		})
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if err != nil {
+			g.log().Warn("bad sitemap", "url", s, "err", err)
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		read++
+		all.Pages = append(all.Pages, sm.Pages...)
+		all.Images = append(all.Images, sm.Images...)
+	}
+	if read == 0 {
+		return nil, firstErr
+	}
+
+	return all, nil
+}
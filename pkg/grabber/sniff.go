@@ -0,0 +1,214 @@
+package grabber
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// imageExtensions maps the sniffed content types to the extension files of
+// that type are saved with.
+var imageExtensions = map[string]string{
+	"image/jpeg":               ".jpg",
+	"image/png":                ".png",
+	"image/gif":                ".gif",
+	"image/webp":               ".webp",
+	"image/bmp":                ".bmp",
+	"image/avif":               ".avif",
+	"image/x-icon":             ".ico",
+	"image/vnd.microsoft.icon": ".ico",
+}
+
+// videoExtensions maps the sniffed content types of videos to the extension
+// files of that type are saved with.
+var videoExtensions = map[string]string{
+	"video/mp4":  ".mp4",
+	"video/webm": ".webm",
+	"video/avi":  ".avi",
+	"video/mp2t": ".ts",
+}
+
+// extensionAliases are other spellings of an extension that are fine as is.
+var extensionAliases = map[string]string{
+	".jpeg": ".jpg",
+	".jpe":  ".jpg",
+	".jfif": ".jpg",
+}
+
+// replaceableExtensions are extensions that say nothing about the content,
+// typically scripts serving images, so they get replaced by the real one.
+var replaceableExtensions = map[string]bool{
+	".php":  true,
+	".asp":  true,
+	".aspx": true,
+	".jsp":  true,
+	".cgi":  true,
+	".img":  true,
+	".tmp":  true,
+	".bin":  true,
+}
+
+// notImageError is returned when only images are wanted and a url serves
+// something else. Downloading it again won't change that.
+type notImageError struct {
+	ContentType string
+}
+
+func (e *notImageError) Error() string {
+	return "not an image: " + e.ContentType
+}
+
+// sniffType detects the content type of a file from its first 512 bytes.
+func sniffType(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+	return sniffBytes(head[:n]), nil
+}
+
+// sniffBytes detects the content type from the first 512 bytes of a file.
+func sniffBytes(head []byte) string {
+	// http.DetectContentType doesn't know about AVIF
+	if len(head) >= 12 && bytes.Equal(head[4:8], []byte("ftyp")) &&
+		(bytes.Equal(head[8:12], []byte("avif")) || bytes.Equal(head[8:12], []byte("avis"))) {
+		return "image/avif"
+	}
+
+	// Nor about MPEG transport streams, made of 188 byte packets
+	if len(head) > 188 && head[0] == 0x47 && head[188] == 0x47 {
+		return "video/mp2t"
+	}
+
+	ct := http.DetectContentType(head)
+	if i := strings.IndexByte(ct, ';'); i >= 0 {
+		ct = ct[:i]
+	}
+	return ct
+}
+
+// fixExtension returns name with the extension of contentType. A wrong image
+// extension or a meaningless one is replaced, anything else is kept and the
+// right extension appended. Names of unknown content types are not changed.
+func fixExtension(name, contentType string) string {
+	want, ok := imageExtensions[contentType]
+	if !ok {
+		want, ok = videoExtensions[contentType]
+	}
+	if !ok {
+		return name
+	}
+
+	ext := strings.ToLower(filepath.Ext(name))
+	if alias, ok := extensionAliases[ext]; ok {
+		ext = alias
+	}
+	if ext == want {
+		return name
+	}
+
+	if isImageExtension(ext) || videoFileExtensions[ext] || replaceableExtensions[ext] {
+		return strings.TrimSuffix(name, filepath.Ext(name)) + want
+	}
+	return name + want
+}
+
+func isImageExtension(ext string) bool {
+	for _, e := range imageExtensions {
+		if e == ext {
+			return true
+		}
+	}
+	_, ok := extensionAliases[ext]
+	return ok
+}
+
+// typesNamed returns the content types of the images and videos name
+// stands for, as a content type, its subtype or an extension: image/jpeg,
+// jpeg, jpg and .jpg all name image/jpeg.
+func typesNamed(name string) []string {
+	name = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(name)), ".")
+	ext := "." + name
+	if alias, ok := extensionAliases[ext]; ok {
+		ext = alias
+	}
+	var types []string
+	for _, m := range []map[string]string{imageExtensions, videoExtensions} {
+		for ct, e := range m {
+			_, subtype, _ := strings.Cut(ct, "/")
+			if name == ct || name == subtype || ext == e {
+				types = append(types, ct)
+			}
+		}
+	}
+	return types
+}
+
+// contentTypes returns the content types Options.Types names, nil if it is
+// empty.
+func (o Options) contentTypes() (map[string]bool, error) {
+	if len(o.Types) == 0 {
+		return nil, nil
+	}
+	m := make(map[string]bool)
+	for _, t := range o.Types {
+		types := typesNamed(t)
+		if len(types) == 0 {
+			return nil, fmt.Errorf("unknown type %q", t)
+		}
+		for _, ct := range types {
+			m[ct] = true
+		}
+	}
+	return m, nil
+}
+
+// acceptsType reports whether the extension of u leaves it possible that it
+// is one of Options.Types. Urls without a known extension are let through,
+// the type of those is only known once downloaded.
+func (o Options) acceptsType(u string) bool {
+	if len(o.Types) == 0 {
+		return true
+	}
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return true
+	}
+	guessed := typesNamed(path.Ext(parsed.Path))
+	if len(guessed) == 0 {
+		return true
+	}
+	types, _ := o.contentTypes()
+	for _, ct := range guessed {
+		if types[ct] {
+			return true
+		}
+	}
+	return false
+}
+
+// checkType rejects content of a type d.Types doesn't have: other images
+// and videos are skipped, anything else, like the HTML error pages some
+// servers send with a 200, fails as not an image.
+func (d *Downloader) checkType(contentType string) error {
+	if d.Types == nil || d.Types[contentType] {
+		return nil
+	}
+	if strings.HasPrefix(contentType, "image/") || strings.HasPrefix(contentType, "video/") {
+		return &SkipError{Reason: "type not wanted"}
+	}
+	return &notImageError{ContentType: contentType}
+}
@@ -0,0 +1,104 @@
+package grabber
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// srcsetCandidate is one image candidate of a srcset attribute,
+// e.g. "photo-1024.jpg 1024w" or "photo@2x.jpg 2x".
+type srcsetCandidate struct {
+	URL     string
+	Width   float64 // from a "w" descriptor, 0 if absent
+	Density float64 // from an "x" descriptor, 1 if absent
+}
+
+// parseSrcset splits a srcset attribute into its candidates following the
+// HTML parsing rules: urls may contain commas, descriptors are separated from
+// the url by whitespace and candidates are separated by commas.
+func parseSrcset(srcset string) []srcsetCandidate {
+	var candidates []srcsetCandidate
+
+	s := srcset
+	for {
+		// Skip the separators in front of the url
+		s = strings.TrimLeftFunc(s, func(r rune) bool { return r == ',' || unicode.IsSpace(r) })
+		if s == "" {
+			return candidates
+		}
+
+		end := strings.IndexFunc(s, unicode.IsSpace)
+		if end < 0 {
+			end = len(s)
+		}
+		url := s[:end]
+		s = s[end:]
+
+		var descriptors string
+		if strings.HasSuffix(url, ",") {
+			// A trailing comma ends the candidate without descriptors
+			url = strings.TrimRight(url, ",")
+		} else {
+			descriptors, s = splitDescriptors(s)
+		}
+
+		c := srcsetCandidate{URL: url, Density: 1}
+		for _, d := range strings.Fields(descriptors) {
+			v, err := strconv.ParseFloat(d[:len(d)-1], 64)
+			if err != nil || v <= 0 {
+				continue
+			}
+			switch d[len(d)-1] {
+			case 'w':
+				c.Width = v
+			case 'x':
+				c.Density = v
+			}
+		}
+		candidates = append(candidates, c)
+	}
+}
+
+// splitDescriptors returns the descriptors up to the next comma that is not
+// inside parentheses, and the rest of the srcset after that comma.
+func splitDescriptors(s string) (string, string) {
+	depth := 0
+	for i, r := range s {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			if depth > 0 {
+				depth--
+			}
+		case ',':
+			if depth == 0 {
+				return s[:i], s[i+1:]
+			}
+		}
+	}
+	return s, ""
+}
+
+// bestSrcset returns the url of the highest resolution candidate of a srcset
+// attribute, or "" when there is none. The sizes attribute only tells the
+// browser which candidate fits the current layout, so when grabbing the
+// biggest one it doesn't matter: width descriptors win over pixel densities
+// and are compared directly.
+func bestSrcset(srcset string) string {
+	var best srcsetCandidate
+	for _, c := range parseSrcset(srcset) {
+		switch {
+		case best.URL == "":
+			best = c
+		case c.Width > 0 || best.Width > 0:
+			if c.Width > best.Width {
+				best = c
+			}
+		case c.Density > best.Density:
+			best = c
+		}
+	}
+	return best.URL
+}
@@ -0,0 +1,62 @@
+package grabber
+
+import (
+	"context"
+	"strings"
+
+	"github.com/gocolly/colly"
+)
+
+// resolveStatic finds the images of the photo detail page at pageURL in its
+// HTML, without chrome: the image files the c.ClickSelector element links
+// to, or else the images matching c.ImageSelector and, with c.MetaImages,
+// the meta tag images. It returns nothing if the page has none of them.
+func (c *Collector) resolveStatic(ctx context.Context, pageURL string) ([]ImageRef, error) {
+	cc := c.colly(ctx)
+
+	var linked, images []ImageRef
+	seen := make(map[string]bool)
+	add := func(list *[]ImageRef, e *colly.HTMLElement, src string) {
+		if !isDataURL(src) {
+			src = c.imageURL(e.Request.AbsoluteURL(src))
+		}
+		if src != "" && (isHTTP(src) || isDataURL(src)) && !seen[src] {
+			seen[src] = true
+			*list = append(*list, ImageRef{URL: src, Page: pageURL})
+		}
+	}
+
+	// The element clicked for the full size image often just links to it
+	if c.ClickSelector != "" {
+		cc.OnHTML(c.ClickSelector, func(e *colly.HTMLElement) {
+			if href := e.Request.AbsoluteURL(e.Attr("href")); mediaKind(href) == MediaImage {
+				add(&linked, e, href)
+			}
+		})
+	}
+	if c.ImageSelector != "" {
+		cc.OnHTML(c.ImageSelector, func(e *colly.HTMLElement) {
+			if src := imageSource(e.Attr, c.LazyAttributes); src != "" {
+				add(&images, e, src)
+			}
+		})
+	}
+	if c.MetaImages {
+		cc.OnHTML(metaImageSelector, func(e *colly.HTMLElement) {
+			if src := strings.TrimSpace(e.Attr("content")); src != "" {
+				add(&images, e, src)
+			}
+		})
+	}
+
+	if err := cc.Visit(pageURL); err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if len(linked) > 0 {
+		return linked, nil
+	}
+	return images, nil
+}
@@ -0,0 +1,372 @@
+package grabber
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Storage is somewhere other than the local disk the grabbed files are
+// written to, picked by the scheme of Options.Dir, like s3://bucket/prefix.
+// Names are slash separated and relative to the url the storage was opened
+// with. Like on disk, files are written under a .tmp name first and only
+// renamed to their real name once they passed all checks.
+type Storage interface {
+	// Create starts writing the file name, which only exists once the
+	// writer is closed without an error.
+	Create(name string) (StorageWriter, error)
+	// Open reads the file name.
+	Open(name string) (io.ReadCloser, error)
+	// Stat returns the size of the file name, or an error matching
+	// fs.ErrNotExist if there is none.
+	Stat(name string) (int64, error)
+	// Rename moves the file from to to, replacing what is there.
+	Rename(from, to string) error
+	// Remove deletes the file name.
+	Remove(name string) error
+}
+
+// StorageWriter writes a file to a Storage.
+type StorageWriter interface {
+	io.Writer
+	// Close finishes the file.
+	Close() error
+	// Abort drops what was written so far.
+	Abort() error
+}
+
+var storages struct {
+	mu      sync.RWMutex
+	openers map[string]func(u *url.URL) (Storage, error)
+}
+
+// RegisterStorage makes OpenStorage open the urls with scheme through open.
+// Storages for new services register themselves from a file of their own.
+func RegisterStorage(scheme string, open func(u *url.URL) (Storage, error)) {
+	storages.mu.Lock()
+	defer storages.mu.Unlock()
+
+	if storages.openers == nil {
+		storages.openers = make(map[string]func(u *url.URL) (Storage, error))
+	}
+	storages.openers[scheme] = open
+}
+
+// storageOpener returns the opener registered for the scheme of dir, or
+// that of archives if dir is a .zip or .tar.gz file, nil if dir is a local
+// directory.
+func storageOpener(dir string) (*url.URL, func(u *url.URL) (Storage, error)) {
+	if !strings.Contains(dir, "://") {
+		if archiveFormat(dir) != "" {
+			return &url.URL{Path: dir}, openArchive
+		}
+		return nil, nil
+	}
+	u, err := url.Parse(dir)
+	if err != nil {
+		return nil, nil
+	}
+
+	storages.mu.RLock()
+	defer storages.mu.RUnlock()
+
+	return u, storages.openers[strings.ToLower(u.Scheme)]
+}
+
+// IsRemote reports whether dir is the url of a Storage or an archive rather
+// than a local directory.
+func IsRemote(dir string) bool {
+	_, open := storageOpener(dir)
+	return open != nil
+}
+
+// redactDir returns dir with the password of a storage url in it masked,
+// for showing it.
+func redactDir(dir string) string {
+	if !IsRemote(dir) {
+		return dir
+	}
+	u, err := url.Parse(dir)
+	if err != nil {
+		return dir
+	}
+	return u.Redacted()
+}
+
+// OpenStorage opens the Storage at the url dir.
+func OpenStorage(dir string) (Storage, error) {
+	u, open := storageOpener(dir)
+	if open == nil {
+		return nil, fmt.Errorf("no storage for %s", dir)
+	}
+	return open(u)
+}
+
+// errAborted is what a pipeWriter hands the upload when it is aborted.
+var errAborted = errors.New("upload aborted")
+
+// pipeWriter is a StorageWriter feeding an upload that reads its content,
+// for the clients taking a reader.
+type pipeWriter struct {
+	pw   *io.PipeWriter
+	done chan error
+}
+
+// pipeUpload runs upload in the background, reading what is written to the
+// returned writer.
+func pipeUpload(upload func(r io.Reader) error) StorageWriter {
+	pr, pw := io.Pipe()
+	w := &pipeWriter{pw: pw, done: make(chan error, 1)}
+	go func() {
+		err := upload(pr)
+		pr.CloseWithError(err)
+		w.done <- err
+	}()
+	return w
+}
+
+func (w *pipeWriter) Write(p []byte) (int, error) {
+	return w.pw.Write(p)
+}
+
+func (w *pipeWriter) Close() error {
+	w.pw.Close()
+	return <-w.done
+}
+
+func (w *pipeWriter) Abort() error {
+	w.pw.CloseWithError(errAborted)
+	<-w.done
+	return nil
+}
+
+// remoteHeadSize is how much of the start of a file is kept in memory while
+// writing it to a Storage, to sniff its type and read its dimensions.
+const remoteHeadSize = 64 << 10
+
+// headBuffer keeps the first max bytes written to it.
+type headBuffer struct {
+	bytes.Buffer
+	max int
+}
+
+func (b *headBuffer) Write(p []byte) (int, error) {
+	if room := b.max - b.Len(); room > 0 {
+		if len(p) < room {
+			room = len(p)
+		}
+		b.Buffer.Write(p[:room])
+	}
+	return len(p), nil
+}
+
+// location is the path or url of name in the downloader directory.
+func (d *Downloader) location(name string) string {
+	if d.Storage != nil {
+		return strings.TrimSuffix(d.Dir, "/") + "/" + name
+	}
+	return filepath.Join(d.Dir, name)
+}
+
+// downloadStored is download for d.Storage.
+func (d *Downloader) downloadStored(ctx context.Context, url, referer string, since validators) (*File, int, error) {
+	req, err := d.newRequest(ctx, http.MethodGet, url, referer)
+	if err != nil {
+		return nil, 0, err
+	}
+	since.set(req)
+	resp, err := d.do(ctx, req)
+	if err != nil {
+		var se *statusError
+		if errors.As(err, &se) && se.Code == http.StatusNotModified {
+			return nil, se.Code, notModified()
+		}
+		if errors.As(err, &se) {
+			return nil, se.Code, err
+		}
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	name := getFileName(url, d.TrackingParams)
+	if n := dispositionFileName(resp); n != "" {
+		name = n
+	}
+	if name == "" {
+		name = shortHash([]byte(url))
+	}
+
+	var total uint64
+	if resp.ContentLength > 0 {
+		total = uint64(resp.ContentLength)
+		if err := d.Filter.checkBytes(resp.ContentLength); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	var body io.Reader = resp.Body
+	if c := d.checksums(resp); c != nil {
+		body = c
+	}
+	f, err := d.store(url, name, d.Filter.limit(body, 0), total)
+	d.setModified(f, resp)
+	return f, resp.StatusCode, err
+}
+
+// store writes the content of url read from r to d.Storage as name, going
+// through the same checks, naming and deduplication as a download to disk.
+// total is the expected size, 0 if unknown. Interrupted writes start over
+// and near duplicates aren't looked for, which would mean reading back the
+// whole file.
+func (d *Downloader) store(url, name string, r io.Reader, total uint64) (*File, error) {
+	// served is the type before any conversion
+	served := ""
+	if len(d.Convert) > 0 {
+		// Converting needs the whole image, so only the ones to convert
+		// are read into memory
+		br := bufio.NewReaderSize(r, 512)
+		head, _ := br.Peek(512)
+		r = br
+		served = sniffBytes(head)
+		if contentType := served; d.Convert[contentType] != "" {
+			data, err := io.ReadAll(br)
+			if err != nil {
+				return nil, err
+			}
+			data, _ = d.convert(url, data, contentType)
+			r, total = bytes.NewReader(data), uint64(len(data))
+		}
+	}
+
+	tmpName := name + "." + shortHash([]byte(url)) + ".tmp"
+	w, err := d.Storage.Create(tmpName)
+	if err != nil {
+		return nil, err
+	}
+
+	h := sha256.New()
+	head := &headBuffer{max: remoteHeadSize}
+	p := Progress{URL: url, File: d.location(name), Total: total}
+	counter := &WriteCounter{Progress: func(written uint64) {
+		p.Written = written
+		d.progress(p)
+	}}
+	if _, err := io.Copy(io.MultiWriter(w, h, head), io.TeeReader(r, counter)); err != nil {
+		w.Abort()
+		// Some servers keep what they got of an aborted upload
+		d.Storage.Remove(tmpName)
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	p.Written = counter.Total
+
+	contentType := sniffBytes(head.Bytes())
+	if served == "" {
+		served = contentType
+	}
+	if isErrorPage(url, served) {
+		return nil, d.storedErrorPage(url, tmpName, served, head.Bytes())
+	}
+	if err := d.checkType(served); err != nil {
+		d.Storage.Remove(tmpName)
+		return nil, err
+	}
+	if d.ImagesOnly && !strings.HasPrefix(contentType, "image/") &&
+		!(d.Videos && strings.HasPrefix(contentType, "video/")) {
+		d.Storage.Remove(tmpName)
+		return nil, &notImageError{ContentType: contentType}
+	}
+	if err := d.Filter.checkBytes(int64(p.Written)); err != nil {
+		d.Storage.Remove(tmpName)
+		return nil, err
+	}
+	width, height, err := d.Filter.checkHeader(bytes.NewReader(head.Bytes()))
+	if err != nil {
+		d.Storage.Remove(tmpName)
+		return nil, err
+	}
+
+	t := d.makeThumb(func() (io.ReadCloser, error) { return d.Storage.Open(tmpName) }, contentType)
+
+	sum := hex.EncodeToString(h.Sum(nil))
+	if dest := d.destinationOf(url); dest != "" {
+		name = filepath.ToSlash(dest)
+	} else if dir := d.subdirOf(url); dir != "" {
+		name = filepath.ToSlash(filepath.Join(dir, filepath.Base(name)))
+	}
+	d.mu.Lock()
+	name, err = claimNameWith(fixExtension(name, contentType), sum, func(candidate string) (bool, error) {
+		_, err := d.Storage.Stat(candidate)
+		if errors.Is(err, fs.ErrNotExist) {
+			return true, nil
+		}
+		return err == nil && d.Index != nil && d.Index.lookup(sum) == candidate, err
+	})
+	var original string
+	if err == nil {
+		original, err = d.dedupeStored(tmpName, name, sum)
+	}
+	d.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	f := &File{
+		URL:         url,
+		Path:        d.location(name),
+		Size:        int64(p.Written),
+		SHA256:      sum,
+		ContentType: contentType,
+		Width:       width,
+		Height:      height,
+	}
+	if original != "" {
+		f.DuplicateOf = d.location(original)
+		if d.Dedup == DedupSkip {
+			f.Path = f.DuplicateOf
+			return f, d.saveThumb(f, t)
+		}
+	}
+	if err := d.saveThumb(f, t); err != nil {
+		return nil, err
+	}
+
+	p.File = f.Path
+	p.Done = true
+	d.progress(p)
+
+	return f, nil
+}
+
+// dedupeStored is dedupe for d.Storage. Storages have no hard links, so
+// DedupLink keeps a copy.
+func (d *Downloader) dedupeStored(tmpName, name, sum string) (string, error) {
+	original := ""
+	if d.Dedup.enabled() && d.Index != nil {
+		original = d.Index.lookup(sum)
+	}
+	if original == "" || original == name {
+		if d.Index != nil {
+			d.Index.add(sum, name)
+		}
+		return "", d.Storage.Rename(tmpName, name)
+	}
+
+	if d.Dedup == DedupLink {
+		return original, d.Storage.Rename(tmpName, name)
+	}
+	return original, d.Storage.Remove(tmpName)
+}
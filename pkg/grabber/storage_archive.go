@@ -0,0 +1,257 @@
+package grabber
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The formats an archive can be written in.
+const (
+	archiveZip   = "zip"
+	archiveTar   = "tar"
+	archiveTarGz = "tar.gz"
+)
+
+// archiveFormat tells from its extension which archive format the file at
+// path is, "" if it isn't one.
+func archiveFormat(path string) string {
+	lower := strings.ToLower(path)
+	switch {
+	case strings.HasSuffix(lower, ".zip"):
+		return archiveZip
+	case strings.HasSuffix(lower, ".tar"):
+		return archiveTar
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		return archiveTarGz
+	}
+	return ""
+}
+
+// archiveStorage writes the files into a single zip or tar.gz archive, in
+// the order they are done. Files are spooled next to the archive while they
+// download and go into it once they passed all checks, so nothing that is
+// dropped ever ends up in it. The archive is only created with its first
+// file and replaces what was there before. It is finished by Close.
+type archiveStorage struct {
+	path   string
+	format string
+
+	mu   sync.Mutex
+	file *os.File
+	zw   *zip.Writer
+	gz   *gzip.Writer
+	tw   *tar.Writer
+	// spools are the files being downloaded by name, sizes those in the
+	// archive.
+	spools map[string]string
+	sizes  map[string]int64
+	closed bool
+}
+
+func openArchive(u *url.URL) (Storage, error) {
+	return &archiveStorage{
+		path:   u.Path,
+		format: archiveFormat(u.Path),
+		spools: make(map[string]string),
+		sizes:  make(map[string]int64),
+	}, nil
+}
+
+// archiveSpool is a file waiting to go into the archive.
+type archiveSpool struct {
+	*os.File
+	s    *archiveStorage
+	name string
+}
+
+func (f *archiveSpool) Close() error {
+	if err := f.File.Close(); err != nil {
+		os.Remove(f.File.Name())
+		return err
+	}
+	f.s.mu.Lock()
+	defer f.s.mu.Unlock()
+
+	if old, ok := f.s.spools[f.name]; ok {
+		os.Remove(old)
+	}
+	f.s.spools[f.name] = f.File.Name()
+	return nil
+}
+
+func (f *archiveSpool) Abort() error {
+	f.File.Close()
+	return os.Remove(f.File.Name())
+}
+
+func (s *archiveStorage) Create(name string) (StorageWriter, error) {
+	f, err := os.CreateTemp(filepath.Dir(s.path), ".grab-*.tmp")
+	if err != nil {
+		return nil, err
+	}
+	return &archiveSpool{File: f, s: s, name: name}, nil
+}
+
+// Open reads a file that is still being spooled. Files in the archive
+// can't be read back.
+func (s *archiveStorage) Open(name string) (io.ReadCloser, error) {
+	s.mu.Lock()
+	spool, ok := s.spools[name]
+	s.mu.Unlock()
+	if !ok {
+		return nil, fs.ErrNotExist
+	}
+	return os.Open(spool)
+}
+
+func (s *archiveStorage) Stat(name string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if size, ok := s.sizes[name]; ok {
+		return size, nil
+	}
+	if spool, ok := s.spools[name]; ok {
+		info, err := os.Stat(spool)
+		if err != nil {
+			return 0, err
+		}
+		return info.Size(), nil
+	}
+	return 0, fs.ErrNotExist
+}
+
+// Rename adds the spooled file from to the archive as to. As archives are
+// only ever appended to, that can only be done once for every name.
+func (s *archiveStorage) Rename(from, to string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	spool, ok := s.spools[from]
+	if !ok {
+		return fs.ErrNotExist
+	}
+	if _, ok := s.sizes[to]; ok {
+		return fmt.Errorf("%s is already in %s", to, s.path)
+	}
+	if s.closed {
+		return fmt.Errorf("%s is closed", s.path)
+	}
+
+	f, err := os.Open(spool)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	if err := s.add(to, f, info.Size()); err != nil {
+		return err
+	}
+	s.sizes[to] = info.Size()
+	delete(s.spools, from)
+	return os.Remove(spool)
+}
+
+// add writes the entry name with size bytes read from r, creating the
+// archive first if this is the first entry.
+func (s *archiveStorage) add(name string, r io.Reader, size int64) error {
+	if s.file == nil {
+		f, err := os.Create(s.path)
+		if err != nil {
+			return err
+		}
+		s.file = f
+		switch s.format {
+		case archiveZip:
+			s.zw = zip.NewWriter(f)
+		case archiveTarGz:
+			s.gz = gzip.NewWriter(f)
+			s.tw = tar.NewWriter(s.gz)
+		default:
+			s.tw = tar.NewWriter(f)
+		}
+	}
+
+	now := time.Now()
+	if s.zw != nil {
+		// Images and videos are compressed already
+		w, err := s.zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store, Modified: now})
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(w, r)
+		return err
+	}
+
+	hdr := &tar.Header{Name: name, Mode: 0644, Size: size, ModTime: now, Typeflag: tar.TypeReg}
+	if err := s.tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err := io.Copy(s.tw, r)
+	return err
+}
+
+// Remove drops a spooled file. Files in the archive can't be removed.
+func (s *archiveStorage) Remove(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	spool, ok := s.spools[name]
+	if !ok {
+		if _, ok := s.sizes[name]; ok {
+			return fmt.Errorf("can't remove %s from %s", name, s.path)
+		}
+		return fs.ErrNotExist
+	}
+	delete(s.spools, name)
+	return os.Remove(spool)
+}
+
+// Close finishes the archive and deletes what is left of the spooled files.
+func (s *archiveStorage) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+
+	for name, spool := range s.spools {
+		os.Remove(spool)
+		delete(s.spools, name)
+	}
+	if s.file == nil {
+		return nil
+	}
+
+	var err error
+	if s.zw != nil {
+		err = s.zw.Close()
+	} else {
+		err = s.tw.Close()
+		if s.gz != nil {
+			if gzErr := s.gz.Close(); err == nil {
+				err = gzErr
+			}
+		}
+	}
+	if closeErr := s.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
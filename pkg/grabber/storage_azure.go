@@ -0,0 +1,183 @@
+package grabber
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blockblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/container"
+)
+
+// azureBlockSize is the size of the blocks files are uploaded in. With the
+// limit of 50000 blocks that is files of up to 400GiB.
+const azureBlockSize = 8 << 20
+
+func init() {
+	RegisterStorage("azblob", openAzure)
+}
+
+// azureStorage keeps the files in an Azure Blob Storage container under a
+// prefix.
+type azureStorage struct {
+	client *container.Client
+	prefix string
+}
+
+// openAzure opens the container and prefix of an azblob://container/prefix
+// url. AZURE_STORAGE_CONNECTION_STRING connects to any account, Azurite
+// included. Otherwise the account is AZURE_STORAGE_ACCOUNT, with the key
+// AZURE_STORAGE_KEY if set or else the credentials the Azure CLI and SDKs
+// find, like a managed identity.
+func openAzure(u *url.URL) (Storage, error) {
+	if u.Host == "" {
+		return nil, fmt.Errorf("no container in %s", u)
+	}
+	prefix := strings.Trim(u.Path, "/")
+
+	if conn := os.Getenv("AZURE_STORAGE_CONNECTION_STRING"); conn != "" {
+		client, err := container.NewClientFromConnectionString(conn, u.Host, nil)
+		if err != nil {
+			return nil, err
+		}
+		return &azureStorage{client: client, prefix: prefix}, nil
+	}
+
+	account := os.Getenv("AZURE_STORAGE_ACCOUNT")
+	if account == "" {
+		return nil, errors.New("set AZURE_STORAGE_ACCOUNT or AZURE_STORAGE_CONNECTION_STRING")
+	}
+	containerURL := "https://" + account + ".blob.core.windows.net/" + u.Host
+
+	var client *container.Client
+	if key := os.Getenv("AZURE_STORAGE_KEY"); key != "" {
+		cred, err := container.NewSharedKeyCredential(account, key)
+		if err != nil {
+			return nil, err
+		}
+		if client, err = container.NewClientWithSharedKeyCredential(containerURL, cred, nil); err != nil {
+			return nil, err
+		}
+	} else {
+		cred, err := azidentity.NewDefaultAzureCredential(nil)
+		if err != nil {
+			return nil, err
+		}
+		if client, err = container.NewClient(containerURL, cred, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	return &azureStorage{client: client, prefix: prefix}, nil
+}
+
+// key is the blob name of the file name.
+func (s *azureStorage) key(name string) string {
+	if s.prefix == "" {
+		return name
+	}
+	return s.prefix + "/" + name
+}
+
+// Create uploads the file as it is written, in blocks of azureBlockSize.
+// Aborted uploads leave uncommitted blocks behind, which Azure drops after
+// a week.
+func (s *azureStorage) Create(name string) (StorageWriter, error) {
+	opts := &blockblob.UploadStreamOptions{
+		BlockSize:   azureBlockSize,
+		HTTPHeaders: contentTypeHeaders(name),
+	}
+	bb := s.client.NewBlockBlobClient(s.key(name))
+	return pipeUpload(func(r io.Reader) error {
+		_, err := bb.UploadStream(context.Background(), r, opts)
+		return err
+	}), nil
+}
+
+func (s *azureStorage) Open(name string) (io.ReadCloser, error) {
+	resp, err := s.client.NewBlobClient(s.key(name)).DownloadStream(context.Background(), nil)
+	if err != nil {
+		return nil, azureError(err)
+	}
+	return resp.Body, nil
+}
+
+func (s *azureStorage) Stat(name string) (int64, error) {
+	props, err := s.client.NewBlobClient(s.key(name)).GetProperties(context.Background(), nil)
+	if err != nil {
+		return 0, azureError(err)
+	}
+	if props.ContentLength == nil {
+		return 0, nil
+	}
+	return *props.ContentLength, nil
+}
+
+// Rename copies the blob and deletes the old one, as Azure can't move
+// blobs. The copy gets the content type of its new extension.
+func (s *azureStorage) Rename(from, to string) error {
+	ctx := context.Background()
+	src := s.client.NewBlobClient(s.key(from))
+	dst := s.client.NewBlobClient(s.key(to))
+
+	// Copies within an account usually finish right away, but may also
+	// run in the background
+	resp, err := dst.StartCopyFromURL(ctx, src.URL(), nil)
+	if err != nil {
+		return azureError(err)
+	}
+	status := resp.CopyStatus
+	for status != nil && *status == blob.CopyStatusTypePending {
+		time.Sleep(100 * time.Millisecond)
+		props, err := dst.GetProperties(ctx, nil)
+		if err != nil {
+			return azureError(err)
+		}
+		if status = props.CopyStatus; status != nil && *status != blob.CopyStatusTypePending &&
+			*status != blob.CopyStatusTypeSuccess {
+			desc := ""
+			if props.CopyStatusDescription != nil {
+				desc = *props.CopyStatusDescription
+			}
+			return fmt.Errorf("copy %s: %s %s", from, *status, desc)
+		}
+	}
+
+	if _, err := dst.SetHTTPHeaders(ctx, *contentTypeHeaders(to), nil); err != nil {
+		return azureError(err)
+	}
+	return s.Remove(from)
+}
+
+func (s *azureStorage) Remove(name string) error {
+	_, err := s.client.NewBlobClient(s.key(name)).Delete(context.Background(), nil)
+	return azureError(err)
+}
+
+// contentTypeHeaders sets the content type going by the extension of name.
+func contentTypeHeaders(name string) *blob.HTTPHeaders {
+	ct := mime.TypeByExtension(path.Ext(name))
+	if ct == "" {
+		return &blob.HTTPHeaders{}
+	}
+	return &blob.HTTPHeaders{BlobContentType: &ct}
+}
+
+// azureError turns the error for a missing blob into fs.ErrNotExist.
+func azureError(err error) error {
+	if bloberror.HasCode(err, bloberror.BlobNotFound) {
+		return fs.ErrNotExist
+	}
+	return err
+}
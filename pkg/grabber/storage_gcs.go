@@ -0,0 +1,121 @@
+package grabber
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/url"
+	"path"
+	"strings"
+
+	"cloud.google.com/go/storage"
+)
+
+// gcsChunkSize is the size of the chunks of the resumable uploads files are
+// written with.
+const gcsChunkSize = 16 << 20
+
+func init() {
+	RegisterStorage("gs", openGCS)
+}
+
+// gcsStorage keeps the files in a Google Cloud Storage bucket under a
+// prefix.
+type gcsStorage struct {
+	bucket *storage.BucketHandle
+	prefix string
+}
+
+// openGCS opens the bucket and prefix of a gs://bucket/prefix url, with the
+// application default credentials: GOOGLE_APPLICATION_CREDENTIALS, those of
+// gcloud or the service account of the machine. STORAGE_EMULATOR_HOST
+// points it to an emulator instead.
+func openGCS(u *url.URL) (Storage, error) {
+	if u.Host == "" {
+		return nil, fmt.Errorf("no bucket in %s", u)
+	}
+
+	client, err := storage.NewClient(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	return &gcsStorage{bucket: client.Bucket(u.Host), prefix: strings.Trim(u.Path, "/")}, nil
+}
+
+// object is the object of the file name.
+func (s *gcsStorage) object(name string) *storage.ObjectHandle {
+	if s.prefix == "" {
+		return s.bucket.Object(name)
+	}
+	return s.bucket.Object(s.prefix + "/" + name)
+}
+
+// gcsWriter is a StorageWriter for a resumable upload, which is dropped by
+// cancelling its context.
+type gcsWriter struct {
+	*storage.Writer
+	cancel context.CancelFunc
+}
+
+func (w *gcsWriter) Close() error {
+	defer w.cancel()
+	return w.Writer.Close()
+}
+
+func (w *gcsWriter) Abort() error {
+	w.cancel()
+	w.Writer.Close()
+	return nil
+}
+
+func (s *gcsStorage) Create(name string) (StorageWriter, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	w := s.object(name).NewWriter(ctx)
+	w.ChunkSize = gcsChunkSize
+	w.ContentType = mime.TypeByExtension(path.Ext(name))
+	return &gcsWriter{Writer: w, cancel: cancel}, nil
+}
+
+func (s *gcsStorage) Open(name string) (io.ReadCloser, error) {
+	r, err := s.object(name).NewReader(context.Background())
+	if err != nil {
+		return nil, gcsError(err)
+	}
+	return r, nil
+}
+
+func (s *gcsStorage) Stat(name string) (int64, error) {
+	attrs, err := s.object(name).Attrs(context.Background())
+	if err != nil {
+		return 0, gcsError(err)
+	}
+	return attrs.Size, nil
+}
+
+// Rename copies the object and deletes the old one, as GCS can't move
+// objects. The copy gets the content type of its new extension.
+func (s *gcsStorage) Rename(from, to string) error {
+	ctx := context.Background()
+	c := s.object(to).CopierFrom(s.object(from))
+	c.ContentType = mime.TypeByExtension(path.Ext(to))
+	if _, err := c.Run(ctx); err != nil {
+		return gcsError(err)
+	}
+	return s.Remove(from)
+}
+
+func (s *gcsStorage) Remove(name string) error {
+	return gcsError(s.object(name).Delete(context.Background()))
+}
+
+// gcsError turns the error for a missing object into fs.ErrNotExist.
+func gcsError(err error) error {
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return fs.ErrNotExist
+	}
+	return err
+}
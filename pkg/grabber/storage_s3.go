@@ -0,0 +1,131 @@
+package grabber
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// s3PartSize is the size of the parts files of unknown length are uploaded
+// in. With the limit of 10000 parts that is files of up to 160GiB.
+const s3PartSize = 16 << 20
+
+func init() {
+	RegisterStorage("s3", openS3)
+}
+
+// s3Storage keeps the files in an S3 bucket, or one of a compatible service
+// like MinIO, under a prefix.
+type s3Storage struct {
+	client *minio.Client
+	bucket string
+	prefix string
+}
+
+// openS3 opens the bucket and prefix of an s3://bucket/prefix url. The
+// endpoint is AWS unless AWS_ENDPOINT_URL_S3 or AWS_ENDPOINT_URL says
+// otherwise, as in http://localhost:9000 for a local MinIO. Credentials are
+// taken from the AWS or MinIO environment variables, ~/.aws/credentials or
+// the IAM role of the machine, in that order.
+func openS3(u *url.URL) (Storage, error) {
+	if u.Host == "" {
+		return nil, fmt.Errorf("no bucket in %s", u)
+	}
+
+	endpoint, secure := "s3.amazonaws.com", true
+	for _, env := range []string{"AWS_ENDPOINT_URL_S3", "AWS_ENDPOINT_URL"} {
+		if v := os.Getenv(env); v != "" {
+			e, err := url.Parse(v)
+			if err != nil || e.Host == "" {
+				return nil, fmt.Errorf("bad %s %q", env, v)
+			}
+			endpoint, secure = e.Host, e.Scheme != "http"
+			break
+		}
+	}
+
+	client, err := minio.New(endpoint, &minio.Options{
+		Creds: credentials.NewChainCredentials([]credentials.Provider{
+			&credentials.EnvAWS{},
+			&credentials.EnvMinio{},
+			&credentials.FileAWSCredentials{},
+			&credentials.IAM{},
+		}),
+		Secure: secure,
+		Region: os.Getenv("AWS_REGION"),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &s3Storage{client: client, bucket: u.Host, prefix: strings.Trim(u.Path, "/")}, nil
+}
+
+// key is the object name of the file name.
+func (s *s3Storage) key(name string) string {
+	if s.prefix == "" {
+		return name
+	}
+	return s.prefix + "/" + name
+}
+
+// Create uploads the file as it is written, in parts of s3PartSize.
+func (s *s3Storage) Create(name string) (StorageWriter, error) {
+	opts := minio.PutObjectOptions{
+		ContentType: mime.TypeByExtension(path.Ext(name)),
+		PartSize:    s3PartSize,
+	}
+	return pipeUpload(func(r io.Reader) error {
+		_, err := s.client.PutObject(context.Background(), s.bucket, s.key(name), r, -1, opts)
+		return err
+	}), nil
+}
+
+func (s *s3Storage) Open(name string) (io.ReadCloser, error) {
+	// GetObject only fails on the first read, stat first so a missing
+	// object is reported here
+	if _, err := s.Stat(name); err != nil {
+		return nil, err
+	}
+	return s.client.GetObject(context.Background(), s.bucket, s.key(name), minio.GetObjectOptions{})
+}
+
+func (s *s3Storage) Stat(name string) (int64, error) {
+	info, err := s.client.StatObject(context.Background(), s.bucket, s.key(name), minio.StatObjectOptions{})
+	if err != nil {
+		if minio.ToErrorResponse(err).Code == minio.NoSuchKey {
+			return 0, fs.ErrNotExist
+		}
+		return 0, err
+	}
+	return info.Size, nil
+}
+
+// Rename copies the object and removes the old one, as S3 can't move
+// objects. The copy gets the content type of its new extension.
+func (s *s3Storage) Rename(from, to string) error {
+	dst := minio.CopyDestOptions{
+		Bucket:          s.bucket,
+		Object:          s.key(to),
+		ReplaceMetadata: true,
+		ContentType:     mime.TypeByExtension(path.Ext(to)),
+	}
+	src := minio.CopySrcOptions{Bucket: s.bucket, Object: s.key(from)}
+	if _, err := s.client.ComposeObject(context.Background(), dst, src); err != nil {
+		return err
+	}
+	return s.Remove(from)
+}
+
+func (s *s3Storage) Remove(name string) error {
+	return s.client.RemoveObject(context.Background(), s.bucket, s.key(name), minio.RemoveObjectOptions{})
+}
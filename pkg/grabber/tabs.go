@@ -0,0 +1,70 @@
+package grabber
+
+import (
+	"context"
+	"sync"
+)
+
+// resolvedLink is what resolving a photo link came to.
+type resolvedLink struct {
+	link     string
+	refs     []ImageRef
+	attempts int
+	err      error
+	// disallowed is set if robots.txt disallows the link, canceled if the
+	// run was canceled before it was resolved.
+	disallowed bool
+	canceled   bool
+}
+
+// resolveAll resolves the photo links in up to Options.Tabs chrome tabs at
+// the same time, each page bounded by Options.PageTimeout, and returns what
+// every link came to in the order of links. Links left once the run is
+// canceled come back canceled.
+func (g *Grabber) resolveAll(ctx context.Context, links []string) []resolvedLink {
+	results := make([]resolvedLink, len(links))
+	for i, link := range links {
+		results[i] = resolvedLink{link: link, canceled: true}
+	}
+
+	n := g.Options.Tabs
+	if n < 1 {
+		n = 1
+	}
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < n; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = g.resolveLink(ctx, links[i])
+			}
+		}()
+	}
+	for i := range links {
+		if g.canceledErr(ctx) != nil {
+			break
+		}
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return results
+}
+
+// resolveLink resolves a single photo link, retrying it as Options say.
+func (g *Grabber) resolveLink(ctx context.Context, link string) resolvedLink {
+	r := resolvedLink{link: link}
+	if !g.allowed(ctx, link) {
+		r.disallowed = true
+		r.canceled = ctx.Err() != nil
+		return r
+	}
+	r.attempts, r.err = g.retry(ctx, link, func() (err error) {
+		r.refs, err = g.Collector.Resolve(ctx, link)
+		return err
+	})
+	r.canceled = r.err != nil && ctx.Err() != nil
+	return r
+}
@@ -0,0 +1,158 @@
+package grabber
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/image/draw"
+)
+
+// ThumbsDir is the directory, next to the downloaded files, that the
+// thumbnails are written into with the same relative paths.
+const ThumbsDir = "thumbs"
+
+// thumbQuality is the JPEG quality of thumbnails.
+const thumbQuality = 85
+
+// thumb is an encoded thumbnail and the extension it is saved with.
+type thumb struct {
+	data []byte
+	ext  string
+}
+
+// makeThumb scales the image read from open down to fit in d.Thumbs
+// pixels. PNG images keep their format for the transparency, everything
+// else becomes JPEG. It returns nil if thumbnails are off or the file can't
+// be decoded as an image. It is called before the file gets its final name,
+// as archives can't read files back once they are in.
+func (d *Downloader) makeThumb(open func() (io.ReadCloser, error), contentType string) *thumb {
+	if d.Thumbs <= 0 || !strings.HasPrefix(contentType, "image/") {
+		return nil
+	}
+
+	r, err := open()
+	if err != nil {
+		orDiscard(d.Logger).Warn("no thumbnail", "err", err)
+		return nil
+	}
+	img, _, err := image.Decode(r)
+	r.Close()
+	if err != nil {
+		orDiscard(d.Logger).Debug("no thumbnail", "err", err)
+		return nil
+	}
+
+	var buf bytes.Buffer
+	t := &thumb{ext: ".jpg"}
+	if contentType == "image/png" {
+		t.ext = ".png"
+		err = png.Encode(&buf, scaleDown(img, d.Thumbs))
+	} else {
+		err = jpeg.Encode(&buf, opaque(scaleDown(img, d.Thumbs)), &jpeg.Options{Quality: thumbQuality})
+	}
+	if err != nil {
+		orDiscard(d.Logger).Warn("no thumbnail", "err", err)
+		return nil
+	}
+	t.data = buf.Bytes()
+	return t
+}
+
+// saveThumb writes t into ThumbsDir at the path f.Path has in d.Dir, and
+// sets f.Thumb to it.
+func (d *Downloader) saveThumb(f *File, t *thumb) error {
+	if t == nil {
+		return nil
+	}
+
+	// a.png and a.jpg must not share a thumbnail
+	name := d.relative(f.Path)
+	if e := strings.ToLower(path.Ext(name)); e != t.ext && !(t.ext == ".jpg" && e == ".jpeg") {
+		name += t.ext
+	}
+	name = path.Join(ThumbsDir, filepath.ToSlash(name))
+
+	var err error
+	if d.Storage != nil {
+		// The thumbnail of a duplicate may be in an archive already
+		if _, err := d.Storage.Stat(name); err == nil {
+			f.Thumb = d.location(name)
+			return nil
+		}
+		err = d.writeStored(name, t.data)
+	} else {
+		p := filepath.Join(d.Dir, filepath.FromSlash(name))
+		if err = os.MkdirAll(filepath.Dir(p), 0755); err == nil {
+			err = os.WriteFile(p, t.data, 0644)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("thumbnail: %v", err)
+	}
+	f.Thumb = d.location(name)
+	return nil
+}
+
+// writeStored writes data as name in d.Storage through a tmp object.
+func (d *Downloader) writeStored(name string, data []byte) error {
+	tmp := name + ".tmp"
+	w, err := d.Storage.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		w.Abort()
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return d.Storage.Rename(tmp, name)
+}
+
+// relative returns the path of the saved file p relative to d.Dir, with
+// forward slashes for a Storage.
+func (d *Downloader) relative(p string) string {
+	if d.Storage != nil {
+		return strings.TrimPrefix(p, strings.TrimSuffix(d.Dir, "/")+"/")
+	}
+	if rel, err := filepath.Rel(d.Dir, p); err == nil {
+		return rel
+	}
+	return filepath.Base(p)
+}
+
+// scaleDown returns img scaled to fit in size by size pixels, or img
+// itself if it already does.
+func scaleDown(img image.Image, size int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= size && h <= size {
+		return img
+	}
+	if w > h {
+		w, h = size, max(h*size/w, 1)
+	} else {
+		w, h = max(w*size/h, 1), size
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, b, draw.Src, nil)
+	return dst
+}
+
+// opaque puts img on a white background, JPEG has no transparency.
+func opaque(img image.Image) image.Image {
+	dst := image.NewRGBA(img.Bounds())
+	draw.Draw(dst, dst.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(dst, dst.Bounds(), img, img.Bounds().Min, draw.Over)
+	return dst
+}
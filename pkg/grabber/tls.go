@@ -0,0 +1,65 @@
+package grabber
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// TLSOptions configure how the servers are trusted and how the grabber
+// tells them who it is, for hosts behind a private CA or asking for a
+// client certificate. Chrome only follows Insecure, it trusts the CAs of
+// the system and sends no client certificate.
+type TLSOptions struct {
+	// Insecure accepts any certificate the servers present, chrome
+	// included. Only for hosts there is no other way to trust.
+	Insecure bool `json:"insecure,omitempty" yaml:"insecure" toml:"insecure"`
+	// CAFile is a PEM bundle of CAs trusted on top of those of the system.
+	CAFile string `json:"ca_file,omitempty" yaml:"ca_file" toml:"ca_file"`
+	// CertFile and KeyFile are the PEM client certificate and its key sent
+	// to the servers asking for one.
+	CertFile string `json:"cert_file,omitempty" yaml:"cert_file" toml:"cert_file"`
+	KeyFile  string `json:"key_file,omitempty" yaml:"key_file" toml:"key_file"`
+}
+
+// validate checks that the client certificate comes with its key.
+func (o TLSOptions) validate() error {
+	if (o.CertFile == "") != (o.KeyFile == "") {
+		return errors.New("a client certificate needs both a cert and a key file")
+	}
+	return nil
+}
+
+// config returns the TLS config of the options, nil if there is nothing
+// to change from the default one.
+func (o TLSOptions) config() (*tls.Config, error) {
+	if !o.Insecure && o.CAFile == "" && o.CertFile == "" {
+		return nil, nil
+	}
+
+	c := &tls.Config{InsecureSkipVerify: o.Insecure}
+	if o.CAFile != "" {
+		pem, err := os.ReadFile(o.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates in %s", o.CAFile)
+		}
+		c.RootCAs = pool
+	}
+	if o.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(o.CertFile, o.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		c.Certificates = []tls.Certificate{cert}
+	}
+	return c, nil
+}
@@ -0,0 +1,38 @@
+package grabber
+
+import (
+	"net/http"
+	"time"
+)
+
+// minIdleConnsPerHost is the fewest idle connections kept to a host, so
+// the page requests of a crawl don't close the ones of the downloads.
+const minIdleConnsPerHost = 8
+
+// newTransport returns the transport the requests of a run share, pages
+// and downloads alike. It is http.DefaultTransport speaking HTTP/2 where
+// the server does, but keeping an idle connection to a host for every
+// download worker instead of 2, so hundreds of images from the same CDN
+// reuse their connections rather than each doing a TLS handshake. With
+// Options.MaxConnsPerHost no more than that many connections are open to
+// a host at once. The servers are trusted as Options.TLS says, and
+// reached where Options.Resolve and Options.IPVersion say.
+func newTransport(opts Options) (*http.Transport, error) {
+	tlsConfig, err := opts.TLS.config()
+	if err != nil {
+		return nil, err
+	}
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	if tlsConfig != nil {
+		t.TLSClientConfig = tlsConfig
+	}
+	if dial := opts.dialer(); dial != nil {
+		t.DialContext = dial
+	}
+	t.ForceAttemptHTTP2 = true
+	t.MaxIdleConns = 0
+	t.MaxIdleConnsPerHost = max(opts.Concurrency+opts.Tabs, minIdleConnsPerHost)
+	t.MaxConnsPerHost = opts.MaxConnsPerHost
+	t.IdleConnTimeout = 90 * time.Second
+	return t, nil
+}
@@ -0,0 +1,87 @@
+package grabber
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// ChecksumError is returned when a download doesn't match the checksum its
+// server sent. It is retried, the bytes were most likely damaged on the way.
+type ChecksumError struct {
+	// Header is the header with the checksum, Content-MD5 or ETag.
+	Header string
+	// Want and Got are the hex encoded MD5 sums.
+	Want string
+	Got  string
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("corrupt download: %s is %s, got %s", e.Header, e.Want, e.Got)
+}
+
+// checksumReader hashes a response body while it is read and checks it
+// against the checksums of the response at the end.
+type checksumReader struct {
+	r io.Reader
+	// body is the MD5 of the body, for Content-MD5. whole is the MD5 of
+	// the whole file, for the ETag, which differ when resuming.
+	body       hash.Hash
+	whole      hash.Hash
+	contentMD5 []byte
+	etag       []byte
+}
+
+// checksums returns a reader checking the body of resp, nil if Verify is
+// off or the server sent nothing to check. Only strong ETags that look like
+// an MD5 sum, as S3 and many others send, are taken as one. Bodies the
+// transport decompressed can't be checked, the sums are of what was sent.
+func (d *Downloader) checksums(resp *http.Response) *checksumReader {
+	if !d.Verify || resp.Uncompressed {
+		return nil
+	}
+
+	c := &checksumReader{r: resp.Body, body: md5.New(), whole: md5.New()}
+	if v := resp.Header.Get("Content-MD5"); v != "" {
+		if sum, err := base64.StdEncoding.DecodeString(v); err == nil && len(sum) == md5.Size {
+			c.contentMD5 = sum
+		}
+	}
+	if v := resp.Header.Get("ETag"); v != "" && !strings.HasPrefix(v, "W/") {
+		if sum, err := hex.DecodeString(strings.Trim(v, `"`)); err == nil && len(sum) == md5.Size {
+			c.etag = sum
+		}
+	}
+	if c.contentMD5 == nil && c.etag == nil {
+		return nil
+	}
+	return c
+}
+
+func (c *checksumReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.body.Write(p[:n])
+	c.whole.Write(p[:n])
+	if err == io.EOF {
+		if err := c.check(); err != nil {
+			return n, err
+		}
+	}
+	return n, err
+}
+
+func (c *checksumReader) check() error {
+	if got := c.body.Sum(nil); c.contentMD5 != nil && !bytes.Equal(got, c.contentMD5) {
+		return &ChecksumError{Header: "Content-MD5", Want: hex.EncodeToString(c.contentMD5), Got: hex.EncodeToString(got)}
+	}
+	if got := c.whole.Sum(nil); c.etag != nil && !bytes.Equal(got, c.etag) {
+		return &ChecksumError{Header: "ETag", Want: hex.EncodeToString(c.etag), Got: hex.EncodeToString(got)}
+	}
+	return nil
+}
@@ -0,0 +1,225 @@
+package grabber
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/dustin/go-humanize"
+)
+
+// WebhookFormat is the shape of the JSON posted to Options.Webhook.
+type WebhookFormat string
+
+const (
+	// WebhookGeneric posts a WebhookPayload.
+	WebhookGeneric WebhookFormat = "generic"
+	// WebhookSlack posts a message to a Slack incoming webhook.
+	WebhookSlack WebhookFormat = "slack"
+	// WebhookDiscord posts a message to a Discord webhook.
+	WebhookDiscord WebhookFormat = "discord"
+)
+
+// Set implements flag.Value.
+func (f *WebhookFormat) Set(v string) error {
+	switch WebhookFormat(v) {
+	case "", WebhookGeneric, WebhookSlack, WebhookDiscord:
+		*f = WebhookFormat(v)
+		return nil
+	}
+	return fmt.Errorf("unknown webhook format %q, want generic, slack or discord", v)
+}
+
+func (f WebhookFormat) String() string {
+	return string(f)
+}
+
+// The events a webhook is told about.
+const (
+	WebhookFinished    = "finished"
+	WebhookFailureRate = "failure_rate"
+)
+
+// webhookMinAttempts is how many urls must have been tried before the
+// failure rate means anything.
+const webhookMinAttempts = 10
+
+// WebhookPayload is what a generic webhook gets.
+type WebhookPayload struct {
+	// Event is WebhookFinished or WebhookFailureRate.
+	Event string `json:"event"`
+	// Status is done, failed, canceled or stopped for a finished run,
+	// running for a failure rate alert.
+	Status   string    `json:"status"`
+	Error    string    `json:"error,omitempty"`
+	Dir      string    `json:"dir"`
+	Manifest string    `json:"manifest,omitempty"`
+	Time     time.Time `json:"time"`
+	// FailureRate is the fraction of the urls tried so far that failed.
+	FailureRate    float64 `json:"failure_rate"`
+	ElapsedSeconds float64 `json:"elapsed_seconds"`
+	Report         Report  `json:"report"`
+}
+
+// webhookFormat returns the format of the webhook at u, guessing it from
+// the host if f doesn't say.
+func webhookFormat(f WebhookFormat, u string) WebhookFormat {
+	if f != "" {
+		return f
+	}
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return WebhookGeneric
+	}
+	host := strings.ToLower(parsed.Hostname())
+	switch {
+	case host == "hooks.slack.com":
+		return WebhookSlack
+	case (host == "discord.com" || host == "discordapp.com") && strings.HasPrefix(parsed.Path, "/api/webhooks/"):
+		return WebhookDiscord
+	}
+	return WebhookGeneric
+}
+
+// validateWebhook checks the webhook options.
+func (o Options) validateWebhook() error {
+	if err := new(WebhookFormat).Set(string(o.WebhookFormat)); err != nil {
+		return err
+	}
+	if o.WebhookFailureRate < 0 || o.WebhookFailureRate > 1 {
+		return errors.New("webhook failure rate must be between 0 and 1")
+	}
+	if o.Webhook == "" {
+		return nil
+	}
+	u, err := url.Parse(o.Webhook)
+	if err != nil || u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("webhook %q is not an http or https url", o.Webhook)
+	}
+	return nil
+}
+
+// notifyFinished tells the webhook how the run ended, with err being the
+// first error Crawl or Download returned.
+func (g *Grabber) notifyFinished(err error) {
+	if g.Options.Webhook == "" || g.Options.DryRun {
+		return
+	}
+
+	status := "done"
+	var quotaErr *QuotaError
+	switch {
+	case errors.Is(err, ErrCanceled):
+		status = "canceled"
+	case errors.As(err, &quotaErr):
+		status = "stopped"
+	case err != nil:
+		status = "failed"
+	}
+	g.notify(WebhookFinished, status, err)
+}
+
+// checkFailureRate tells the webhook, once, when more of the urls tried
+// fail than Options.WebhookFailureRate allows.
+func (g *Grabber) checkFailureRate() {
+	if g.Options.Webhook == "" || g.Options.WebhookFailureRate == 0 || g.Options.DryRun {
+		return
+	}
+	tried, rate := g.failures.rate()
+	if tried < webhookMinAttempts || rate <= g.Options.WebhookFailureRate || g.alerted.Swap(true) {
+		return
+	}
+	g.notify(WebhookFailureRate, "running", nil)
+}
+
+// notify posts event to the webhook. Failing to is logged, it doesn't
+// fail the run.
+func (g *Grabber) notify(event, status string, err error) {
+	rep := g.Report()
+	_, rate := g.failures.rate()
+	p := WebhookPayload{
+		Event:          event,
+		Status:         status,
+		Dir:            redactDir(g.Options.Dir),
+		Manifest:       g.manifestLocation(),
+		Time:           time.Now(),
+		FailureRate:    rate,
+		ElapsedSeconds: rep.Elapsed.Seconds(),
+		Report:         rep,
+	}
+	if err != nil {
+		p.Error = err.Error()
+	}
+
+	var body interface{} = p
+	switch webhookFormat(g.Options.WebhookFormat, g.Options.Webhook) {
+	case WebhookSlack:
+		body = map[string]string{"text": p.message()}
+	case WebhookDiscord:
+		body = map[string]string{"content": p.message()}
+	}
+	data, jsonErr := json.Marshal(body)
+	if jsonErr != nil {
+		g.log().Warn("webhook failed", "err", jsonErr)
+		return
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, postErr := client.Post(g.Options.Webhook, "application/json", bytes.NewReader(data))
+	if postErr != nil {
+		g.log().Warn("webhook failed", "event", event, "err", postErr)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		g.log().Warn("webhook failed", "event", event, "status", resp.Status)
+		return
+	}
+	g.log().Debug("webhook told", "event", event, "status", status)
+}
+
+// manifestLocation returns where the manifest is, "" without one.
+func (g *Grabber) manifestLocation() string {
+	switch {
+	case g.Options.Manifest == "":
+		return ""
+	case IsRemote(g.Options.Dir):
+		return strings.TrimSuffix(redactDir(g.Options.Dir), "/") + "/" + g.Options.Manifest
+	}
+	return filepath.Join(g.Options.Dir, g.Options.Manifest)
+}
+
+// message sums p up for a chat.
+func (p WebhookPayload) message() string {
+	var b strings.Builder
+	r := p.Report
+	if p.Event == WebhookFailureRate {
+		fmt.Fprintf(&b, "grab into %s: %.0f%% of the urls failed so far, %d failed and %d images downloaded",
+			p.Dir, p.FailureRate*100, len(r.Failed), r.Downloaded)
+	} else {
+		fmt.Fprintf(&b, "grab into %s %s: %d images downloaded (%s), %d failed, %d skipped in %s",
+			p.Dir, p.Status, r.Downloaded, humanize.Bytes(uint64(r.Bytes)), len(r.Failed), skippedCount(r),
+			time.Duration(p.ElapsedSeconds*float64(time.Second)).Round(time.Second))
+	}
+	if p.Error != "" {
+		fmt.Fprintf(&b, "\nError: %s", p.Error)
+	}
+	if p.Manifest != "" {
+		fmt.Fprintf(&b, "\nManifest: %s", p.Manifest)
+	}
+	return b.String()
+}
+
+func skippedCount(r Report) int {
+	n := 0
+	for _, c := range r.Skipped {
+		n += c
+	}
+	return n
+}
@@ -0,0 +1,4 @@
+// Package grabpb is the gRPC API of grab serve, generated from grab.proto.
+package grabpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative grab.proto
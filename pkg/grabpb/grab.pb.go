@@ -0,0 +1,1286 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: grab.proto
+
+package grabpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	structpb "google.golang.org/protobuf/types/known/structpb"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type Command int32
+
+const (
+	Command_COMMAND_UNSPECIFIED Command = 0
+	// COMMAND_CRAWL crawls the urls as gallery pages, the default.
+	Command_COMMAND_CRAWL Command = 1
+	// COMMAND_DOWNLOAD downloads the urls as images.
+	Command_COMMAND_DOWNLOAD Command = 2
+)
+
+// Enum value maps for Command.
+var (
+	Command_name = map[int32]string{
+		0: "COMMAND_UNSPECIFIED",
+		1: "COMMAND_CRAWL",
+		2: "COMMAND_DOWNLOAD",
+	}
+	Command_value = map[string]int32{
+		"COMMAND_UNSPECIFIED": 0,
+		"COMMAND_CRAWL":       1,
+		"COMMAND_DOWNLOAD":    2,
+	}
+)
+
+func (x Command) Enum() *Command {
+	p := new(Command)
+	*p = x
+	return p
+}
+
+func (x Command) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Command) Descriptor() protoreflect.EnumDescriptor {
+	return file_grab_proto_enumTypes[0].Descriptor()
+}
+
+func (Command) Type() protoreflect.EnumType {
+	return &file_grab_proto_enumTypes[0]
+}
+
+func (x Command) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Command.Descriptor instead.
+func (Command) EnumDescriptor() ([]byte, []int) {
+	return file_grab_proto_rawDescGZIP(), []int{0}
+}
+
+type JobState int32
+
+const (
+	JobState_JOB_STATE_UNSPECIFIED JobState = 0
+	JobState_JOB_STATE_QUEUED      JobState = 1
+	JobState_JOB_STATE_RUNNING     JobState = 2
+	JobState_JOB_STATE_DONE        JobState = 3
+	JobState_JOB_STATE_FAILED      JobState = 4
+	JobState_JOB_STATE_CANCELED    JobState = 5
+)
+
+// Enum value maps for JobState.
+var (
+	JobState_name = map[int32]string{
+		0: "JOB_STATE_UNSPECIFIED",
+		1: "JOB_STATE_QUEUED",
+		2: "JOB_STATE_RUNNING",
+		3: "JOB_STATE_DONE",
+		4: "JOB_STATE_FAILED",
+		5: "JOB_STATE_CANCELED",
+	}
+	JobState_value = map[string]int32{
+		"JOB_STATE_UNSPECIFIED": 0,
+		"JOB_STATE_QUEUED":      1,
+		"JOB_STATE_RUNNING":     2,
+		"JOB_STATE_DONE":        3,
+		"JOB_STATE_FAILED":      4,
+		"JOB_STATE_CANCELED":    5,
+	}
+)
+
+func (x JobState) Enum() *JobState {
+	p := new(JobState)
+	*p = x
+	return p
+}
+
+func (x JobState) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (JobState) Descriptor() protoreflect.EnumDescriptor {
+	return file_grab_proto_enumTypes[1].Descriptor()
+}
+
+func (JobState) Type() protoreflect.EnumType {
+	return &file_grab_proto_enumTypes[1]
+}
+
+func (x JobState) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use JobState.Descriptor instead.
+func (JobState) EnumDescriptor() ([]byte, []int) {
+	return file_grab_proto_rawDescGZIP(), []int{1}
+}
+
+// JobSpec is what a job grabs and how. Unset fields keep the defaults of
+// the server.
+type JobSpec struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Command Command                `protobuf:"varint,1,opt,name=command,proto3,enum=grab.v1.Command" json:"command,omitempty"`
+	Urls    []string               `protobuf:"bytes,2,rep,name=urls,proto3" json:"urls,omitempty"`
+	// dir is the output directory in the root of the server, or the url of a
+	// storage.
+	Dir           string            `protobuf:"bytes,3,opt,name=dir,proto3" json:"dir,omitempty"`
+	Manifest      string            `protobuf:"bytes,4,opt,name=manifest,proto3" json:"manifest,omitempty"`
+	Concurrency   int32             `protobuf:"varint,5,opt,name=concurrency,proto3" json:"concurrency,omitempty"`
+	Depth         int32             `protobuf:"varint,6,opt,name=depth,proto3" json:"depth,omitempty"`
+	LinkSelector  string            `protobuf:"bytes,7,opt,name=link_selector,json=linkSelector,proto3" json:"link_selector,omitempty"`
+	ImageSelector string            `protobuf:"bytes,8,opt,name=image_selector,json=imageSelector,proto3" json:"image_selector,omitempty"`
+	Extensions    []string          `protobuf:"bytes,9,rep,name=extensions,proto3" json:"extensions,omitempty"`
+	Thumbnails    int32             `protobuf:"varint,10,opt,name=thumbnails,proto3" json:"thumbnails,omitempty"`
+	Headers       map[string]string `protobuf:"bytes,11,rep,name=headers,proto3" json:"headers,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// options are the other options, by their names in the REST API.
+	Options       *structpb.Struct `protobuf:"bytes,12,opt,name=options,proto3" json:"options,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *JobSpec) Reset() {
+	*x = JobSpec{}
+	mi := &file_grab_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *JobSpec) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*JobSpec) ProtoMessage() {}
+
+func (x *JobSpec) ProtoReflect() protoreflect.Message {
+	mi := &file_grab_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use JobSpec.ProtoReflect.Descriptor instead.
+func (*JobSpec) Descriptor() ([]byte, []int) {
+	return file_grab_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *JobSpec) GetCommand() Command {
+	if x != nil {
+		return x.Command
+	}
+	return Command_COMMAND_UNSPECIFIED
+}
+
+func (x *JobSpec) GetUrls() []string {
+	if x != nil {
+		return x.Urls
+	}
+	return nil
+}
+
+func (x *JobSpec) GetDir() string {
+	if x != nil {
+		return x.Dir
+	}
+	return ""
+}
+
+func (x *JobSpec) GetManifest() string {
+	if x != nil {
+		return x.Manifest
+	}
+	return ""
+}
+
+func (x *JobSpec) GetConcurrency() int32 {
+	if x != nil {
+		return x.Concurrency
+	}
+	return 0
+}
+
+func (x *JobSpec) GetDepth() int32 {
+	if x != nil {
+		return x.Depth
+	}
+	return 0
+}
+
+func (x *JobSpec) GetLinkSelector() string {
+	if x != nil {
+		return x.LinkSelector
+	}
+	return ""
+}
+
+func (x *JobSpec) GetImageSelector() string {
+	if x != nil {
+		return x.ImageSelector
+	}
+	return ""
+}
+
+func (x *JobSpec) GetExtensions() []string {
+	if x != nil {
+		return x.Extensions
+	}
+	return nil
+}
+
+func (x *JobSpec) GetThumbnails() int32 {
+	if x != nil {
+		return x.Thumbnails
+	}
+	return 0
+}
+
+func (x *JobSpec) GetHeaders() map[string]string {
+	if x != nil {
+		return x.Headers
+	}
+	return nil
+}
+
+func (x *JobSpec) GetOptions() *structpb.Struct {
+	if x != nil {
+		return x.Options
+	}
+	return nil
+}
+
+type SubmitJobRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Spec          *JobSpec               `protobuf:"bytes,1,opt,name=spec,proto3" json:"spec,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SubmitJobRequest) Reset() {
+	*x = SubmitJobRequest{}
+	mi := &file_grab_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SubmitJobRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SubmitJobRequest) ProtoMessage() {}
+
+func (x *SubmitJobRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_grab_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SubmitJobRequest.ProtoReflect.Descriptor instead.
+func (*SubmitJobRequest) Descriptor() ([]byte, []int) {
+	return file_grab_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *SubmitJobRequest) GetSpec() *JobSpec {
+	if x != nil {
+		return x.Spec
+	}
+	return nil
+}
+
+type GetJobRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetJobRequest) Reset() {
+	*x = GetJobRequest{}
+	mi := &file_grab_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetJobRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetJobRequest) ProtoMessage() {}
+
+func (x *GetJobRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_grab_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetJobRequest.ProtoReflect.Descriptor instead.
+func (*GetJobRequest) Descriptor() ([]byte, []int) {
+	return file_grab_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *GetJobRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type ListJobsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListJobsRequest) Reset() {
+	*x = ListJobsRequest{}
+	mi := &file_grab_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListJobsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListJobsRequest) ProtoMessage() {}
+
+func (x *ListJobsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_grab_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListJobsRequest.ProtoReflect.Descriptor instead.
+func (*ListJobsRequest) Descriptor() ([]byte, []int) {
+	return file_grab_proto_rawDescGZIP(), []int{3}
+}
+
+type ListJobsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Jobs          []*Job                 `protobuf:"bytes,1,rep,name=jobs,proto3" json:"jobs,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListJobsResponse) Reset() {
+	*x = ListJobsResponse{}
+	mi := &file_grab_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListJobsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListJobsResponse) ProtoMessage() {}
+
+func (x *ListJobsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_grab_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListJobsResponse.ProtoReflect.Descriptor instead.
+func (*ListJobsResponse) Descriptor() ([]byte, []int) {
+	return file_grab_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ListJobsResponse) GetJobs() []*Job {
+	if x != nil {
+		return x.Jobs
+	}
+	return nil
+}
+
+type StreamProgressRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StreamProgressRequest) Reset() {
+	*x = StreamProgressRequest{}
+	mi := &file_grab_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamProgressRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamProgressRequest) ProtoMessage() {}
+
+func (x *StreamProgressRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_grab_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamProgressRequest.ProtoReflect.Descriptor instead.
+func (*StreamProgressRequest) Descriptor() ([]byte, []int) {
+	return file_grab_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *StreamProgressRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type CancelJobRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CancelJobRequest) Reset() {
+	*x = CancelJobRequest{}
+	mi := &file_grab_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CancelJobRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CancelJobRequest) ProtoMessage() {}
+
+func (x *CancelJobRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_grab_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CancelJobRequest.ProtoReflect.Descriptor instead.
+func (*CancelJobRequest) Descriptor() ([]byte, []int) {
+	return file_grab_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *CancelJobRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type Job struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Id      string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Command Command                `protobuf:"varint,2,opt,name=command,proto3,enum=grab.v1.Command" json:"command,omitempty"`
+	Urls    []string               `protobuf:"bytes,3,rep,name=urls,proto3" json:"urls,omitempty"`
+	Dir     string                 `protobuf:"bytes,4,opt,name=dir,proto3" json:"dir,omitempty"`
+	State   JobState               `protobuf:"varint,5,opt,name=state,proto3,enum=grab.v1.JobState" json:"state,omitempty"`
+	Error   string                 `protobuf:"bytes,6,opt,name=error,proto3" json:"error,omitempty"`
+	Created *timestamppb.Timestamp `protobuf:"bytes,7,opt,name=created,proto3" json:"created,omitempty"`
+	Started *timestamppb.Timestamp `protobuf:"bytes,8,opt,name=started,proto3" json:"started,omitempty"`
+	Ended   *timestamppb.Timestamp `protobuf:"bytes,9,opt,name=ended,proto3" json:"ended,omitempty"`
+	Report  *Report                `protobuf:"bytes,10,opt,name=report,proto3" json:"report,omitempty"`
+	Active  []*FileProgress        `protobuf:"bytes,11,rep,name=active,proto3" json:"active,omitempty"`
+	// files are the last files downloaded, the newest first.
+	Files         []*File `protobuf:"bytes,12,rep,name=files,proto3" json:"files,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Job) Reset() {
+	*x = Job{}
+	mi := &file_grab_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Job) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Job) ProtoMessage() {}
+
+func (x *Job) ProtoReflect() protoreflect.Message {
+	mi := &file_grab_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Job.ProtoReflect.Descriptor instead.
+func (*Job) Descriptor() ([]byte, []int) {
+	return file_grab_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *Job) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Job) GetCommand() Command {
+	if x != nil {
+		return x.Command
+	}
+	return Command_COMMAND_UNSPECIFIED
+}
+
+func (x *Job) GetUrls() []string {
+	if x != nil {
+		return x.Urls
+	}
+	return nil
+}
+
+func (x *Job) GetDir() string {
+	if x != nil {
+		return x.Dir
+	}
+	return ""
+}
+
+func (x *Job) GetState() JobState {
+	if x != nil {
+		return x.State
+	}
+	return JobState_JOB_STATE_UNSPECIFIED
+}
+
+func (x *Job) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *Job) GetCreated() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Created
+	}
+	return nil
+}
+
+func (x *Job) GetStarted() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Started
+	}
+	return nil
+}
+
+func (x *Job) GetEnded() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Ended
+	}
+	return nil
+}
+
+func (x *Job) GetReport() *Report {
+	if x != nil {
+		return x.Report
+	}
+	return nil
+}
+
+func (x *Job) GetActive() []*FileProgress {
+	if x != nil {
+		return x.Active
+	}
+	return nil
+}
+
+func (x *Job) GetFiles() []*File {
+	if x != nil {
+		return x.Files
+	}
+	return nil
+}
+
+type Report struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Pages         int32                  `protobuf:"varint,1,opt,name=pages,proto3" json:"pages,omitempty"`
+	Found         int32                  `protobuf:"varint,2,opt,name=found,proto3" json:"found,omitempty"`
+	Downloaded    int32                  `protobuf:"varint,3,opt,name=downloaded,proto3" json:"downloaded,omitempty"`
+	Bytes         int64                  `protobuf:"varint,4,opt,name=bytes,proto3" json:"bytes,omitempty"`
+	Skipped       map[string]int32       `protobuf:"bytes,5,rep,name=skipped,proto3" json:"skipped,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
+	Failed        []*FailedURL           `protobuf:"bytes,6,rep,name=failed,proto3" json:"failed,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Report) Reset() {
+	*x = Report{}
+	mi := &file_grab_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Report) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Report) ProtoMessage() {}
+
+func (x *Report) ProtoReflect() protoreflect.Message {
+	mi := &file_grab_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Report.ProtoReflect.Descriptor instead.
+func (*Report) Descriptor() ([]byte, []int) {
+	return file_grab_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *Report) GetPages() int32 {
+	if x != nil {
+		return x.Pages
+	}
+	return 0
+}
+
+func (x *Report) GetFound() int32 {
+	if x != nil {
+		return x.Found
+	}
+	return 0
+}
+
+func (x *Report) GetDownloaded() int32 {
+	if x != nil {
+		return x.Downloaded
+	}
+	return 0
+}
+
+func (x *Report) GetBytes() int64 {
+	if x != nil {
+		return x.Bytes
+	}
+	return 0
+}
+
+func (x *Report) GetSkipped() map[string]int32 {
+	if x != nil {
+		return x.Skipped
+	}
+	return nil
+}
+
+func (x *Report) GetFailed() []*FailedURL {
+	if x != nil {
+		return x.Failed
+	}
+	return nil
+}
+
+type FailedURL struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Url           string                 `protobuf:"bytes,1,opt,name=url,proto3" json:"url,omitempty"`
+	Error         string                 `protobuf:"bytes,2,opt,name=error,proto3" json:"error,omitempty"`
+	Attempts      int32                  `protobuf:"varint,3,opt,name=attempts,proto3" json:"attempts,omitempty"`
+	Permanent     bool                   `protobuf:"varint,4,opt,name=permanent,proto3" json:"permanent,omitempty"`
+	Corrupt       bool                   `protobuf:"varint,5,opt,name=corrupt,proto3" json:"corrupt,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FailedURL) Reset() {
+	*x = FailedURL{}
+	mi := &file_grab_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FailedURL) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FailedURL) ProtoMessage() {}
+
+func (x *FailedURL) ProtoReflect() protoreflect.Message {
+	mi := &file_grab_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FailedURL.ProtoReflect.Descriptor instead.
+func (*FailedURL) Descriptor() ([]byte, []int) {
+	return file_grab_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *FailedURL) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+func (x *FailedURL) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *FailedURL) GetAttempts() int32 {
+	if x != nil {
+		return x.Attempts
+	}
+	return 0
+}
+
+func (x *FailedURL) GetPermanent() bool {
+	if x != nil {
+		return x.Permanent
+	}
+	return false
+}
+
+func (x *FailedURL) GetCorrupt() bool {
+	if x != nil {
+		return x.Corrupt
+	}
+	return false
+}
+
+type FileProgress struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Url     string                 `protobuf:"bytes,1,opt,name=url,proto3" json:"url,omitempty"`
+	File    string                 `protobuf:"bytes,2,opt,name=file,proto3" json:"file,omitempty"`
+	Written uint64                 `protobuf:"varint,3,opt,name=written,proto3" json:"written,omitempty"`
+	// total is 0 if the server didn't say.
+	Total         uint64 `protobuf:"varint,4,opt,name=total,proto3" json:"total,omitempty"`
+	Done          bool   `protobuf:"varint,5,opt,name=done,proto3" json:"done,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FileProgress) Reset() {
+	*x = FileProgress{}
+	mi := &file_grab_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FileProgress) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FileProgress) ProtoMessage() {}
+
+func (x *FileProgress) ProtoReflect() protoreflect.Message {
+	mi := &file_grab_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FileProgress.ProtoReflect.Descriptor instead.
+func (*FileProgress) Descriptor() ([]byte, []int) {
+	return file_grab_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *FileProgress) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+func (x *FileProgress) GetFile() string {
+	if x != nil {
+		return x.File
+	}
+	return ""
+}
+
+func (x *FileProgress) GetWritten() uint64 {
+	if x != nil {
+		return x.Written
+	}
+	return 0
+}
+
+func (x *FileProgress) GetTotal() uint64 {
+	if x != nil {
+		return x.Total
+	}
+	return 0
+}
+
+func (x *FileProgress) GetDone() bool {
+	if x != nil {
+		return x.Done
+	}
+	return false
+}
+
+type File struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Url   string                 `protobuf:"bytes,1,opt,name=url,proto3" json:"url,omitempty"`
+	// path and thumb are relative to the job directory.
+	Path          string `protobuf:"bytes,2,opt,name=path,proto3" json:"path,omitempty"`
+	Thumb         string `protobuf:"bytes,3,opt,name=thumb,proto3" json:"thumb,omitempty"`
+	Size          int64  `protobuf:"varint,4,opt,name=size,proto3" json:"size,omitempty"`
+	Width         int32  `protobuf:"varint,5,opt,name=width,proto3" json:"width,omitempty"`
+	Height        int32  `protobuf:"varint,6,opt,name=height,proto3" json:"height,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *File) Reset() {
+	*x = File{}
+	mi := &file_grab_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *File) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*File) ProtoMessage() {}
+
+func (x *File) ProtoReflect() protoreflect.Message {
+	mi := &file_grab_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use File.ProtoReflect.Descriptor instead.
+func (*File) Descriptor() ([]byte, []int) {
+	return file_grab_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *File) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+func (x *File) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *File) GetThumb() string {
+	if x != nil {
+		return x.Thumb
+	}
+	return ""
+}
+
+func (x *File) GetSize() int64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+func (x *File) GetWidth() int32 {
+	if x != nil {
+		return x.Width
+	}
+	return 0
+}
+
+func (x *File) GetHeight() int32 {
+	if x != nil {
+		return x.Height
+	}
+	return 0
+}
+
+type ProgressEvent struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Event:
+	//
+	//	*ProgressEvent_Progress
+	//	*ProgressEvent_File
+	//	*ProgressEvent_Job
+	Event         isProgressEvent_Event `protobuf_oneof:"event"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ProgressEvent) Reset() {
+	*x = ProgressEvent{}
+	mi := &file_grab_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ProgressEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ProgressEvent) ProtoMessage() {}
+
+func (x *ProgressEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_grab_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ProgressEvent.ProtoReflect.Descriptor instead.
+func (*ProgressEvent) Descriptor() ([]byte, []int) {
+	return file_grab_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *ProgressEvent) GetEvent() isProgressEvent_Event {
+	if x != nil {
+		return x.Event
+	}
+	return nil
+}
+
+func (x *ProgressEvent) GetProgress() *FileProgress {
+	if x != nil {
+		if x, ok := x.Event.(*ProgressEvent_Progress); ok {
+			return x.Progress
+		}
+	}
+	return nil
+}
+
+func (x *ProgressEvent) GetFile() *File {
+	if x != nil {
+		if x, ok := x.Event.(*ProgressEvent_File); ok {
+			return x.File
+		}
+	}
+	return nil
+}
+
+func (x *ProgressEvent) GetJob() *Job {
+	if x != nil {
+		if x, ok := x.Event.(*ProgressEvent_Job); ok {
+			return x.Job
+		}
+	}
+	return nil
+}
+
+type isProgressEvent_Event interface {
+	isProgressEvent_Event()
+}
+
+type ProgressEvent_Progress struct {
+	Progress *FileProgress `protobuf:"bytes,1,opt,name=progress,proto3,oneof"`
+}
+
+type ProgressEvent_File struct {
+	File *File `protobuf:"bytes,2,opt,name=file,proto3,oneof"`
+}
+
+type ProgressEvent_Job struct {
+	Job *Job `protobuf:"bytes,3,opt,name=job,proto3,oneof"`
+}
+
+func (*ProgressEvent_Progress) isProgressEvent_Event() {}
+
+func (*ProgressEvent_File) isProgressEvent_Event() {}
+
+func (*ProgressEvent_Job) isProgressEvent_Event() {}
+
+var File_grab_proto protoreflect.FileDescriptor
+
+const file_grab_proto_rawDesc = "" +
+	"\n" +
+	"\n" +
+	"grab.proto\x12\agrab.v1\x1a\x1cgoogle/protobuf/struct.proto\x1a\x1fgoogle/protobuf/timestamp.proto\"\xe3\x03\n" +
+	"\aJobSpec\x12*\n" +
+	"\acommand\x18\x01 \x01(\x0e2\x10.grab.v1.CommandR\acommand\x12\x12\n" +
+	"\x04urls\x18\x02 \x03(\tR\x04urls\x12\x10\n" +
+	"\x03dir\x18\x03 \x01(\tR\x03dir\x12\x1a\n" +
+	"\bmanifest\x18\x04 \x01(\tR\bmanifest\x12 \n" +
+	"\vconcurrency\x18\x05 \x01(\x05R\vconcurrency\x12\x14\n" +
+	"\x05depth\x18\x06 \x01(\x05R\x05depth\x12#\n" +
+	"\rlink_selector\x18\a \x01(\tR\flinkSelector\x12%\n" +
+	"\x0eimage_selector\x18\b \x01(\tR\rimageSelector\x12\x1e\n" +
+	"\n" +
+	"extensions\x18\t \x03(\tR\n" +
+	"extensions\x12\x1e\n" +
+	"\n" +
+	"thumbnails\x18\n" +
+	" \x01(\x05R\n" +
+	"thumbnails\x127\n" +
+	"\aheaders\x18\v \x03(\v2\x1d.grab.v1.JobSpec.HeadersEntryR\aheaders\x121\n" +
+	"\aoptions\x18\f \x01(\v2\x17.google.protobuf.StructR\aoptions\x1a:\n" +
+	"\fHeadersEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"8\n" +
+	"\x10SubmitJobRequest\x12$\n" +
+	"\x04spec\x18\x01 \x01(\v2\x10.grab.v1.JobSpecR\x04spec\"\x1f\n" +
+	"\rGetJobRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\"\x11\n" +
+	"\x0fListJobsRequest\"4\n" +
+	"\x10ListJobsResponse\x12 \n" +
+	"\x04jobs\x18\x01 \x03(\v2\f.grab.v1.JobR\x04jobs\"'\n" +
+	"\x15StreamProgressRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\"\"\n" +
+	"\x10CancelJobRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\"\xc1\x03\n" +
+	"\x03Job\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12*\n" +
+	"\acommand\x18\x02 \x01(\x0e2\x10.grab.v1.CommandR\acommand\x12\x12\n" +
+	"\x04urls\x18\x03 \x03(\tR\x04urls\x12\x10\n" +
+	"\x03dir\x18\x04 \x01(\tR\x03dir\x12'\n" +
+	"\x05state\x18\x05 \x01(\x0e2\x11.grab.v1.JobStateR\x05state\x12\x14\n" +
+	"\x05error\x18\x06 \x01(\tR\x05error\x124\n" +
+	"\acreated\x18\a \x01(\v2\x1a.google.protobuf.TimestampR\acreated\x124\n" +
+	"\astarted\x18\b \x01(\v2\x1a.google.protobuf.TimestampR\astarted\x120\n" +
+	"\x05ended\x18\t \x01(\v2\x1a.google.protobuf.TimestampR\x05ended\x12'\n" +
+	"\x06report\x18\n" +
+	" \x01(\v2\x0f.grab.v1.ReportR\x06report\x12-\n" +
+	"\x06active\x18\v \x03(\v2\x15.grab.v1.FileProgressR\x06active\x12#\n" +
+	"\x05files\x18\f \x03(\v2\r.grab.v1.FileR\x05files\"\x8a\x02\n" +
+	"\x06Report\x12\x14\n" +
+	"\x05pages\x18\x01 \x01(\x05R\x05pages\x12\x14\n" +
+	"\x05found\x18\x02 \x01(\x05R\x05found\x12\x1e\n" +
+	"\n" +
+	"downloaded\x18\x03 \x01(\x05R\n" +
+	"downloaded\x12\x14\n" +
+	"\x05bytes\x18\x04 \x01(\x03R\x05bytes\x126\n" +
+	"\askipped\x18\x05 \x03(\v2\x1c.grab.v1.Report.SkippedEntryR\askipped\x12*\n" +
+	"\x06failed\x18\x06 \x03(\v2\x12.grab.v1.FailedURLR\x06failed\x1a:\n" +
+	"\fSkippedEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x05R\x05value:\x028\x01\"\x87\x01\n" +
+	"\tFailedURL\x12\x10\n" +
+	"\x03url\x18\x01 \x01(\tR\x03url\x12\x14\n" +
+	"\x05error\x18\x02 \x01(\tR\x05error\x12\x1a\n" +
+	"\battempts\x18\x03 \x01(\x05R\battempts\x12\x1c\n" +
+	"\tpermanent\x18\x04 \x01(\bR\tpermanent\x12\x18\n" +
+	"\acorrupt\x18\x05 \x01(\bR\acorrupt\"x\n" +
+	"\fFileProgress\x12\x10\n" +
+	"\x03url\x18\x01 \x01(\tR\x03url\x12\x12\n" +
+	"\x04file\x18\x02 \x01(\tR\x04file\x12\x18\n" +
+	"\awritten\x18\x03 \x01(\x04R\awritten\x12\x14\n" +
+	"\x05total\x18\x04 \x01(\x04R\x05total\x12\x12\n" +
+	"\x04done\x18\x05 \x01(\bR\x04done\"\x84\x01\n" +
+	"\x04File\x12\x10\n" +
+	"\x03url\x18\x01 \x01(\tR\x03url\x12\x12\n" +
+	"\x04path\x18\x02 \x01(\tR\x04path\x12\x14\n" +
+	"\x05thumb\x18\x03 \x01(\tR\x05thumb\x12\x12\n" +
+	"\x04size\x18\x04 \x01(\x03R\x04size\x12\x14\n" +
+	"\x05width\x18\x05 \x01(\x05R\x05width\x12\x16\n" +
+	"\x06height\x18\x06 \x01(\x05R\x06height\"\x94\x01\n" +
+	"\rProgressEvent\x123\n" +
+	"\bprogress\x18\x01 \x01(\v2\x15.grab.v1.FileProgressH\x00R\bprogress\x12#\n" +
+	"\x04file\x18\x02 \x01(\v2\r.grab.v1.FileH\x00R\x04file\x12 \n" +
+	"\x03job\x18\x03 \x01(\v2\f.grab.v1.JobH\x00R\x03jobB\a\n" +
+	"\x05event*K\n" +
+	"\aCommand\x12\x17\n" +
+	"\x13COMMAND_UNSPECIFIED\x10\x00\x12\x11\n" +
+	"\rCOMMAND_CRAWL\x10\x01\x12\x14\n" +
+	"\x10COMMAND_DOWNLOAD\x10\x02*\x94\x01\n" +
+	"\bJobState\x12\x19\n" +
+	"\x15JOB_STATE_UNSPECIFIED\x10\x00\x12\x14\n" +
+	"\x10JOB_STATE_QUEUED\x10\x01\x12\x15\n" +
+	"\x11JOB_STATE_RUNNING\x10\x02\x12\x12\n" +
+	"\x0eJOB_STATE_DONE\x10\x03\x12\x14\n" +
+	"\x10JOB_STATE_FAILED\x10\x04\x12\x16\n" +
+	"\x12JOB_STATE_CANCELED\x10\x052\xb2\x02\n" +
+	"\aGrabber\x124\n" +
+	"\tSubmitJob\x12\x19.grab.v1.SubmitJobRequest\x1a\f.grab.v1.Job\x12.\n" +
+	"\x06GetJob\x12\x16.grab.v1.GetJobRequest\x1a\f.grab.v1.Job\x12?\n" +
+	"\bListJobs\x12\x18.grab.v1.ListJobsRequest\x1a\x19.grab.v1.ListJobsResponse\x12J\n" +
+	"\x0eStreamProgress\x12\x1e.grab.v1.StreamProgressRequest\x1a\x16.grab.v1.ProgressEvent0\x01\x124\n" +
+	"\tCancelJob\x12\x19.grab.v1.CancelJobRequest\x1a\f.grab.v1.JobB,Z*github.com/d3z41k/image-grabber/pkg/grabpbb\x06proto3"
+
+var (
+	file_grab_proto_rawDescOnce sync.Once
+	file_grab_proto_rawDescData []byte
+)
+
+func file_grab_proto_rawDescGZIP() []byte {
+	file_grab_proto_rawDescOnce.Do(func() {
+		file_grab_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_grab_proto_rawDesc), len(file_grab_proto_rawDesc)))
+	})
+	return file_grab_proto_rawDescData
+}
+
+var file_grab_proto_enumTypes = make([]protoimpl.EnumInfo, 2)
+var file_grab_proto_msgTypes = make([]protoimpl.MessageInfo, 15)
+var file_grab_proto_goTypes = []any{
+	(Command)(0),                  // 0: grab.v1.Command
+	(JobState)(0),                 // 1: grab.v1.JobState
+	(*JobSpec)(nil),               // 2: grab.v1.JobSpec
+	(*SubmitJobRequest)(nil),      // 3: grab.v1.SubmitJobRequest
+	(*GetJobRequest)(nil),         // 4: grab.v1.GetJobRequest
+	(*ListJobsRequest)(nil),       // 5: grab.v1.ListJobsRequest
+	(*ListJobsResponse)(nil),      // 6: grab.v1.ListJobsResponse
+	(*StreamProgressRequest)(nil), // 7: grab.v1.StreamProgressRequest
+	(*CancelJobRequest)(nil),      // 8: grab.v1.CancelJobRequest
+	(*Job)(nil),                   // 9: grab.v1.Job
+	(*Report)(nil),                // 10: grab.v1.Report
+	(*FailedURL)(nil),             // 11: grab.v1.FailedURL
+	(*FileProgress)(nil),          // 12: grab.v1.FileProgress
+	(*File)(nil),                  // 13: grab.v1.File
+	(*ProgressEvent)(nil),         // 14: grab.v1.ProgressEvent
+	nil,                           // 15: grab.v1.JobSpec.HeadersEntry
+	nil,                           // 16: grab.v1.Report.SkippedEntry
+	(*structpb.Struct)(nil),       // 17: google.protobuf.Struct
+	(*timestamppb.Timestamp)(nil), // 18: google.protobuf.Timestamp
+}
+var file_grab_proto_depIdxs = []int32{
+	0,  // 0: grab.v1.JobSpec.command:type_name -> grab.v1.Command
+	15, // 1: grab.v1.JobSpec.headers:type_name -> grab.v1.JobSpec.HeadersEntry
+	17, // 2: grab.v1.JobSpec.options:type_name -> google.protobuf.Struct
+	2,  // 3: grab.v1.SubmitJobRequest.spec:type_name -> grab.v1.JobSpec
+	9,  // 4: grab.v1.ListJobsResponse.jobs:type_name -> grab.v1.Job
+	0,  // 5: grab.v1.Job.command:type_name -> grab.v1.Command
+	1,  // 6: grab.v1.Job.state:type_name -> grab.v1.JobState
+	18, // 7: grab.v1.Job.created:type_name -> google.protobuf.Timestamp
+	18, // 8: grab.v1.Job.started:type_name -> google.protobuf.Timestamp
+	18, // 9: grab.v1.Job.ended:type_name -> google.protobuf.Timestamp
+	10, // 10: grab.v1.Job.report:type_name -> grab.v1.Report
+	12, // 11: grab.v1.Job.active:type_name -> grab.v1.FileProgress
+	13, // 12: grab.v1.Job.files:type_name -> grab.v1.File
+	16, // 13: grab.v1.Report.skipped:type_name -> grab.v1.Report.SkippedEntry
+	11, // 14: grab.v1.Report.failed:type_name -> grab.v1.FailedURL
+	12, // 15: grab.v1.ProgressEvent.progress:type_name -> grab.v1.FileProgress
+	13, // 16: grab.v1.ProgressEvent.file:type_name -> grab.v1.File
+	9,  // 17: grab.v1.ProgressEvent.job:type_name -> grab.v1.Job
+	3,  // 18: grab.v1.Grabber.SubmitJob:input_type -> grab.v1.SubmitJobRequest
+	4,  // 19: grab.v1.Grabber.GetJob:input_type -> grab.v1.GetJobRequest
+	5,  // 20: grab.v1.Grabber.ListJobs:input_type -> grab.v1.ListJobsRequest
+	7,  // 21: grab.v1.Grabber.StreamProgress:input_type -> grab.v1.StreamProgressRequest
+	8,  // 22: grab.v1.Grabber.CancelJob:input_type -> grab.v1.CancelJobRequest
+	9,  // 23: grab.v1.Grabber.SubmitJob:output_type -> grab.v1.Job
+	9,  // 24: grab.v1.Grabber.GetJob:output_type -> grab.v1.Job
+	6,  // 25: grab.v1.Grabber.ListJobs:output_type -> grab.v1.ListJobsResponse
+	14, // 26: grab.v1.Grabber.StreamProgress:output_type -> grab.v1.ProgressEvent
+	9,  // 27: grab.v1.Grabber.CancelJob:output_type -> grab.v1.Job
+	23, // [23:28] is the sub-list for method output_type
+	18, // [18:23] is the sub-list for method input_type
+	18, // [18:18] is the sub-list for extension type_name
+	18, // [18:18] is the sub-list for extension extendee
+	0,  // [0:18] is the sub-list for field type_name
+}
+
+func init() { file_grab_proto_init() }
+func file_grab_proto_init() {
+	if File_grab_proto != nil {
+		return
+	}
+	file_grab_proto_msgTypes[12].OneofWrappers = []any{
+		(*ProgressEvent_Progress)(nil),
+		(*ProgressEvent_File)(nil),
+		(*ProgressEvent_Job)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_grab_proto_rawDesc), len(file_grab_proto_rawDesc)),
+			NumEnums:      2,
+			NumMessages:   15,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_grab_proto_goTypes,
+		DependencyIndexes: file_grab_proto_depIdxs,
+		EnumInfos:         file_grab_proto_enumTypes,
+		MessageInfos:      file_grab_proto_msgTypes,
+	}.Build()
+	File_grab_proto = out.File
+	file_grab_proto_goTypes = nil
+	file_grab_proto_depIdxs = nil
+}
@@ -0,0 +1,293 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.6.2
+// - protoc             (unknown)
+// source: grab.proto
+
+package grabpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Grabber_SubmitJob_FullMethodName      = "/grab.v1.Grabber/SubmitJob"
+	Grabber_GetJob_FullMethodName         = "/grab.v1.Grabber/GetJob"
+	Grabber_ListJobs_FullMethodName       = "/grab.v1.Grabber/ListJobs"
+	Grabber_StreamProgress_FullMethodName = "/grab.v1.Grabber/StreamProgress"
+	Grabber_CancelJob_FullMethodName      = "/grab.v1.Grabber/CancelJob"
+)
+
+// GrabberClient is the client API for Grabber service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Grabber runs crawl and download jobs, like the REST API of grab serve.
+type GrabberClient interface {
+	// SubmitJob starts a job once a slot is free.
+	SubmitJob(ctx context.Context, in *SubmitJobRequest, opts ...grpc.CallOption) (*Job, error)
+	// GetJob returns a job with its report so far.
+	GetJob(ctx context.Context, in *GetJobRequest, opts ...grpc.CallOption) (*Job, error)
+	// ListJobs returns every job, the oldest first.
+	ListJobs(ctx context.Context, in *ListJobsRequest, opts ...grpc.CallOption) (*ListJobsResponse, error)
+	// StreamProgress sends the job as it is, then what happens to it until
+	// it ends, ending with the job as it ended.
+	StreamProgress(ctx context.Context, in *StreamProgressRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ProgressEvent], error)
+	// CancelJob cancels a queued or running job.
+	CancelJob(ctx context.Context, in *CancelJobRequest, opts ...grpc.CallOption) (*Job, error)
+}
+
+type grabberClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewGrabberClient(cc grpc.ClientConnInterface) GrabberClient {
+	return &grabberClient{cc}
+}
+
+func (c *grabberClient) SubmitJob(ctx context.Context, in *SubmitJobRequest, opts ...grpc.CallOption) (*Job, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Job)
+	err := c.cc.Invoke(ctx, Grabber_SubmitJob_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *grabberClient) GetJob(ctx context.Context, in *GetJobRequest, opts ...grpc.CallOption) (*Job, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Job)
+	err := c.cc.Invoke(ctx, Grabber_GetJob_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *grabberClient) ListJobs(ctx context.Context, in *ListJobsRequest, opts ...grpc.CallOption) (*ListJobsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListJobsResponse)
+	err := c.cc.Invoke(ctx, Grabber_ListJobs_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *grabberClient) StreamProgress(ctx context.Context, in *StreamProgressRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ProgressEvent], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Grabber_ServiceDesc.Streams[0], Grabber_StreamProgress_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[StreamProgressRequest, ProgressEvent]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Grabber_StreamProgressClient = grpc.ServerStreamingClient[ProgressEvent]
+
+func (c *grabberClient) CancelJob(ctx context.Context, in *CancelJobRequest, opts ...grpc.CallOption) (*Job, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Job)
+	err := c.cc.Invoke(ctx, Grabber_CancelJob_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GrabberServer is the server API for Grabber service.
+// All implementations must embed UnimplementedGrabberServer
+// for forward compatibility.
+//
+// Grabber runs crawl and download jobs, like the REST API of grab serve.
+type GrabberServer interface {
+	// SubmitJob starts a job once a slot is free.
+	SubmitJob(context.Context, *SubmitJobRequest) (*Job, error)
+	// GetJob returns a job with its report so far.
+	GetJob(context.Context, *GetJobRequest) (*Job, error)
+	// ListJobs returns every job, the oldest first.
+	ListJobs(context.Context, *ListJobsRequest) (*ListJobsResponse, error)
+	// StreamProgress sends the job as it is, then what happens to it until
+	// it ends, ending with the job as it ended.
+	StreamProgress(*StreamProgressRequest, grpc.ServerStreamingServer[ProgressEvent]) error
+	// CancelJob cancels a queued or running job.
+	CancelJob(context.Context, *CancelJobRequest) (*Job, error)
+	mustEmbedUnimplementedGrabberServer()
+}
+
+// UnimplementedGrabberServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedGrabberServer struct{}
+
+func (UnimplementedGrabberServer) SubmitJob(context.Context, *SubmitJobRequest) (*Job, error) {
+	return nil, status.Error(codes.Unimplemented, "method SubmitJob not implemented")
+}
+func (UnimplementedGrabberServer) GetJob(context.Context, *GetJobRequest) (*Job, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetJob not implemented")
+}
+func (UnimplementedGrabberServer) ListJobs(context.Context, *ListJobsRequest) (*ListJobsResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method ListJobs not implemented")
+}
+func (UnimplementedGrabberServer) StreamProgress(*StreamProgressRequest, grpc.ServerStreamingServer[ProgressEvent]) error {
+	return status.Error(codes.Unimplemented, "method StreamProgress not implemented")
+}
+func (UnimplementedGrabberServer) CancelJob(context.Context, *CancelJobRequest) (*Job, error) {
+	return nil, status.Error(codes.Unimplemented, "method CancelJob not implemented")
+}
+func (UnimplementedGrabberServer) mustEmbedUnimplementedGrabberServer() {}
+func (UnimplementedGrabberServer) testEmbeddedByValue()                 {}
+
+// UnsafeGrabberServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to GrabberServer will
+// result in compilation errors.
+type UnsafeGrabberServer interface {
+	mustEmbedUnimplementedGrabberServer()
+}
+
+func RegisterGrabberServer(s grpc.ServiceRegistrar, srv GrabberServer) {
+	// If the following call panics, it indicates UnimplementedGrabberServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Grabber_ServiceDesc, srv)
+}
+
+func _Grabber_SubmitJob_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SubmitJobRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GrabberServer).SubmitJob(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Grabber_SubmitJob_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GrabberServer).SubmitJob(ctx, req.(*SubmitJobRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Grabber_GetJob_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetJobRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GrabberServer).GetJob(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Grabber_GetJob_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GrabberServer).GetJob(ctx, req.(*GetJobRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Grabber_ListJobs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListJobsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GrabberServer).ListJobs(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Grabber_ListJobs_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GrabberServer).ListJobs(ctx, req.(*ListJobsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Grabber_StreamProgress_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamProgressRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(GrabberServer).StreamProgress(m, &grpc.GenericServerStream[StreamProgressRequest, ProgressEvent]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Grabber_StreamProgressServer = grpc.ServerStreamingServer[ProgressEvent]
+
+func _Grabber_CancelJob_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CancelJobRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GrabberServer).CancelJob(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Grabber_CancelJob_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GrabberServer).CancelJob(ctx, req.(*CancelJobRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Grabber_ServiceDesc is the grpc.ServiceDesc for Grabber service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Grabber_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "grab.v1.Grabber",
+	HandlerType: (*GrabberServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "SubmitJob",
+			Handler:    _Grabber_SubmitJob_Handler,
+		},
+		{
+			MethodName: "GetJob",
+			Handler:    _Grabber_GetJob_Handler,
+		},
+		{
+			MethodName: "ListJobs",
+			Handler:    _Grabber_ListJobs_Handler,
+		},
+		{
+			MethodName: "CancelJob",
+			Handler:    _Grabber_CancelJob_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamProgress",
+			Handler:       _Grabber_StreamProgress_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "grab.proto",
+}